@@ -1,16 +1,74 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"talos-autoextender/pkg/backup"
 	"talos-autoextender/pkg/dns"
+	"talos-autoextender/pkg/imagefactory"
 	"talos-autoextender/pkg/network"
 	"talos-autoextender/pkg/providers"
+	"talos-autoextender/pkg/state"
+	"talos-autoextender/pkg/talos"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
 )
 
+// Exit codes by kind of failure, so automation can decide how to react
+const (
+	exitFailure      = 1
+	exitUsage        = 2
+	exitUnauthorized = 3
+	exitNotFound     = 4
+	exitQuota        = 5
+	exitInvalid      = 6
+	exitTransient    = 7
+	exitOverBudget   = 8
+)
+
+// exitCode is the status the process exits with once the command returns
+var exitCode int
+
+// fail reports the error a command stops on and sets the exit code for its kind
+func fail(message string, err error) {
+	fmt.Printf("%s: %v\n", message, err)
+	exitCode = errorExitCode(err)
+}
+
+func errorExitCode(err error) int {
+	switch {
+	case errors.Is(err, providers.ErrUnauthorized):
+		return exitUnauthorized
+	case errors.Is(err, providers.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, providers.ErrQuotaExceeded):
+		return exitQuota
+	case errors.Is(err, providers.ErrInvalidRegion), errors.Is(err, providers.ErrInvalidSpec):
+		return exitInvalid
+	case errors.Is(err, providers.ErrTransient):
+		return exitTransient
+	case errors.Is(err, providers.ErrOverBudget):
+		return exitOverBudget
+	}
+	return exitFailure
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "talos-autoextender",
 	Short: "A tool to extend Talos clusters into the cloud",
@@ -19,23 +77,130 @@ to one or more cloud-based Talos clusters.
 
 It enables secure, seamless exposure of home-hosted services to the
 internet via cloud ingress, with dynamic DNS, automated provisioning,
-and minimal manual intervention.`,
+and minimal manual intervention.
+
+Exit codes:
+  0  success
+  1  failure
+  2  invalid command line
+  3  cloud provider credentials rejected
+  4  cloud provider resource not found
+  5  cloud provider quota exceeded
+  6  invalid region or cluster spec
+  7  transient cloud provider error; retrying may succeed
+  8  refused by the cost budget`,
 }
 
 var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a cloud cluster extension",
-	Long:  `Create a new Talos cluster in the cloud to extend your home cluster.`,
+	Long: `Create a new Talos cluster in the cloud to extend your home cluster.
+
+Nodes are grouped into pools made from --nodes, --size and --controlplanes, or
+read from --pools-file. Pod, service and VPC ranges that are not given are
+allocated so they do not overlap the home or other recorded clusters. The cost
+is printed and checked against the budget before any instance is created, and
+the cluster is bootstrapped once its nodes are running.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		provider, _ := cmd.Flags().GetString("provider")
 		region, _ := cmd.Flags().GetString("region")
-		nodeCount, _ := cmd.Flags().GetInt("nodes")
-		nodeSize, _ := cmd.Flags().GetString("size")
+		clusterName, _ := cmd.Flags().GetString("name")
 		talosVersion, _ := cmd.Flags().GetString("talos-version")
 		apiKey, _ := cmd.Flags().GetString("api-key")
+		podCIDR, _ := cmd.Flags().GetString("pod-cidr")
+		serviceCIDR, _ := cmd.Flags().GetString("service-cidr")
+		supernet, _ := cmd.Flags().GetString("supernet")
+		kubernetesVersion, _ := cmd.Flags().GetString("kubernetes-version")
+		image, _ := cmd.Flags().GetString("image")
+		endpoint, _ := cmd.Flags().GetString("endpoint")
+		skipBootstrap, _ := cmd.Flags().GetBool("skip-bootstrap")
+		extensions, _ := cmd.Flags().GetStringSlice("extension")
+		kernelArgs, _ := cmd.Flags().GetStringArray("kernel-arg")
+		factoryURL, _ := cmd.Flags().GetString("image-factory")
+		noFirewall, _ := cmd.Flags().GetBool("no-firewall")
+		adminCIDRs, _ := cmd.Flags().GetStringSlice("admin-cidr")
+		ingressPorts, _ := cmd.Flags().GetIntSlice("ingress-port")
+		ingressCIDRs, _ := cmd.Flags().GetStringSlice("ingress-cidr")
+		useVPC, _ := cmd.Flags().GetBool("vpc")
+		vpcName, _ := cmd.Flags().GetString("vpc-name")
+		vpcSubnet, _ := cmd.Flags().GetString("vpc-subnet")
+		loadBalancer, _ := cmd.Flags().GetBool("load-balancer")
+		ingressIP, _ := cmd.Flags().GetBool("ingress-ip")
+		placement, _ := cmd.Flags().GetString("placement")
+
+		pools, err := flagPools(cmd)
+		if err != nil {
+			fail("Error reading node pools", err)
+			return
+		}
+
+		// Nodes boot --image, or a Talos image the Image Factory builds for the
+		// extensions and kernel args
+		if image == "" && len(extensions) == 0 && len(kernelArgs) == 0 {
+			fail("Error", fmt.Errorf("%w: nodes need a Talos image: pass --image, --extension or --kernel-arg", providers.ErrInvalidSpec))
+			return
+		}
+
+		fmt.Printf("Creating cluster %s with provider %s in region %s\n", clusterName, provider, region)
+		for _, pool := range pools {
+			fmt.Printf("  pool %s: %d %s nodes of size %s\n", pool.Name, pool.Count, pool.Role, pool.Size)
+		}
+
+		// Plan cluster networks that do not overlap the home or other cloud clusters
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+		planner, err := cidrPlanner(st, supernet)
+		if err != nil {
+			fail("Error planning cluster networks", err)
+			return
+		}
+		vpcSpec := providers.VPCSpec{Enabled: useVPC || vpcName != "", Name: vpcName, Subnet: vpcSubnet}
+		var nodeCIDRs []string
+		if vpcSpec.Enabled {
+			if vpcSubnet == "" {
+				vpcSubnet, err = planVPCSubnet(st, planner, clusterName)
+				if err != nil {
+					fail("Error planning cluster networks", err)
+					return
+				}
+				vpcSpec.Subnet = vpcSubnet
+			}
+			nodeCIDRs = []string{vpcSubnet}
+		}
+		networks, err := planClusterNetworks(planner, clusterName, podCIDR, serviceCIDR, nodeCIDRs)
+		if err != nil {
+			fail("Error planning cluster networks", err)
+			return
+		}
+		pods, services, nodes := networks.Strings()
+		fmt.Printf("Using pod CIDR %s and service CIDR %s\n", pods[0], services[0])
+
+		// Reuse the secrets of an earlier attempt so configured nodes keep their identity
+		secrets := ""
+		if cluster, ok := st.Cluster(clusterName); ok {
+			secrets = cluster.Secrets
+		}
+		if secrets == "" {
+			generated, err := talos.GenerateSecrets(talosVersion)
+			if err != nil {
+				fail("Error generating cluster secrets", err)
+				return
+			}
+			secrets = string(generated)
+		}
 
-		fmt.Printf("Creating cluster with provider %s in region %s with %d nodes of size %s\n",
-			provider, region, nodeCount, nodeSize)
+		// Customized images are built by the Image Factory from a schematic
+		schematicID, err := registerSchematic(cmd.Context(), factoryURL, extensions, kernelArgs)
+		if err != nil {
+			fail("Error creating Image Factory schematic", err)
+			return
+		}
+		if schematicID != "" {
+			fmt.Printf("Using Image Factory schematic %s\n", schematicID)
+		}
 
 		// Initialize provider configuration
 		providerConfig := providers.Provider{
@@ -48,9 +213,55 @@ var createCmd = &cobra.Command{
 
 		// Create cluster specification
 		spec := providers.ClusterSpec{
-			NodeCount:    nodeCount,
-			NodeSize:     nodeSize,
+			Name:         clusterName,
 			TalosVersion: talosVersion,
+			PodCIDR:      pods[0],
+			ServiceCIDR:  services[0],
+			Pools:        pools,
+			Image:        image,
+			Extensions:   extensions,
+			KernelArgs:   kernelArgs,
+			SchematicID:  schematicID,
+			ImageFactory: factoryURL,
+			Firewall: providers.FirewallSpec{
+				Disabled:     noFirewall,
+				AdminCIDRs:   adminCIDRs,
+				IngressPorts: ingressPorts,
+				IngressCIDRs: ingressCIDRs,
+			},
+			VPC:          vpcSpec,
+			LoadBalancer: loadBalancer,
+			IngressIP:    ingressIP,
+			Placement:    placement,
+		}
+		if err := spec.Validate(); err != nil {
+			fail("Error", err)
+			return
+		}
+
+		clusterConfig := talos.ClusterConfig{
+			Name:              clusterName,
+			Endpoint:          endpoint,
+			TalosVersion:      talosVersion,
+			KubernetesVersion: kubernetesVersion,
+			PodCIDR:           pods[0],
+			ServiceCIDR:       services[0],
+		}
+		if vpcSpec.Enabled {
+			clusterConfig.PrivateSubnet = vpcSubnet
+		}
+		if schematicID != "" {
+			clusterConfig.InstallImage = imagefactory.NewClient(factoryURL).InstallerImage(schematicID, talosVersion)
+		}
+
+		// With a known endpoint the machine configs are injected as user data.
+		// Otherwise nodes boot into maintenance mode and are configured once their
+		// addresses are known.
+		if endpoint != "" {
+			if err := injectPoolConfigs(&spec, clusterConfig, []byte(secrets)); err != nil {
+				fail("Error generating machine configs", err)
+				return
+			}
 		}
 
 		// Create provider factory
@@ -59,14 +270,76 @@ var createCmd = &cobra.Command{
 		// Create cloud provider
 		cloudProvider, err := factory.CreateProvider(providerConfig)
 		if err != nil {
-			fmt.Printf("Error creating provider: %v\n", err)
+			fail("Error creating provider", err)
+			return
+		}
+
+		// Show what the cluster will cost and check it against the budget
+		// before paying for it
+		var resizable []string
+		for _, pool := range spec.Pools {
+			resizable = append(resizable, pool.Name)
+		}
+		if err := enforceBudget(st, provider, cloudProvider, &spec, resizable, apiKey); err != nil {
+			fail("Error", err)
 			return
 		}
 
 		// Create the cluster
 		err = cloudProvider.CreateCluster(spec)
 		if err != nil {
-			fmt.Printf("Error creating cluster: %v\n", err)
+			fail("Error creating cluster", err)
+			return
+		}
+
+		// Record the cluster, its networks and secrets before bootstrapping so a
+		// failed bootstrap can be retried
+		err = stateStore(cmd).Update(func(st *state.State) error {
+			cluster, ok := st.Cluster(clusterName)
+			if !ok {
+				cluster = &state.Cluster{Name: clusterName}
+			}
+			cluster.Provider = provider
+			cluster.Region = region
+			cluster.DeletedAt = time.Time{}
+			cluster.Networks = state.Networks{Pods: pods, Services: services, Nodes: nodes}
+			cluster.Pools = recordPools(spec.Pools)
+			cluster.Image = image
+			cluster.Extensions = extensions
+			cluster.KernelArgs = kernelArgs
+			cluster.Schematic = schematicID
+			cluster.ImageFactory = factoryURL
+			cluster.Firewall = state.Firewall{
+				Disabled:     noFirewall,
+				AdminCIDRs:   adminCIDRs,
+				IngressPorts: ingressPorts,
+				IngressCIDRs: ingressCIDRs,
+			}
+			cluster.VPC = state.VPC{Enabled: vpcSpec.Enabled, Name: vpcName, Subnet: vpcSubnet}
+			cluster.LoadBalancer = loadBalancer
+			cluster.IngressIP = ingressIP
+			cluster.Placement = placement
+			cluster.TalosVersion = talosVersion
+			cluster.KubernetesVersion = kubernetesVersion
+			cluster.ControlPlaneEndpoint = endpoint
+			cluster.Secrets = secrets
+			st.PutCluster(cluster)
+			return nil
+		})
+		if err != nil {
+			fail("Error saving cluster state", err)
+			return
+		}
+
+		if skipBootstrap {
+			fmt.Println("Cluster created successfully, skipping bootstrap")
+			return
+		}
+
+		fmt.Println("Instances are running, bootstrapping the cluster")
+		if err := bootstrapCluster(cmd, cloudProvider, clusterConfig, spec.Pools, []byte(secrets), ""); err != nil {
+			fail("Error bootstrapping cluster", err)
+			fmt.Printf("Retry with: talos-autoextender bootstrap --name %s\n", clusterName)
 			return
 		}
 
@@ -74,205 +347,2803 @@ var createCmd = &cobra.Command{
 	},
 }
 
-var deleteCmd = &cobra.Command{
-	Use:   "delete",
-	Short: "Delete a cloud cluster extension",
-	Long:  `Delete an existing Talos cluster in the cloud.`,
+var bootstrapCmd = &cobra.Command{
+	Use:   "bootstrap",
+	Short: "Bootstrap a created cloud cluster",
+	Long: `Bootstrap the Talos cluster of a created cloud cluster extension.
+
+This waits for the Talos API on every node, applies machine configs to nodes
+in maintenance mode, bootstraps etcd on one control plane node and waits for
+etcd and the Kubernetes API to become healthy. It is run by create and can be
+re-run if bootstrapping did not finish.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		provider, _ := cmd.Flags().GetString("provider")
-		region, _ := cmd.Flags().GetString("region")
 		clusterName, _ := cmd.Flags().GetString("name")
 		apiKey, _ := cmd.Flags().GetString("api-key")
 
-		fmt.Printf("Deleting cluster %s with provider %s in region %s\n",
-			clusterName, provider, region)
-
-		// Initialize provider configuration
-		providerConfig := providers.Provider{
-			Name:   provider,
-			Region: region,
-			Credentials: map[string]string{
-				"api_key": apiKey,
-			},
+		cluster, err := clusterWithSecrets(cmd, clusterName)
+		if err != nil {
+			fail("Error", err)
+			return
 		}
 
-		// Create provider factory
-		factory := providers.NewProviderFactory()
-
-		// Create cloud provider
-		cloudProvider, err := factory.CreateProvider(providerConfig)
+		cloudProvider, err := clusterProvider(cluster, apiKey)
 		if err != nil {
-			fmt.Printf("Error creating provider: %v\n", err)
+			fail("Error creating provider", err)
 			return
 		}
 
-		// Delete the cluster
-		err = cloudProvider.DeleteCluster(clusterName)
-		if err != nil {
-			fmt.Printf("Error deleting cluster: %v\n", err)
+		if err := bootstrapCluster(cmd, cloudProvider, clusterTalosConfig(cluster), clusterPools(cluster), []byte(cluster.Secrets), ""); err != nil {
+			fail("Error bootstrapping cluster", err)
 			return
 		}
 
-		fmt.Println("Cluster deleted successfully")
+		fmt.Println("Cluster bootstrapped successfully")
 	},
 }
 
-var statusCmd = &cobra.Command{
-	Use:   "status",
-	Short: "Get the status of a cloud cluster extension",
-	Long:  `Get the status of an existing Talos cluster in the cloud.`,
+var scaleCmd = &cobra.Command{
+	Use:   "scale",
+	Short: "Scale a node pool of a cloud cluster",
+	Long: `Set the node count of a node pool, or add a pool when --size is given.
+
+New nodes get the pool's machine config, including its node labels, taints
+and patches, and join the cluster. Workers are removed from the highest index
+down without being drained first. Control plane pools can only grow.
+
+With a budget set by cost budget, scaling is refused when the clusters would
+cost more than it. In downsize mode a pool added with --size gets a cheaper
+size instead; existing pools keep theirs.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		provider, _ := cmd.Flags().GetString("provider")
-		region, _ := cmd.Flags().GetString("region")
 		clusterName, _ := cmd.Flags().GetString("name")
 		apiKey, _ := cmd.Flags().GetString("api-key")
+		poolName, _ := cmd.Flags().GetString("pool")
+		count, _ := cmd.Flags().GetInt("count")
+		role, _ := cmd.Flags().GetString("role")
+		size, _ := cmd.Flags().GetString("size")
 
-		fmt.Printf("Getting status for cluster %s with provider %s in region %s\n",
-			clusterName, provider, region)
+		cluster, err := clusterWithSecrets(cmd, clusterName)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
 
-		// Initialize provider configuration
-		providerConfig := providers.Provider{
-			Name:   provider,
-			Region: region,
-			Credentials: map[string]string{
-				"api_key": apiKey,
-			},
+		spec := clusterSpec(cluster)
+		pool, ok := spec.Pool(poolName)
+		var resizable []string
+		if !ok {
+			if size == "" {
+				fail("Error", fmt.Errorf("cluster %s has no pool %s; give --size to add it", cluster.Name, poolName))
+				return
+			}
+			spec.Pools = append(spec.Pools, providers.NodePool{Name: poolName, Role: role, Size: size})
+			pool = &spec.Pools[len(spec.Pools)-1]
+			resizable = []string{poolName}
 		}
+		fmt.Printf("Scaling pool %s of cluster %s from %d to %d nodes\n", pool.Name, cluster.Name, pool.Count, count)
+		pool.Count = count
 
-		// Create provider factory
-		factory := providers.NewProviderFactory()
+		if err := spec.Validate(); err != nil {
+			fail("Error", err)
+			return
+		}
 
-		// Create cloud provider
-		cloudProvider, err := factory.CreateProvider(providerConfig)
+		clusterConfig := clusterTalosConfig(cluster)
+		if clusterConfig.Endpoint != "" {
+			if err := injectPoolConfigs(&spec, clusterConfig, []byte(cluster.Secrets)); err != nil {
+				fail("Error generating machine configs", err)
+				return
+			}
+		}
+
+		cloudProvider, err := clusterProvider(cluster, apiKey)
 		if err != nil {
-			fmt.Printf("Error creating provider: %v\n", err)
+			fail("Error creating provider", err)
 			return
 		}
 
-		// Get cluster status
-		status, err := cloudProvider.GetClusterStatus(clusterName)
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+		if err := enforceBudget(st, cluster.Provider, cloudProvider, &spec, resizable, apiKey); err != nil {
+			fail("Error", err)
+			return
+		}
+
+		if err := cloudProvider.UpdateCluster(spec); err != nil {
+			fail("Error scaling cluster", err)
+			return
+		}
+
+		err = stateStore(cmd).Update(func(st *state.State) error {
+			recorded, ok := st.Cluster(cluster.Name)
+			if !ok {
+				return fmt.Errorf("cluster %s is not recorded", cluster.Name)
+			}
+			recorded.Pools = recordPools(spec.Pools)
+			return nil
+		})
+		if err != nil {
+			fail("Error saving cluster state", err)
+			return
+		}
+
+		fmt.Println("Instances are running, configuring new nodes")
+		if err := bootstrapCluster(cmd, cloudProvider, clusterConfig, spec.Pools, []byte(cluster.Secrets), ""); err != nil {
+			fail("Error configuring nodes", err)
+			fmt.Printf("Retry with: talos-autoextender bootstrap --name %s\n", cluster.Name)
+			return
+		}
+
+		fmt.Printf("Pool %s scaled to %d nodes\n", pool.Name, count)
+	},
+}
+
+var kubeconfigCmd = &cobra.Command{
+	Use:   "kubeconfig",
+	Short: "Get admin credentials for a cloud cluster's Kubernetes API",
+	Long: `Generate an admin kubeconfig for a cloud cluster from its stored secrets.
+
+The kubeconfig is printed, written to --output, or merged into ~/.kube/config
+(or the first file in KUBECONFIG) with --merge, using a context named after
+the cluster.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		clusterName, _ := cmd.Flags().GetString("name")
+		merge, _ := cmd.Flags().GetBool("merge")
+		path, _ := cmd.Flags().GetString("path")
+		output, _ := cmd.Flags().GetString("output")
+		ttl, _ := cmd.Flags().GetDuration("ttl")
+
+		cluster, err := clusterWithSecrets(cmd, clusterName)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
+
+		kubeconfig, err := talos.AdminKubeconfig(cluster.Name, cluster.ControlPlaneEndpoint, []byte(cluster.Secrets), ttl)
 		if err != nil {
-			fmt.Printf("Error getting cluster status: %v\n", err)
+			fail("Error generating kubeconfig", err)
 			return
 		}
 
-		fmt.Printf("Cluster %s status:\n", clusterName)
-		fmt.Printf("  State: %s\n", status.State)
-		fmt.Printf("  Nodes: %d (%d ready)\n", status.NodeCount, status.ReadyNodeCount)
-		if status.Error != "" {
-			fmt.Printf("  Error: %s\n", status.Error)
+		switch {
+		case merge:
+			if path == "" {
+				if path, err = talos.DefaultKubeconfigPath(); err != nil {
+					fail("Error locating kubeconfig", err)
+					return
+				}
+			}
+			if err := talos.MergeKubeconfigFile(path, kubeconfig); err != nil {
+				fail("Error merging kubeconfig", err)
+				return
+			}
+			fmt.Printf("Merged context %s into %s\n", cluster.Name, path)
+		case output != "":
+			if err := talos.WriteCredentials(output, kubeconfig); err != nil {
+				fail("Error writing kubeconfig", err)
+				return
+			}
+			fmt.Printf("Kubeconfig written to %s\n", output)
+		default:
+			fmt.Print(string(kubeconfig))
 		}
 	},
 }
 
-var connectCmd = &cobra.Command{
-	Use:   "connect",
-	Short: "Connect a cloud cluster to home cluster",
-	Long:  `Connect a cloud cluster to your home Talos cluster using KubeSpan.`,
+var talosconfigCmd = &cobra.Command{
+	Use:   "talosconfig",
+	Short: "Get admin credentials for a cloud cluster's Talos API",
+	Long: `Generate an admin talosconfig for a cloud cluster from its stored secrets.
+
+The talosconfig is printed, written to --output, or merged into the default
+talosconfig (~/.talos/config or TALOSCONFIG) with --merge, using a context
+named after the cluster.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		homeEndpoint, _ := cmd.Flags().GetString("home-endpoint")
-		cloudEndpoint, _ := cmd.Flags().GetString("cloud-endpoint")
 		clusterName, _ := cmd.Flags().GetString("name")
+		merge, _ := cmd.Flags().GetBool("merge")
+		path, _ := cmd.Flags().GetString("path")
+		output, _ := cmd.Flags().GetString("output")
+
+		cluster, err := clusterWithSecrets(cmd, clusterName)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
 
-		fmt.Printf("Connecting cluster %s at %s to home cluster at %s\n",
-			clusterName, cloudEndpoint, homeEndpoint)
+		if len(cluster.ControlPlanes) == 0 {
+			fmt.Printf("Warning: cluster %s has no recorded control plane addresses, set endpoints with talosctl config endpoint\n", cluster.Name)
+		}
 
-		// Create KubeSpan manager
-		manager := network.NewKubeSpanManager(homeEndpoint)
+		talosconfig, err := talos.AdminTalosconfig(cluster.Name, cluster.ControlPlanes, []byte(cluster.Secrets))
+		if err != nil {
+			fail("Error generating talosconfig", err)
+			return
+		}
 
-		// Validate the home endpoint
-		if err := manager.ValidateEndpoint(); err != nil {
-			fmt.Printf("Invalid home endpoint: %v\n", err)
+		if merge {
+			if path == "" {
+				if path, err = talos.DefaultTalosconfigPath(); err != nil {
+					fail("Error locating talosconfig", err)
+					return
+				}
+			}
+			if err := talos.MergeTalosconfig(path, talosconfig); err != nil {
+				fail("Error merging talosconfig", err)
+				return
+			}
+			fmt.Printf("Merged context %s into %s\n", cluster.Name, path)
 			return
 		}
 
-		// Add the cloud cluster
-		if err := manager.AddCloudCluster(clusterName, cloudEndpoint); err != nil {
-			fmt.Printf("Error adding cloud cluster: %v\n", err)
+		data, err := talosconfig.Bytes()
+		if err != nil {
+			fail("Error encoding talosconfig", err)
 			return
 		}
 
-		fmt.Println("Cloud cluster connected successfully")
+		if output != "" {
+			if err := talos.WriteCredentials(output, data); err != nil {
+				fail("Error writing talosconfig", err)
+				return
+			}
+			fmt.Printf("Talosconfig written to %s\n", output)
+			return
+		}
+
+		fmt.Print(string(data))
 	},
 }
 
-var dnsCmd = &cobra.Command{
-	Use:   "dns",
-	Short: "Manage DNS records for the cluster",
-	Long:  `Manage DNS records for exposing services from your home cluster.`,
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade Talos on a cloud cluster",
+	Long: `Upgrade the Talos version of a cloud cluster one node at a time.
+
+Control plane nodes are upgraded first, each only if etcd keeps quorum while
+it reboots. After each node the upgrade waits for the node to come back on the
+new version with healthy etcd and a Ready Kubernetes node. The upgrade halts
+at the first node that fails these checks and reports the state of every node.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		provider, _ := cmd.Flags().GetString("provider")
-		domain, _ := cmd.Flags().GetString("domain")
-		recordName, _ := cmd.Flags().GetString("record")
-		recordType, _ := cmd.Flags().GetString("type")
-		content, _ := cmd.Flags().GetString("content")
+		clusterName, _ := cmd.Flags().GetString("name")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		talosVersion, _ := cmd.Flags().GetString("talos-version")
+		image, _ := cmd.Flags().GetString("image")
+		preserve, _ := cmd.Flags().GetBool("preserve")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
 
-		fmt.Printf("Managing DNS record %s.%s of type %s with provider %s\n",
-			recordName, domain, recordType, provider)
+		cluster, err := clusterWithSecrets(cmd, clusterName)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
 
-		// Create DNS manager
-		manager := dns.NewDNSManager(provider, domain)
+		cloudProvider, err := clusterProvider(cluster, apiKey)
+		if err != nil {
+			fail("Error creating provider", err)
+			return
+		}
 
-		// Validate configuration
-		if err := manager.ValidateConfig(); err != nil {
-			fmt.Printf("Invalid DNS configuration: %v\n", err)
+		status, err := cloudProvider.GetClusterStatus(cluster.Name)
+		if err != nil {
+			fail("Error getting cluster status", err)
 			return
 		}
+		nodes := bootstrapNodes(status, nil)
 
-		// Upsert DNS record
-		if err := manager.UpsertRecord(recordName, recordType, content); err != nil {
-			fmt.Printf("Error upserting DNS record: %v\n", err)
+		talosconfig, err := talos.AdminTalosconfig(cluster.Name, cluster.ControlPlanes, []byte(cluster.Secrets))
+		if err != nil {
+			fail("Error generating talosconfig", err)
 			return
 		}
 
-		fmt.Println("DNS record updated successfully")
+		upgrader := talos.NewUpgrader(talos.NewConfigConnector(talosconfig), talosVersion)
+		if image == "" {
+			image = clusterInstallerImage(cluster, talosVersion)
+		}
+		if image != "" {
+			upgrader.Image = image
+		}
+		upgrader.Preserve = preserve
+		upgrader.Timeout = timeout
+		upgrader.Report = func(node, message string) {
+			fmt.Printf("[%s] %s\n", node, message)
+		}
+
+		fmt.Printf("Upgrading cluster %s to Talos %s using %s\n", cluster.Name, upgrader.Version, upgrader.Image)
+		report, err := upgrader.Run(cmd.Context(), nodes)
+		printUpgradeReport(report)
+		if err != nil {
+			fail("Error", err)
+			fmt.Println("Fix the failing node and re-run the upgrade; upgraded nodes are skipped")
+			return
+		}
+
+		err = stateStore(cmd).Update(func(st *state.State) error {
+			if recorded, ok := st.Cluster(cluster.Name); ok {
+				recorded.TalosVersion = upgrader.Version
+			}
+			return nil
+		})
+		if err != nil {
+			fail("Error saving cluster state", err)
+			return
+		}
+
+		fmt.Println("Cluster upgraded successfully")
 	},
 }
 
-func init() {
-	// Create command flags
-	createCmd.Flags().String("provider", "linode", "Cloud provider to use (linode, hetzner)")
-	createCmd.Flags().String("region", "us-east", "Region to deploy the cluster")
-	createCmd.Flags().Int("nodes", 3, "Number of nodes in the cluster")
-	createCmd.Flags().String("size", "g6-standard-2", "Size/type of the nodes")
-	createCmd.Flags().String("talos-version", "v1.6.0", "Talos version to use")
-	createCmd.Flags().String("api-key", "", "API key for the cloud provider")
+var upgradeKubernetesCmd = &cobra.Command{
+	Use:   "upgrade-k8s",
+	Short: "Upgrade Kubernetes on a cloud cluster",
+	Long: `Upgrade the Kubernetes version of a cloud cluster one node at a time.
 
-	// Delete command flags
-	deleteCmd.Flags().String("provider", "linode", "Cloud provider to use (linode, hetzner)")
-	deleteCmd.Flags().String("region", "us-east", "Region of the cluster")
-	deleteCmd.Flags().String("name", "", "Name of the cluster to delete")
-	deleteCmd.Flags().String("api-key", "", "API key for the cloud provider")
+Before upgrading, the API server is checked for requests to deprecated APIs
+that are removed in the target version; the upgrade is refused if any are in
+use unless --force is given. Control plane nodes are updated first: the
+kubelet and control plane static pod images in each node's machine config are
+retagged and the node waits for a healthy kubelet, a Ready Kubernetes node and,
+on control planes, a healthy Kubernetes API before moving on.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		clusterName, _ := cmd.Flags().GetString("name")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		version, _ := cmd.Flags().GetString("to")
+		force, _ := cmd.Flags().GetBool("force")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
 
-	// Status command flags
-	statusCmd.Flags().String("provider", "linode", "Cloud provider to use (linode, hetzner)")
-	statusCmd.Flags().String("region", "us-east", "Region of the cluster")
-	statusCmd.Flags().String("name", "", "Name of the cluster to get status for")
-	statusCmd.Flags().String("api-key", "", "API key for the cloud provider")
+		cluster, err := clusterWithSecrets(cmd, clusterName)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
 
-	// Connect command flags
-	connectCmd.Flags().String("home-endpoint", "", "Endpoint of the home cluster (IP:PORT)")
-	connectCmd.Flags().String("cloud-endpoint", "", "Endpoint of the cloud cluster (IP:PORT)")
-	connectCmd.Flags().String("name", "", "Name of the cloud cluster")
+		creds, err := talos.AdminKubernetesCredentials(cluster.ControlPlaneEndpoint, []byte(cluster.Secrets), time.Hour)
+		if err != nil {
+			fail("Error generating Kubernetes credentials", err)
+			return
+		}
 
-	// DNS command flags
-	dnsCmd.Flags().String("provider", "cloudflare", "DNS provider to use")
-	dnsCmd.Flags().String("domain", "", "Domain to manage records for")
-	dnsCmd.Flags().String("record", "", "Record name (e.g., www)")
-	dnsCmd.Flags().String("type", "A", "Record type (A, CNAME, etc.)")
-	dnsCmd.Flags().String("content", "", "Record content (e.g., IP address)")
+		removed, err := talos.RemovedAPIs(cmd.Context(), creds, version)
+		if err != nil {
+			fail("Error checking deprecated API usage", err)
+			return
+		}
+		if len(removed) > 0 {
+			fmt.Printf("The following APIs are in use but removed in Kubernetes %s:\n", version)
+			for _, api := range removed {
+				fmt.Printf("  %s\n", api)
+			}
+			if !force {
+				fmt.Println("Migrate these workloads first, or re-run with --force to upgrade anyway")
+				exitCode = exitFailure
+				return
+			}
+			fmt.Println("Continuing because --force is set")
+		}
 
-	// Add commands to root command
-	rootCmd.AddCommand(createCmd)
-	rootCmd.AddCommand(deleteCmd)
-	rootCmd.AddCommand(statusCmd)
-	rootCmd.AddCommand(connectCmd)
-	rootCmd.AddCommand(dnsCmd)
+		cloudProvider, err := clusterProvider(cluster, apiKey)
+		if err != nil {
+			fail("Error creating provider", err)
+			return
+		}
+
+		status, err := cloudProvider.GetClusterStatus(cluster.Name)
+		if err != nil {
+			fail("Error getting cluster status", err)
+			return
+		}
+
+		talosconfig, err := talos.AdminTalosconfig(cluster.Name, cluster.ControlPlanes, []byte(cluster.Secrets))
+		if err != nil {
+			fail("Error generating talosconfig", err)
+			return
+		}
+
+		apiHealthy, err := talos.NewKubernetesHealthCheck(creds.Endpoint, creds.CA)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
+
+		upgrader := talos.NewKubernetesUpgrader(talos.NewConfigConnector(talosconfig), apiHealthy, version)
+		upgrader.Timeout = timeout
+		upgrader.Report = func(node, message string) {
+			fmt.Printf("[%s] %s\n", node, message)
+		}
+
+		fmt.Printf("Upgrading cluster %s to Kubernetes %s\n", cluster.Name, upgrader.Version)
+		report, err := upgrader.Run(cmd.Context(), bootstrapNodes(status, nil))
+		printUpgradeReport(report)
+		if err != nil {
+			fail("Error", err)
+			fmt.Println("Fix the failing node and re-run the upgrade; upgraded nodes are skipped")
+			return
+		}
+
+		err = stateStore(cmd).Update(func(st *state.State) error {
+			if recorded, ok := st.Cluster(cluster.Name); ok {
+				recorded.KubernetesVersion = upgrader.Version
+			}
+			return nil
+		})
+		if err != nil {
+			fail("Error saving cluster state", err)
+			return
+		}
+
+		fmt.Println("Kubernetes upgraded successfully")
+	},
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up the etcd data of a cloud cluster",
+	Long: `Take an etcd snapshot of a cloud cluster through the Talos API.
+
+Snapshots are stored below --storage, a local directory or an S3-compatible
+bucket given as s3://bucket/prefix. S3 credentials are read from
+AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. After each backup, snapshots
+beyond --keep or older than --max-age are deleted; the newest snapshot is
+always kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		clusterName, _ := cmd.Flags().GetString("name")
+		keep, _ := cmd.Flags().GetInt("keep")
+		maxAge, _ := cmd.Flags().GetDuration("max-age")
+
+		cluster, err := clusterWithSecrets(cmd, clusterName)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
+
+		store, err := backupStore(cmd)
+		if err != nil {
+			fail("Error opening backup storage", err)
+			return
+		}
+
+		snapshot, err := takeSnapshot(cmd.Context(), cluster, store)
+		if err != nil {
+			fail("Error taking etcd snapshot", err)
+			return
+		}
+		fmt.Printf("Saved snapshot %s (%d bytes) to %s\n", snapshot.Name, snapshot.Size, store.Location())
+
+		policy := backup.RetentionPolicy{Keep: keep, MaxAge: maxAge}
+		pruned, err := backup.Prune(cmd.Context(), store, cluster.Name, policy, time.Now())
+		for _, expired := range pruned {
+			fmt.Printf("Deleted expired snapshot %s\n", expired.Name)
+		}
+		if err != nil {
+			fail("Error applying retention policy", err)
+			return
+		}
+	},
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the etcd snapshots of a cloud cluster",
+	Run: func(cmd *cobra.Command, args []string) {
+		clusterName, _ := cmd.Flags().GetString("name")
+
+		store, err := backupStore(cmd)
+		if err != nil {
+			fail("Error opening backup storage", err)
+			return
+		}
+
+		snapshots, err := backup.List(cmd.Context(), store, clusterName)
+		if err != nil {
+			fail("Error listing snapshots", err)
+			return
+		}
+		if len(snapshots) == 0 {
+			fmt.Printf("No snapshots of cluster %s in %s\n", clusterName, store.Location())
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tCREATED\tSIZE")
+		for _, snapshot := range snapshots {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", snapshot.Name, snapshot.CreatedAt.Format(time.RFC3339), snapshot.Size)
+		}
+		w.Flush()
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Rebuild a cloud cluster from an etcd snapshot",
+	Long: `Rebuild a deleted cloud cluster from an etcd snapshot.
+
+The cluster is created again with its recorded provider, region, networks,
+versions and secrets, so existing service account tokens and certificates stay
+valid. Instead of a fresh bootstrap, etcd on the first control plane node is
+recovered from the snapshot and the other control plane nodes join it.
+
+The cluster must not have any instances left; delete it first. The Kubernetes
+API endpoint is kept if it was set explicitly at creation, otherwise it moves
+to the first new control plane node unless --endpoint is given. Like create,
+the restored cluster is checked against the cost budget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		clusterName, _ := cmd.Flags().GetString("name")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		snapshotName, _ := cmd.Flags().GetString("snapshot")
+		endpoint, _ := cmd.Flags().GetString("endpoint")
+
+		cluster, err := clusterWithSecrets(cmd, clusterName)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
+
+		store, err := backupStore(cmd)
+		if err != nil {
+			fail("Error opening backup storage", err)
+			return
+		}
+
+		snapshot, err := backup.Find(cmd.Context(), store, cluster.Name, snapshotName)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
+
+		cloudProvider, err := clusterProvider(cluster, apiKey)
+		if err != nil {
+			fail("Error creating provider", err)
+			return
+		}
+
+		status, err := cloudProvider.GetClusterStatus(cluster.Name)
+		if err != nil {
+			fail("Error getting cluster status", err)
+			return
+		}
+		if len(status.Nodes) > 0 {
+			fail("Error", fmt.Errorf("cluster %s still has %d instances; delete it before restoring", cluster.Name, len(status.Nodes)))
+			return
+		}
+
+		fmt.Printf("Downloading snapshot %s from %s\n", snapshot.Name, store.Location())
+		snapshotPath, err := downloadSnapshot(cmd.Context(), store, snapshot)
+		if err != nil {
+			fail("Error downloading snapshot", err)
+			return
+		}
+		defer os.Remove(snapshotPath)
+
+		spec, err := restoreSpec(cmd, cluster)
+		if err != nil {
+			fail("Error", err)
+			return
+		}
+
+		// A restored cluster is paid for like a new one
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+		var resizable []string
+		for _, pool := range spec.Pools {
+			resizable = append(resizable, pool.Name)
+		}
+		if err := enforceBudget(st, cluster.Provider, cloudProvider, &spec, resizable, apiKey); err != nil {
+			fail("Error", err)
+			return
+		}
+
+		clusterConfig := clusterTalosConfig(cluster)
+		clusterConfig.Endpoint = restoreEndpoint(cluster, endpoint)
+
+		if clusterConfig.Endpoint != "" {
+			if err := injectPoolConfigs(&spec, clusterConfig, []byte(cluster.Secrets)); err != nil {
+				fail("Error generating machine configs", err)
+				return
+			}
+		}
+
+		fmt.Printf("Recreating cluster %s with %d nodes in %d pools\n", spec.Name, spec.NodeCount(), len(spec.Pools))
+		if err := cloudProvider.CreateCluster(spec); err != nil {
+			fail("Error creating cluster", err)
+			return
+		}
+
+		err = stateStore(cmd).Update(func(st *state.State) error {
+			recorded, ok := st.Cluster(cluster.Name)
+			if !ok {
+				return fmt.Errorf("cluster %s is not recorded", cluster.Name)
+			}
+			recorded.Pools = recordPools(spec.Pools)
+			recorded.Image = spec.Image
+			recorded.ControlPlaneEndpoint = clusterConfig.Endpoint
+			recorded.ControlPlanes = nil
+			recorded.BootstrappedAt = time.Time{}
+			recorded.DeletedAt = time.Time{}
+			return nil
+		})
+		if err != nil {
+			fail("Error saving cluster state", err)
+			return
+		}
+
+		fmt.Println("Instances are running, recovering etcd from the snapshot")
+		if err := bootstrapCluster(cmd, cloudProvider, clusterConfig, spec.Pools, []byte(cluster.Secrets), snapshotPath); err != nil {
+			fail("Error recovering cluster", err)
+			return
+		}
+
+		fmt.Printf("Cluster %s restored from %s\n", cluster.Name, snapshot.Name)
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a cloud cluster extension",
+	Long: `Delete an existing Talos cluster in the cloud.
+
+Recorded clusters are deleted with the provider and region in the state file
+and marked deleted there; the record is kept so restore can rebuild the
+cluster from a backup. --provider and --region are only used for clusters that
+are not recorded.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, _ := cmd.Flags().GetString("provider")
+		region, _ := cmd.Flags().GetString("region")
+		clusterName, _ := cmd.Flags().GetString("name")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+		cluster, recorded := st.Cluster(clusterName)
+		if recorded && cluster.Provider != "" {
+			provider, region = cluster.Provider, cluster.Region
+		}
+
+		fmt.Printf("Deleting cluster %s with provider %s in region %s\n",
+			clusterName, provider, region)
+
+		// Initialize provider configuration
+		providerConfig := providers.Provider{
+			Name:   provider,
+			Region: region,
+			Credentials: map[string]string{
+				"api_key": apiKey,
+			},
+		}
+
+		// Create provider factory
+		factory := providers.NewProviderFactory()
+
+		// Create cloud provider
+		cloudProvider, err := factory.CreateProvider(providerConfig)
+		if err != nil {
+			fail("Error creating provider", err)
+			return
+		}
+
+		// Delete the cluster
+		err = cloudProvider.DeleteCluster(clusterName)
+		if err != nil {
+			fail("Error deleting cluster", err)
+			return
+		}
+
+		if recorded {
+			err = stateStore(cmd).Update(func(st *state.State) error {
+				if cluster, ok := st.Cluster(clusterName); ok {
+					cluster.DeletedAt = time.Now().UTC()
+					cluster.ControlPlanes = nil
+					cluster.BootstrappedAt = time.Time{}
+				}
+				return nil
+			})
+			if err != nil {
+				fail("Error saving cluster state", err)
+				return
+			}
+		}
+
+		fmt.Println("Cluster deleted successfully")
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Get the status of a cloud cluster extension",
+	Long: `Get the status of an existing Talos cluster in the cloud.
+
+Every node is listed with its instance details. For clusters recorded by
+create or import, the Talos version and Kubernetes Ready condition are queried
+from the nodes through the Talos API. Use -o json or -o yaml for
+machine-readable output.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, _ := cmd.Flags().GetString("provider")
+		region, _ := cmd.Flags().GetString("region")
+		clusterName, _ := cmd.Flags().GetString("name")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		output, _ := cmd.Flags().GetString("output")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		if output != "table" && output != "json" && output != "yaml" {
+			fail("Error", fmt.Errorf("unsupported output format %q (table, json, yaml)", output))
+			return
+		}
+
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+		cluster, recorded := st.Cluster(clusterName)
+
+		// Initialize provider configuration
+		providerConfig := providers.Provider{
+			Name:   provider,
+			Region: region,
+			Credentials: map[string]string{
+				"api_key": apiKey,
+			},
+		}
+
+		// Create provider factory
+		factory := providers.NewProviderFactory()
+
+		// Create cloud provider
+		cloudProvider, err := factory.CreateProvider(providerConfig)
+		if err != nil {
+			fail("Error creating provider", err)
+			return
+		}
+
+		// Get cluster status
+		status, err := cloudProvider.GetClusterStatus(clusterName)
+		if err != nil {
+			fail("Error getting cluster status", err)
+			return
+		}
+
+		if recorded {
+			status.Endpoints.Kubernetes = cluster.ControlPlaneEndpoint
+			if err := queryNodeStatus(cmd.Context(), cluster, &status, timeout); err != nil {
+				status.Error = err.Error()
+			}
+		}
+
+		if err := printClusterStatus(os.Stdout, output, status, time.Now()); err != nil {
+			fail("Error", err)
+		}
+	},
+}
+
+var connectCmd = &cobra.Command{
+	Use:   "connect",
+	Short: "Connect a cloud cluster to home cluster",
+	Long: `Connect a cloud cluster to your home Talos cluster using KubeSpan or,
+with --transport headscale, a Headscale-coordinated Tailscale overlay.
+
+For KubeSpan both sides may be given several endpoints (e.g. one IPv4 and one
+IPv6). IPv6 is preferred when both sides support it unless --ip-family says
+otherwise.
+
+The machine-config documents to apply to the cloud nodes are printed, or
+written to the --patch-out file. For Headscale they hold the node pre-auth key.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		homeEndpoints, _ := cmd.Flags().GetStringSlice("home-endpoint")
+		cloudEndpoints, _ := cmd.Flags().GetStringSlice("cloud-endpoint")
+		clusterName, _ := cmd.Flags().GetString("name")
+		transportName, _ := cmd.Flags().GetString("transport")
+		ipFamily, _ := cmd.Flags().GetString("ip-family")
+		patchOut, _ := cmd.Flags().GetString("patch-out")
+		headscaleURL, _ := cmd.Flags().GetString("headscale-url")
+		discoverMTU, _ := cmd.Flags().GetBool("discover-mtu")
+		kubespanMTU, _ := cmd.Flags().GetInt("kubespan-mtu")
+
+		fmt.Printf("Connecting cluster %s at %s to home cluster at %s using %s\n",
+			clusterName, strings.Join(cloudEndpoints, ","), strings.Join(homeEndpoints, ","), transportName)
+
+		// Pod-to-pod routing across the mesh requires non-overlapping ranges
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+		planner, err := cidrPlanner(st, "")
+		if err != nil {
+			fail("Error checking cluster networks", err)
+			return
+		}
+		networks, err := connectNetworks(cmd, st, clusterName)
+		if err != nil {
+			fail("Invalid cluster networks", fmt.Errorf("%w: %v", providers.ErrInvalidSpec, err))
+			return
+		}
+		if conflicts := planner.Conflicts(clusterName, networks); len(conflicts) > 0 {
+			msgs := make([]string, 0, len(conflicts))
+			for _, conflict := range conflicts {
+				msgs = append(msgs, conflict.String())
+			}
+			fail("Cluster networks overlap", fmt.Errorf("%w: %s", providers.ErrInvalidSpec, strings.Join(msgs, "; ")))
+			return
+		}
+
+		// Create the transport
+		var transport network.Transport
+		var manager *network.KubeSpanManager
+		switch transportName {
+		case "kubespan":
+			manager = network.NewKubeSpanManager(homeEndpoints...)
+			manager.Preference = network.FamilyPreference(ipFamily)
+
+			// Validate the home endpoint
+			if err := manager.ValidateEndpoint(); err != nil {
+				fail("Invalid home endpoint", fmt.Errorf("%w: %v", providers.ErrInvalidSpec, err))
+				return
+			}
+
+			// Size the KubeSpan MTU for the path to the cloud cluster
+			manager.MTU = kubespanMTU
+			if discoverMTU {
+				mtu, err := discoverKubeSpanMTU(cmd, cloudEndpoints, clusterName)
+				if err != nil {
+					fail("Error discovering path MTU", err)
+					return
+				}
+				manager.MTU = mtu
+			}
+
+			transport = network.NewKubeSpanTransport(manager, nil)
+		case "headscale":
+			apiKey := headscaleAPIKey(cmd)
+			if headscaleURL == "" || apiKey == "" {
+				fail("Error", fmt.Errorf("%w: headscale transport requires --headscale-url and --headscale-api-key", providers.ErrInvalidSpec))
+				return
+			}
+			transport = network.NewHeadscaleTransport(network.NewHeadscaleClient(headscaleURL, apiKey))
+		default:
+			fail("Error", fmt.Errorf("%w: unknown transport %q", providers.ErrInvalidSpec, transportName))
+			return
+		}
+
+		// Add the cloud cluster
+		nodeConfig, err := transport.Register(cmd.Context(), clusterName, cloudEndpoints)
+		if err != nil {
+			fail("Error adding cloud cluster", err)
+			return
+		}
+
+		// The Talos API listens on the host of the selected WireGuard peer
+		var selected, talosEndpoint string
+		var mtu int
+		if manager != nil {
+			mtu = manager.MTU
+			peer, _ := manager.CloudPeer(clusterName)
+			selected = peer.Selected.String()
+			talosEndpoint = net.JoinHostPort(peer.Selected.Host, strconv.Itoa(providers.TalosAPIPort))
+			fmt.Printf("Using %s endpoint %s\n", peer.Family, selected)
+		}
+
+		// Write the machine-config documents for the cloud nodes. They carry the
+		// Headscale pre-auth key, which is lost if they are not written.
+		if err := writePatch(patchOut, nodeConfig.YAML()); err != nil {
+			fail("Error writing node configuration", err)
+			return
+		}
+
+		// Record the connection so it can be monitored later
+		err = stateStore(cmd).Update(func(st *state.State) error {
+			cluster, ok := st.Cluster(clusterName)
+			if !ok {
+				cluster = &state.Cluster{Name: clusterName}
+			}
+			cluster.Transport = transport.Name()
+			if len(homeEndpoints) > 0 {
+				cluster.HomeEndpoint = homeEndpoints[0]
+			}
+			cluster.Endpoint = selected
+			cluster.Endpoints = cloudEndpoints
+			cluster.TalosEndpoint = talosEndpoint
+			cluster.HeadscaleURL = headscaleURL
+			cluster.KubeSpanMTU = mtu
+			pods, services, nodes := networks.Strings()
+			cluster.Networks = state.Networks{Pods: pods, Services: services, Nodes: nodes}
+			cluster.ConnectedAt = time.Now().UTC()
+			st.PutCluster(cluster)
+			return nil
+		})
+		if err != nil {
+			fail("Error saving cluster state", err)
+			return
+		}
+
+		fmt.Println("Cloud cluster connected successfully")
+	},
+}
+
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "Inspect the network between home and cloud clusters",
+	Long:  `Inspect the KubeSpan mesh between your home cluster and connected cloud clusters.`,
+}
+
+var networkStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show peer health for connected clusters",
+	Long: `Query the peer status of every connected cloud cluster and report handshake,
+endpoint, traffic and state per peer. KubeSpan clusters are queried through the
+Talos API, Headscale clusters through the Headscale API.
+
+With --failover the shared ingress IP of a cluster created with --ingress-ip
+is moved to another ingress node when the node holding it is not running or
+its peer is down.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		talosconfig, _ := cmd.Flags().GetString("talosconfig")
+		clusterName, _ := cmd.Flags().GetString("name")
+		watch, _ := cmd.Flags().GetBool("watch")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		failover, _ := cmd.Flags().GetBool("failover")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+
+		// Monitor every connected cluster through the transport it uses
+		monitor := network.NewPeerMonitor()
+		connector := talos.NewConnector(talosconfig)
+		for _, cluster := range st.ConnectedClusters() {
+			if clusterName != "" && cluster.Name != clusterName {
+				continue
+			}
+
+			switch cluster.Transport {
+			case "headscale":
+				client := network.NewHeadscaleClient(cluster.HeadscaleURL, headscaleAPIKey(cmd))
+				monitor.Add(cluster.Name, network.NewHeadscaleTransport(client))
+			default:
+				transport := network.NewKubeSpanTransport(network.NewKubeSpanManager(), connector)
+				transport.Track(cluster.Name, clusterTalosEndpoint(cluster))
+				monitor.Add(cluster.Name, transport)
+			}
+		}
+
+		reports := monitor.Check(cmd.Context())
+		if len(reports) == 0 {
+			fmt.Println("No connected clusters found")
+			return
+		}
+
+		if !watch {
+			printPeerReports(reports)
+			if failover {
+				failoverIngress(st, apiKey, reports)
+			}
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		err = monitor.Watch(ctx, interval, func(reports []network.ClusterPeerReport) {
+			fmt.Printf("--- %s\n", time.Now().Format(time.RFC3339))
+			printPeerReports(reports)
+			if failover {
+				failoverIngress(st, apiKey, reports)
+			}
+		})
+		if err != nil {
+			fail("Error watching peers", err)
+		}
+	},
+}
+
+var networkMTUCmd = &cobra.Command{
+	Use:   "mtu",
+	Short: "Discover the path MTU to cloud endpoints",
+	Long: `Probe the path MTU between this host and each endpoint with don't-fragment
+ICMP echo requests and compute the largest KubeSpan MTU that avoids blackholed
+packets, accounting for IPv4/IPv6, WireGuard and optional PPPoE overhead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		endpoints, _ := cmd.Flags().GetStringSlice("endpoint")
+
+		if _, err := discoverKubeSpanMTU(cmd, endpoints, ""); err != nil {
+			fail("Error discovering path MTU", err)
+		}
+	},
+}
+
+var networkCIDRsCmd = &cobra.Command{
+	Use:   "cidrs",
+	Short: "Show and record cluster network ranges",
+	Long: `Show the pod, service and node CIDRs recorded for the home cluster and every
+cloud cluster, and report any overlaps. Use the --home-* flags to record the
+home cluster's ranges; until then the Talos defaults are assumed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		homePods, _ := cmd.Flags().GetStringSlice("home-pods")
+		homeServices, _ := cmd.Flags().GetStringSlice("home-services")
+		homeNodes, _ := cmd.Flags().GetStringSlice("home-nodes")
+		supernet, _ := cmd.Flags().GetString("supernet")
+
+		var st *state.State
+		err := stateStore(cmd).Update(func(s *state.State) error {
+			if len(homePods)+len(homeServices)+len(homeNodes) > 0 {
+				if _, err := network.ParseNetworks(homePods, homeServices, homeNodes); err != nil {
+					return err
+				}
+				s.HomeNetworks = state.Networks{Pods: homePods, Services: homeServices, Nodes: homeNodes}
+			}
+			if supernet != "" {
+				if _, err := network.NewCIDRPlanner(supernet); err != nil {
+					return err
+				}
+				s.Supernet = supernet
+			}
+			st = s
+			return nil
+		})
+		if err != nil {
+			fail("Error recording networks", err)
+			return
+		}
+
+		planner, err := cidrPlanner(st, "")
+		if err != nil {
+			fail("Error loading cluster networks", err)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CLUSTER\tPODS\tSERVICES\tNODES")
+		for _, name := range append([]string{network.HomeCluster}, st.ClusterNames()...) {
+			networks, ok := planner.Networks(name)
+			if !ok {
+				continue
+			}
+			pods, services, nodes := networks.Strings()
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, joinOrDash(pods), joinOrDash(services), joinOrDash(nodes))
+		}
+		w.Flush()
+
+		conflicts := planner.AllConflicts()
+		if len(conflicts) == 0 {
+			fmt.Println("No overlapping ranges")
+			return
+		}
+		fmt.Println("Overlapping ranges:")
+		for _, conflict := range conflicts {
+			fmt.Printf("  %s\n", conflict)
+		}
+	},
+}
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Manage DNS records for the cluster",
+	Long: `Manage DNS records for exposing services from your home cluster.
+
+Leave --type empty to create A and AAAA records from the address family of each
+--content value. With --cluster the addresses are taken from the public IPs of
+the cloud cluster's nodes, or of its load balancer when it has one, so IPv6
+ingress gets AAAA records automatically.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, _ := cmd.Flags().GetString("provider")
+		domain, _ := cmd.Flags().GetString("domain")
+		recordName, _ := cmd.Flags().GetString("record")
+		recordType, _ := cmd.Flags().GetString("type")
+		contents, _ := cmd.Flags().GetStringSlice("content")
+		clusterName, _ := cmd.Flags().GetString("cluster")
+
+		// Resolve the cloud cluster's ingress addresses
+		if clusterName != "" {
+			cloudProvider, _ := cmd.Flags().GetString("cloud-provider")
+			region, _ := cmd.Flags().GetString("region")
+			apiKey, _ := cmd.Flags().GetString("api-key")
+
+			factory := providers.NewProviderFactory()
+			cp, err := factory.CreateProvider(providers.Provider{
+				Name:   cloudProvider,
+				Region: region,
+				Credentials: map[string]string{
+					"api_key": apiKey,
+				},
+			})
+			if err != nil {
+				fail("Error creating provider", err)
+				return
+			}
+
+			status, err := cp.GetClusterStatus(clusterName)
+			if err != nil {
+				fail("Error getting cluster status", err)
+				return
+			}
+			contents = status.PublicAddresses()
+		}
+
+		fmt.Printf("Managing DNS record %s.%s of type %s with provider %s\n",
+			recordName, domain, recordTypeLabel(recordType), provider)
+
+		// Create DNS manager
+		manager := dns.NewDNSManager(provider, domain)
+
+		// Validate configuration
+		if err := manager.ValidateConfig(); err != nil {
+			fail("Invalid DNS configuration", fmt.Errorf("%w: %v", providers.ErrInvalidSpec, err))
+			return
+		}
+
+		// Upsert DNS records
+		if recordType == "" {
+			if err := manager.UpsertAddressRecords(recordName, contents); err != nil {
+				fail("Error upserting DNS records", err)
+				return
+			}
+		} else {
+			if len(contents) == 0 {
+				contents = []string{""}
+			}
+			for _, content := range contents {
+				if err := manager.UpsertRecord(recordName, recordType, content); err != nil {
+					fail("Error upserting DNS record", err)
+					return
+				}
+			}
+		}
+
+		records, _ := manager.ListRecords()
+		for _, record := range records {
+			fmt.Printf("  %s %s %s\n", record.Name, record.Type, record.Content)
+		}
+
+		fmt.Println("DNS record updated successfully")
+	},
+}
+
+var costCmd = &cobra.Command{
+	Use:   "cost",
+	Short: "Estimate and report the cost of cloud clusters",
+	Long: `Price cloud clusters from the provider's pricing catalog, e.g. the Linode type
+prices. Only nodes are priced; load balancers, extra addresses, backups and
+transfer overages are not included.`,
+}
+
+var costEstimateCmd = &cobra.Command{
+	Use:   "estimate",
+	Short: "Estimate the hourly and monthly cost of a cluster before creating it",
+	Long: `Estimate the cost of the node pools create would provision, per pool and in
+total. The pools are given the same way as to create: --nodes, --size and
+--controlplanes, or --pools-file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, _ := cmd.Flags().GetString("provider")
+		region, _ := cmd.Flags().GetString("region")
+		clusterName, _ := cmd.Flags().GetString("name")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		output, _ := cmd.Flags().GetString("output")
+
+		if output != "table" && output != "json" {
+			fail("Error", fmt.Errorf("unsupported output format %q (table, json)", output))
+			return
+		}
+		pools, err := flagPools(cmd)
+		if err != nil {
+			fail("Error reading node pools", err)
+			return
+		}
+
+		estimator, err := costEstimator(provider, region, apiKey)
+		if err != nil {
+			fail("Error creating provider", err)
+			return
+		}
+		estimate, err := estimator.EstimateCost(providers.ClusterSpec{Name: clusterName, Pools: pools})
+		if err != nil {
+			fail("Error estimating cost", err)
+			return
+		}
+
+		if output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(estimate); err != nil {
+				fail("Error", err)
+			}
+			return
+		}
+		printCostEstimate(os.Stdout, estimate)
+	},
+}
+
+var costReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report the current run-rate of all managed clusters",
+	Long: `Price the nodes every recorded cloud cluster runs now and report the hourly
+and monthly run-rate per cluster and in total. Deleted clusters are left out.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		output, _ := cmd.Flags().GetString("output")
+
+		if output != "table" && output != "json" {
+			fail("Error", fmt.Errorf("unsupported output format %q (table, json)", output))
+			return
+		}
+
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+
+		// Clusters that cannot be priced are reported with their error;
+		// deleted clusters cost nothing and are left out
+		var names []string
+		for _, name := range st.ClusterNames() {
+			if cluster, _ := st.Cluster(name); !cluster.Deleted() {
+				names = append(names, name)
+			}
+		}
+		var total providers.Price
+		var failed error
+		estimates := map[string]providers.CostEstimate{}
+		for _, name := range names {
+			cluster, _ := st.Cluster(name)
+			estimate, err := clusterCost(cluster, apiKey)
+			if err != nil {
+				failed = errors.Join(failed, fmt.Errorf("cluster %s: %w", name, err))
+				continue
+			}
+			estimates[name] = estimate
+			total = total.Add(estimate.Total)
+		}
+
+		if output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(map[string]any{"clusters": estimates, "total": total}); err != nil {
+				fail("Error", err)
+				return
+			}
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CLUSTER\tPROVIDER\tREGION\tNODES\tHOURLY\tMONTHLY")
+			for _, name := range names {
+				cluster, _ := st.Cluster(name)
+				estimate, ok := estimates[name]
+				if !ok {
+					fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\n", name, cluster.Provider, cluster.Region)
+					continue
+				}
+				nodes := 0
+				for _, pool := range estimate.Pools {
+					nodes += pool.Count
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", name, cluster.Provider, cluster.Region, nodes,
+					formatDollars(estimate.Total.Hourly), formatDollars(estimate.Total.Monthly))
+			}
+			fmt.Fprintf(w, "TOTAL\t\t\t\t%s\t%s\n", formatDollars(total.Hourly), formatDollars(total.Monthly))
+			w.Flush()
+		}
+		if failed != nil {
+			fail("Error pricing clusters", failed)
+		}
+	},
+}
+
+var costBudgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Show or set the monthly budget of all cloud clusters",
+	Long: `Show or set the most the cloud clusters together may cost per month, in US
+dollars. create and scale check the budget against the run-rate of the other
+recorded clusters and the estimate of the cluster they change, and refuse to
+go over it with an explanation (exit code 8).
+
+With --downsize they provision cheaper instance types of the same class
+instead when that fits: every pool on create, a pool added by scale.
+--monthly 0 removes the budget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		monthly, _ := cmd.Flags().GetFloat64("monthly")
+		downsize, _ := cmd.Flags().GetBool("downsize")
+
+		var budget *state.Budget
+		err := stateStore(cmd).Update(func(st *state.State) error {
+			if cmd.Flags().Changed("monthly") {
+				if monthly < 0 {
+					return fmt.Errorf("invalid monthly budget %.2f", monthly)
+				}
+				st.Budget = &state.Budget{Monthly: monthly, Downsize: downsize}
+				if monthly == 0 {
+					st.Budget = nil
+				}
+			} else if cmd.Flags().Changed("downsize") && st.Budget != nil {
+				st.Budget.Downsize = downsize
+			}
+			budget = st.Budget
+			return nil
+		})
+		if err != nil {
+			fail("Error recording budget", err)
+			return
+		}
+
+		if budget == nil {
+			fmt.Println("No budget set")
+			return
+		}
+		mode := "refuse"
+		if budget.Downsize {
+			mode = "downsize"
+		}
+		fmt.Printf("Monthly budget: %s (%s when exceeded)\n", formatDollars(budget.Monthly), mode)
+	},
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Find and delete cloud resources left behind by failed runs",
+	Long: `List every instance, load balancer and firewall tagged by talos-autoextender
+and every uploaded Talos image, in all regions, and reconcile them with the
+state file. Resources of clusters that are not recorded or were deleted, and
+images no recorded cluster boots, are orphans; they are listed with their age
+and list price and deleted after confirmation, or right away with --yes. VPCs
+and placement groups cannot be tagged and are not collected.
+
+Recorded clusters without any resources are reported too; they are left in
+the state file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, _ := cmd.Flags().GetString("provider")
+		region, _ := cmd.Flags().GetString("region")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+		var clusters []providers.ClusterSpec
+		for _, name := range st.ClusterNames() {
+			if cluster, _ := st.Cluster(name); cluster.Provider == provider && !cluster.Deleted() {
+				clusters = append(clusters, clusterSpec(cluster))
+			}
+		}
+
+		cloudProvider, err := providers.NewProviderFactory().CreateProvider(providers.Provider{
+			Name:   provider,
+			Region: region,
+			Credentials: map[string]string{
+				"api_key": apiKey,
+			},
+		})
+		if err != nil {
+			fail("Error creating provider", err)
+			return
+		}
+		inventory, ok := cloudProvider.(providers.Inventory)
+		if !ok {
+			fail("Error", fmt.Errorf("provider %s cannot list its resources", provider))
+			return
+		}
+
+		resources, err := inventory.Resources()
+		if err != nil {
+			fail("Error listing resources", err)
+			return
+		}
+		for _, cluster := range clusters {
+			found := false
+			for _, resource := range resources {
+				found = found || resource.Cluster == cluster.Name
+			}
+			if !found {
+				fmt.Printf("Cluster %s is recorded but has no resources\n", cluster.Name)
+			}
+		}
+
+		orphans := providers.Orphans(inventory, resources, clusters)
+		if len(orphans) == 0 {
+			fmt.Printf("No orphaned resources among %d\n", len(resources))
+			return
+		}
+
+		var total providers.Price
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tID\tLABEL\tCLUSTER\tAGE\tMONTHLY")
+		for _, orphan := range orphans {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", orphan.Kind, orphan.ID, orphan.Label, orDash(orphan.Cluster),
+				formatAge(orphan.Age(now)), formatDollars(orphan.Cost.Monthly))
+			total = total.Add(orphan.Cost)
+		}
+		fmt.Fprintf(w, "TOTAL\t\t\t\t\t%s\n", formatDollars(total.Monthly))
+		w.Flush()
+
+		if !yes && !confirm(cmd, fmt.Sprintf("Delete %d orphaned resources?", len(orphans))) {
+			fmt.Println("Nothing deleted")
+			return
+		}
+
+		var failed error
+		for _, orphan := range orphans {
+			if err := inventory.DeleteResource(orphan); err != nil {
+				failed = errors.Join(failed, err)
+				continue
+			}
+			fmt.Printf("Deleted %s %s\n", orphan.Kind, orphan.Label)
+		}
+		if failed != nil {
+			fail("Error deleting resources", failed)
+		}
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Adopt an existing Talos cluster built without this tool",
+	Long: `Record a Talos cluster whose instances were created outside talos-autoextender
+so status, scale, upgrade, connect and delete work on it.
+
+Instances in the region are discovered by --tag or by --label-prefix. Each is
+queried through the Talos API with --talosconfig for its Talos version and
+machine config, which give its role, the Kubernetes endpoint and version, the
+pod and service CIDRs and, from a control plane node, the cluster secrets.
+Nodes are grouped into pools by role and instance type: one "controlplane"
+and one "worker" pool, or one pool per type, e.g. "worker-g6-standard-4",
+when a role uses several.
+
+The instances are tagged as members of the cluster and its pools; their other
+tags are kept. Imported clusters have no firewall, load balancer or placement
+group managed by the tool. Pass --image to scale pools, since new nodes need
+a Talos image.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, _ := cmd.Flags().GetString("provider")
+		region, _ := cmd.Flags().GetString("region")
+		clusterName, _ := cmd.Flags().GetString("name")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		tag, _ := cmd.Flags().GetString("tag")
+		labelPrefix, _ := cmd.Flags().GetString("label-prefix")
+		talosconfig, _ := cmd.Flags().GetString("talosconfig")
+		image, _ := cmd.Flags().GetString("image")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		if tag == "" && labelPrefix == "" {
+			fail("Error", fmt.Errorf("%w: --tag or --label-prefix is required", providers.ErrInvalidSpec))
+			return
+		}
+
+		st, err := stateStore(cmd).Load()
+		if err != nil {
+			fail("Error loading state", err)
+			return
+		}
+		if cluster, ok := st.Cluster(clusterName); ok && len(cluster.Pools) > 0 && !cluster.Deleted() {
+			fail("Error", fmt.Errorf("%w: cluster %s is already recorded", providers.ErrInvalidSpec, clusterName))
+			return
+		}
+
+		cloudProvider, err := providers.NewProviderFactory().CreateProvider(providers.Provider{
+			Name:   provider,
+			Region: region,
+			Credentials: map[string]string{
+				"api_key": apiKey,
+			},
+		})
+		if err != nil {
+			fail("Error creating provider", err)
+			return
+		}
+		importer, ok := cloudProvider.(providers.Importer)
+		if !ok {
+			fail("Error", fmt.Errorf("provider %s cannot import instances", provider))
+			return
+		}
+
+		nodes, err := importer.DiscoverNodes(tag, labelPrefix)
+		if err != nil {
+			fail("Error discovering instances", err)
+			return
+		}
+		if len(nodes) == 0 {
+			fail("Error", fmt.Errorf("%w: no instances found in %s", providers.ErrNotFound, region))
+			return
+		}
+		fmt.Printf("Found %d instances in %s\n", len(nodes), region)
+
+		// Ask every node for its role; the cluster details come from a control
+		// plane node since worker configs carry no secrets
+		connector := talos.NewConnector(talosconfig)
+		var cluster talos.NodeDetails
+		var controlPlanes []string
+		for i, node := range nodes {
+			if len(node.PublicIPs) == 0 {
+				fail("Error", fmt.Errorf("instance %s has no public address", node.Label))
+				return
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			details, err := talos.InspectNode(ctx, connector, node.PublicIPs[0])
+			cancel()
+			if err != nil {
+				fail("Error inspecting node", err)
+				return
+			}
+			nodes[i].Role = details.Role
+			nodes[i].TalosVersion = details.TalosVersion
+			if details.Role == providers.RoleControlPlane {
+				controlPlanes = append(controlPlanes, node.PublicIPs[0])
+				if cluster.Secrets == nil {
+					cluster = details
+				}
+			}
+		}
+		if cluster.Secrets == nil {
+			fail("Error", fmt.Errorf("%w: no control plane node among the instances", providers.ErrInvalidSpec))
+			return
+		}
+		for _, node := range nodes {
+			if node.TalosVersion != cluster.TalosVersion {
+				fmt.Printf("Warning: node %s runs Talos %s, recording %s\n", node.Label, node.TalosVersion, cluster.TalosVersion)
+			}
+		}
+
+		// Recorded networks are checked by connect and allocations of later
+		// clusters; an existing cluster cannot move, so overlaps only warn
+		planner, err := cidrPlanner(st, "")
+		if err != nil {
+			fail("Error checking cluster networks", err)
+			return
+		}
+		networks, err := network.ParseNetworks(cluster.PodCIDRs, cluster.ServiceCIDRs, nil)
+		if err != nil {
+			fail("Error reading cluster networks", err)
+			return
+		}
+		for _, conflict := range planner.Conflicts(clusterName, networks) {
+			fmt.Printf("Warning: cluster networks overlap: %s\n", conflict)
+		}
+
+		pools := providers.InferPools(nodes)
+		for _, pool := range pools {
+			fmt.Printf("  pool %s: %d %s nodes of size %s\n", pool.Name, pool.Count, pool.Role, pool.Size)
+		}
+
+		if err := importer.AdoptNodes(clusterName, nodes); err != nil {
+			fail("Error tagging instances", err)
+			return
+		}
+
+		err = stateStore(cmd).Update(func(st *state.State) error {
+			record, ok := st.Cluster(clusterName)
+			if !ok {
+				record = &state.Cluster{Name: clusterName}
+			}
+			record.Provider = provider
+			record.Region = region
+			record.DeletedAt = time.Time{}
+			record.Networks = state.Networks{Pods: cluster.PodCIDRs, Services: cluster.ServiceCIDRs}
+			record.Pools = recordPools(pools)
+			record.Image = image
+			record.Firewall = state.Firewall{Disabled: true}
+			record.Placement = providers.PlacementNone
+			record.TalosVersion = cluster.TalosVersion
+			record.KubernetesVersion = cluster.KubernetesVersion
+			record.ControlPlaneEndpoint = cluster.Endpoint
+			record.ControlPlanes = controlPlanes
+			record.Secrets = string(cluster.Secrets)
+			record.BootstrappedAt = time.Now().UTC()
+			st.PutCluster(record)
+			return nil
+		})
+		if err != nil {
+			fail("Error saving cluster state", err)
+			return
+		}
+
+		fmt.Printf("Cluster %s imported, running Talos %s\n", clusterName, cluster.TalosVersion)
+	},
+}
+
+func init() {
+	// Create command flags
+	createCmd.Flags().String("provider", "linode", "Cloud provider to use (linode, hetzner)")
+	createCmd.Flags().String("region", "us-east", "Region to deploy the cluster")
+	createCmd.Flags().String("name", "", "Name of the cluster to create")
+	createCmd.Flags().Int("nodes", 3, "Number of nodes in the cluster")
+	createCmd.Flags().String("size", "g6-standard-2", "Size/type of the nodes")
+	createCmd.Flags().String("talos-version", "v1.6.0", "Talos version to use")
+	createCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	createCmd.Flags().String("pod-cidr", "", "Pod CIDR (allocated from the supernet if empty)")
+	createCmd.Flags().String("service-cidr", "", "Service CIDR (allocated from the supernet if empty)")
+	createCmd.Flags().String("supernet", "", "Supernet to allocate cluster ranges from (default "+network.DefaultSupernet+")")
+	createCmd.Flags().String("kubernetes-version", talos.DefaultKubernetesVersion, "Kubernetes version to install")
+	createCmd.Flags().Int("controlplanes", 1, "Number of nodes running the control plane")
+	createCmd.Flags().String("pools-file", "", "YAML list of node pools with a name, role, count, size and optional labels, taints and config patches, replacing --nodes, --size and --controlplanes (see README)")
+	createCmd.Flags().String("image", "", "Provider image ID of a Talos image; without it the Image Factory builds one from --extension and --kernel-arg")
+	createCmd.Flags().String("endpoint", "", "Kubernetes API endpoint, e.g. https://k8s.example.com:6443 (default: first control plane node); when set, machine configs are injected as user data")
+	createCmd.Flags().Bool("skip-bootstrap", false, "Only provision the instances")
+	createCmd.Flags().Duration("bootstrap-timeout", 10*time.Minute, "Timeout for each bootstrap phase")
+	createCmd.Flags().StringSlice("extension", nil, "System extension to include in the Talos image, e.g. qemu-guest-agent, kept on upgrades (repeatable)")
+	createCmd.Flags().StringArray("kernel-arg", nil, "Extra kernel argument for the Talos image, kept on upgrades (repeatable)")
+	createCmd.Flags().String("image-factory", imagefactory.DefaultURL, "Talos Image Factory used for customized images")
+	createCmd.Flags().Bool("no-firewall", false, "Do not put the nodes behind a cloud firewall, which otherwise only lets in the admin and ingress traffic and traffic between the nodes")
+	createCmd.Flags().StringSlice("admin-cidr", nil, "CIDR allowed to reach the Talos API, Kubernetes API, WireGuard and ICMP (repeatable, default any address)")
+	createCmd.Flags().IntSlice("ingress-port", providers.DefaultIngressPorts, "TCP port opened for ingress traffic (repeatable)")
+	createCmd.Flags().StringSlice("ingress-cidr", nil, "CIDR allowed to reach the ingress ports (repeatable, default any address)")
+	createCmd.Flags().Bool("vpc", false, "Put the nodes on a private VPC subnet, keeping a public IPv4 address through 1:1 NAT")
+	createCmd.Flags().String("vpc-name", "", "Shared VPC to put the nodes on, created when missing and kept when the cluster is deleted (implies --vpc)")
+	createCmd.Flags().String("vpc-subnet", "", "IPv4 range of the cluster's VPC subnet (default: the recorded subnet or a free /24 in "+providers.DefaultVPCRange+")")
+	createCmd.Flags().Bool("load-balancer", false, "Front the Kubernetes API (6443) and HTTP/HTTPS ingress (80, 443) with a load balancer, the cluster endpoint without --endpoint")
+	createCmd.Flags().Bool("ingress-ip", false, "Allocate an extra IPv4 address shared by the ingress nodes for DNS-free failover with network status --watch --failover")
+	createCmd.Flags().String("placement", providers.PlacementFlexible, "Control plane anti-affinity: strict fails without a separate host, flexible places the node anyway, none disables it")
+	createCmd.MarkFlagRequired("name")
+
+	// Bootstrap command flags
+	bootstrapCmd.Flags().String("name", "", "Name of the cluster to bootstrap")
+	bootstrapCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	bootstrapCmd.Flags().Duration("bootstrap-timeout", 10*time.Minute, "Timeout for each bootstrap phase")
+	bootstrapCmd.MarkFlagRequired("name")
+
+	// Scale command flags
+	scaleCmd.Flags().String("name", "", "Name of the cluster to scale")
+	scaleCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	scaleCmd.Flags().String("pool", providers.DefaultWorkerPool, "Node pool to scale")
+	scaleCmd.Flags().Int("count", 0, "Number of nodes in the pool")
+	scaleCmd.Flags().String("role", providers.RoleWorker, "Role of a new pool (controlplane, worker)")
+	scaleCmd.Flags().String("size", "", "Size/type of the nodes of a new pool")
+	scaleCmd.Flags().Duration("bootstrap-timeout", 10*time.Minute, "Timeout for each bootstrap phase")
+	scaleCmd.MarkFlagRequired("name")
+	scaleCmd.MarkFlagRequired("count")
+
+	// Upgrade command flags
+	upgradeCmd.Flags().String("name", "", "Name of the cluster to upgrade")
+	upgradeCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	upgradeCmd.Flags().String("talos-version", "", "Talos version to upgrade to")
+	upgradeCmd.Flags().String("image", "", "Installer image (default: the cluster's Image Factory installer or ghcr.io/siderolabs/installer:<version>)")
+	upgradeCmd.Flags().Bool("preserve", false, "Preserve the ephemeral partition across the upgrade")
+	upgradeCmd.Flags().Duration("timeout", 15*time.Minute, "Timeout for each node to rejoin")
+	upgradeCmd.MarkFlagRequired("name")
+	upgradeCmd.MarkFlagRequired("talos-version")
+
+	upgradeKubernetesCmd.Flags().String("name", "", "Name of the cluster to upgrade")
+	upgradeKubernetesCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	upgradeKubernetesCmd.Flags().String("to", "", "Kubernetes version to upgrade to")
+	upgradeKubernetesCmd.Flags().Bool("force", false, "Upgrade even if APIs removed in the target version are in use")
+	upgradeKubernetesCmd.Flags().Duration("timeout", 10*time.Minute, "Timeout for each node to become Ready")
+	upgradeKubernetesCmd.MarkFlagRequired("name")
+	upgradeKubernetesCmd.MarkFlagRequired("to")
+
+	backupCmd.PersistentFlags().String("name", "", "Name of the cluster")
+	addStorageFlags(backupCmd.PersistentFlags())
+	backupCmd.Flags().Int("keep", 7, "Number of snapshots to keep (0 keeps all)")
+	backupCmd.Flags().Duration("max-age", 0, "Delete snapshots older than this (0 disables)")
+	backupCmd.MarkPersistentFlagRequired("name")
+	backupCmd.AddCommand(backupListCmd)
+
+	restoreCmd.Flags().String("name", "", "Name of the cluster to restore")
+	restoreCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	restoreCmd.Flags().String("snapshot", "latest", "Name of the snapshot to restore")
+	restoreCmd.Flags().String("endpoint", "", "Kubernetes API endpoint of the rebuilt cluster")
+	restoreCmd.Flags().String("pools-file", "", "YAML file of the node pools to create (default: as recorded)")
+	restoreCmd.Flags().String("image", "", "Provider image ID of a Talos image (default: as created)")
+	restoreCmd.Flags().Duration("bootstrap-timeout", 10*time.Minute, "Timeout for each bootstrap phase")
+	addStorageFlags(restoreCmd.Flags())
+	restoreCmd.MarkFlagRequired("name")
+
+	// Credential command flags
+	kubeconfigCmd.Flags().String("name", "", "Name of the cloud cluster")
+	kubeconfigCmd.Flags().Bool("merge", false, "Merge into the default kubeconfig instead of printing")
+	kubeconfigCmd.Flags().String("path", "", "Kubeconfig to merge into (default ~/.kube/config or KUBECONFIG)")
+	kubeconfigCmd.Flags().StringP("output", "o", "", "Write the kubeconfig to this file")
+	kubeconfigCmd.Flags().Duration("ttl", talos.DefaultAdminCertTTL, "Lifetime of the admin client certificate")
+	kubeconfigCmd.MarkFlagRequired("name")
+
+	talosconfigCmd.Flags().String("name", "", "Name of the cloud cluster")
+	talosconfigCmd.Flags().Bool("merge", false, "Merge into the default talosconfig instead of printing")
+	talosconfigCmd.Flags().String("path", "", "Talosconfig to merge into (default ~/.talos/config or TALOSCONFIG)")
+	talosconfigCmd.Flags().StringP("output", "o", "", "Write the talosconfig to this file")
+	talosconfigCmd.MarkFlagRequired("name")
+
+	// Delete command flags
+	deleteCmd.Flags().String("provider", "linode", "Cloud provider to use (linode, hetzner)")
+	deleteCmd.Flags().String("region", "us-east", "Region of the cluster")
+	deleteCmd.Flags().String("name", "", "Name of the cluster to delete")
+	deleteCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	deleteCmd.MarkFlagRequired("name")
+
+	// Status command flags
+	statusCmd.Flags().String("provider", "linode", "Cloud provider to use (linode, hetzner)")
+	statusCmd.Flags().String("region", "us-east", "Region of the cluster")
+	statusCmd.Flags().String("name", "", "Name of the cluster to get status for")
+	statusCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	statusCmd.Flags().StringP("output", "o", "table", "Output format (table, json, yaml)")
+	statusCmd.Flags().Duration("timeout", 10*time.Second, "Timeout for querying the nodes through the Talos API")
+	statusCmd.MarkFlagRequired("name")
+
+	// Connect command flags
+	connectCmd.Flags().StringSlice("home-endpoint", nil, "Endpoint(s) of the home cluster (IP:PORT, [IPv6]:PORT)")
+	connectCmd.Flags().StringSlice("cloud-endpoint", nil, "KubeSpan WireGuard endpoint(s) of the cloud cluster (IP:PORT, [IPv6]:PORT, usually port 51820)")
+	connectCmd.Flags().String("name", "", "Name of the cloud cluster")
+	connectCmd.Flags().StringSlice("pod-cidr", nil, "Pod CIDR(s) of the cloud cluster, if not created by this tool")
+	connectCmd.Flags().StringSlice("service-cidr", nil, "Service CIDR(s) of the cloud cluster, if not created by this tool")
+	connectCmd.Flags().StringSlice("node-cidr", nil, "Node CIDR(s) of the cloud cluster")
+	connectCmd.Flags().String("transport", "kubespan", "Transport linking the clusters (kubespan, headscale)")
+	connectCmd.Flags().String("ip-family", "auto", "Address family for KubeSpan (auto, ipv4, ipv6, dual)")
+	connectCmd.Flags().String("patch-out", "-", "Write the node machine-config documents to this file (- for stdout)")
+	connectCmd.Flags().Bool("discover-mtu", false, "Probe the path MTU to the cloud endpoints and set the KubeSpan MTU")
+	addMTUFlags(connectCmd)
+	connectCmd.Flags().String("headscale-url", "", "Headscale server URL for the headscale transport")
+	connectCmd.Flags().String("headscale-api-key", "", "Headscale API key (defaults to $HEADSCALE_API_KEY)")
+
+	// Network command flags
+	networkStatusCmd.Flags().String("talosconfig", "", "Path to talosconfig (defaults to $TALOSCONFIG or ~/.talos/config)")
+	networkStatusCmd.Flags().String("name", "", "Only report on this cloud cluster")
+	networkStatusCmd.Flags().String("headscale-api-key", "", "Headscale API key (defaults to $HEADSCALE_API_KEY)")
+	networkStatusCmd.Flags().Bool("watch", false, "Keep polling and print peer status every interval")
+	networkStatusCmd.Flags().Duration("interval", 30*time.Second, "Polling interval for --watch")
+	networkStatusCmd.Flags().Bool("failover", false, "Move shared ingress IPs off nodes that are down")
+	networkStatusCmd.Flags().String("api-key", "", "API key for the cloud provider, used by --failover")
+	networkCmd.AddCommand(networkStatusCmd)
+
+	networkMTUCmd.Flags().StringSlice("endpoint", nil, "Endpoint(s) to probe (IP:PORT, [IPv6]:PORT)")
+	addMTUFlags(networkMTUCmd)
+	networkCmd.AddCommand(networkMTUCmd)
+
+	networkCIDRsCmd.Flags().StringSlice("home-pods", nil, "Record the home cluster pod CIDR(s)")
+	networkCIDRsCmd.Flags().StringSlice("home-services", nil, "Record the home cluster service CIDR(s)")
+	networkCIDRsCmd.Flags().StringSlice("home-nodes", nil, "Record the home cluster node CIDR(s)")
+	networkCIDRsCmd.Flags().String("supernet", "", "Record the supernet new cloud cluster ranges are allocated from")
+	networkCmd.AddCommand(networkCIDRsCmd)
+
+	// DNS command flags
+	dnsCmd.Flags().String("provider", "cloudflare", "DNS provider to use")
+	dnsCmd.Flags().String("domain", "", "Domain to manage records for")
+	dnsCmd.Flags().String("record", "", "Record name (e.g., www)")
+	dnsCmd.Flags().String("type", "", "Record type (A, AAAA, CNAME, etc.); empty infers A/AAAA from addresses")
+	dnsCmd.Flags().StringSlice("content", nil, "Record content (e.g., IP address); may be repeated")
+	dnsCmd.Flags().String("cluster", "", "Use the public node addresses of this cloud cluster as content")
+	dnsCmd.Flags().String("cloud-provider", "linode", "Cloud provider of --cluster")
+	dnsCmd.Flags().String("region", "us-east", "Region of --cluster")
+	dnsCmd.Flags().String("api-key", "", "API key for the cloud provider of --cluster")
+
+	// Cost command flags
+	costEstimateCmd.Flags().String("provider", "linode", "Cloud provider to price")
+	costEstimateCmd.Flags().String("region", "us-east", "Region to price")
+	costEstimateCmd.Flags().String("name", "", "Name of the cluster to estimate")
+	costEstimateCmd.Flags().Int("nodes", 3, "Number of nodes in the cluster")
+	costEstimateCmd.Flags().String("size", "g6-standard-2", "Size/type of the nodes")
+	costEstimateCmd.Flags().Int("controlplanes", 1, "Number of nodes running the control plane")
+	costEstimateCmd.Flags().String("pools-file", "", "YAML list of node pools with a name, role, count, size and optional labels, taints and config patches, replacing --nodes, --size and --controlplanes (see README)")
+	costEstimateCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	costEstimateCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+	costCmd.AddCommand(costEstimateCmd)
+	costReportCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	costReportCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+	costCmd.AddCommand(costReportCmd)
+	costBudgetCmd.Flags().Float64("monthly", 0, "Monthly budget of all cloud clusters in US dollars (0 removes it)")
+	costBudgetCmd.Flags().Bool("downsize", false, "Fall back to cheaper instance types instead of refusing")
+	costCmd.AddCommand(costBudgetCmd)
+
+	// GC command flags
+	gcCmd.Flags().String("provider", "linode", "Cloud provider to collect resources from")
+	gcCmd.Flags().String("region", "us-east", "Region of the provider client; resources are listed in every region")
+	gcCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	gcCmd.Flags().Bool("yes", false, "Delete the orphaned resources without asking")
+
+	// Import command flags
+	importCmd.Flags().String("provider", "linode", "Cloud provider the instances run on")
+	importCmd.Flags().String("region", "us-east", "Region the instances run in")
+	importCmd.Flags().String("name", "", "Name to record the cluster under")
+	importCmd.Flags().String("api-key", "", "API key for the cloud provider")
+	importCmd.Flags().String("tag", "", "Provider tag the cluster's instances carry")
+	importCmd.Flags().String("label-prefix", "", "Label prefix of the cluster's instances, used without --tag")
+	importCmd.Flags().String("talosconfig", "", "Path to talosconfig (defaults to $TALOSCONFIG or ~/.talos/config)")
+	importCmd.Flags().String("image", "", "Provider image ID of a Talos image, used when scaling pools")
+	importCmd.Flags().Duration("timeout", 30*time.Second, "Timeout for querying each node")
+	importCmd.MarkFlagRequired("name")
+
+	// Global flags
+	rootCmd.PersistentFlags().String("state", state.DefaultPath(), "Path to the state file")
+
+	// Add commands to root command
+	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(bootstrapCmd)
+	rootCmd.AddCommand(scaleCmd)
+	rootCmd.AddCommand(upgradeCmd)
+	rootCmd.AddCommand(upgradeKubernetesCmd)
+	rootCmd.AddCommand(kubeconfigCmd)
+	rootCmd.AddCommand(talosconfigCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(connectCmd)
+	rootCmd.AddCommand(networkCmd)
+	rootCmd.AddCommand(dnsCmd)
+	rootCmd.AddCommand(costCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(importCmd)
+}
+
+// cidrPlanner builds a CIDR planner from the recorded home and cloud cluster
+// networks. The home cluster is assumed to use the Talos defaults until its
+// ranges are recorded.
+func cidrPlanner(st *state.State, supernet string) (*network.CIDRPlanner, error) {
+	if supernet == "" {
+		supernet = st.Supernet
+	}
+	if supernet == "" {
+		supernet = network.DefaultSupernet
+	}
+
+	planner, err := network.NewCIDRPlanner(supernet)
+	if err != nil {
+		return nil, err
+	}
+
+	home := st.HomeNetworks
+	if home.IsZero() {
+		home = state.Networks{
+			Pods:     []string{network.TalosDefaultPodCIDR},
+			Services: []string{network.TalosDefaultServiceCIDR},
+		}
+	}
+	networks, err := network.ParseNetworks(home.Pods, home.Services, home.Nodes)
+	if err != nil {
+		return nil, fmt.Errorf("home cluster: %v", err)
+	}
+	planner.Record(network.HomeCluster, networks)
+
+	for _, name := range st.ClusterNames() {
+		recorded := st.Clusters[name].Networks
+		networks, err := network.ParseNetworks(recorded.Pods, recorded.Services, recorded.Nodes)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", name, err)
+		}
+		planner.Record(name, networks)
+	}
+
+	return planner, nil
+}
+
+// planVPCSubnet returns the VPC subnet recorded for a cluster, or a free one
+// that does not overlap the ranges of other clusters, which may share the VPC
+func planVPCSubnet(st *state.State, planner *network.CIDRPlanner, name string) (string, error) {
+	if cluster, ok := st.Cluster(name); ok && cluster.VPC.Subnet != "" {
+		return cluster.VPC.Subnet, nil
+	}
+
+	subnet, err := planner.AllocateNodes(name, netip.MustParsePrefix(providers.DefaultVPCRange), providers.DefaultVPCPrefixLen)
+	if err != nil {
+		return "", err
+	}
+	return subnet.String(), nil
+}
+
+// planClusterNetworks checks the requested pod and service CIDRs and the node
+// CIDRs of a new cluster, allocating pod and service CIDRs that are missing
+func planClusterNetworks(planner *network.CIDRPlanner, name, podCIDR, serviceCIDR string, nodeCIDRs []string) (network.ClusterNetworks, error) {
+	nodes, err := network.ParseNetworks(nil, nil, nodeCIDRs)
+	if err != nil {
+		return network.ClusterNetworks{}, err
+	}
+
+	if podCIDR == "" || serviceCIDR == "" {
+		// Ignore a stale record of a cluster with the same name
+		planner.Record(name, network.ClusterNetworks{})
+		allocated, err := planner.Allocate(name, nodes.Nodes)
+		if err != nil {
+			return network.ClusterNetworks{}, err
+		}
+		if podCIDR == "" {
+			podCIDR = allocated.Pods[0].String()
+		}
+		if serviceCIDR == "" {
+			serviceCIDR = allocated.Services[0].String()
+		}
+	}
+
+	networks, err := network.ParseNetworks([]string{podCIDR}, []string{serviceCIDR}, nodeCIDRs)
+	if err != nil {
+		return network.ClusterNetworks{}, err
+	}
+
+	if conflicts := planner.Conflicts(name, networks); len(conflicts) > 0 {
+		msgs := make([]string, 0, len(conflicts))
+		for _, conflict := range conflicts {
+			msgs = append(msgs, conflict.String())
+		}
+		return network.ClusterNetworks{}, fmt.Errorf("overlapping ranges: %s", strings.Join(msgs, "; "))
+	}
+
+	return networks, nil
+}
+
+// connectNetworks returns the cluster networks given on the connect command
+// line, falling back to the ranges recorded when the cluster was created
+func connectNetworks(cmd *cobra.Command, st *state.State, name string) (network.ClusterNetworks, error) {
+	pods, _ := cmd.Flags().GetStringSlice("pod-cidr")
+	services, _ := cmd.Flags().GetStringSlice("service-cidr")
+	nodes, _ := cmd.Flags().GetStringSlice("node-cidr")
+
+	if cluster, ok := st.Cluster(name); ok && len(pods)+len(services)+len(nodes) == 0 {
+		pods, services, nodes = cluster.Networks.Pods, cluster.Networks.Services, cluster.Networks.Nodes
+	}
+
+	if len(pods)+len(services)+len(nodes) == 0 {
+		fmt.Printf("Warning: no CIDRs recorded for %s, skipping overlap check\n", name)
+	}
+
+	return network.ParseNetworks(pods, services, nodes)
+}
+
+// clusterTalosEndpoint returns the Talos API address peer statuses of a
+// KubeSpan cluster are queried through. Clusters connected before it was
+// recorded have it derived from the WireGuard peer endpoint.
+func clusterTalosEndpoint(cluster *state.Cluster) string {
+	if cluster.TalosEndpoint != "" {
+		return cluster.TalosEndpoint
+	}
+	host, _, err := net.SplitHostPort(cluster.Endpoint)
+	if err != nil {
+		host = cluster.Endpoint
+	}
+	return net.JoinHostPort(host, strconv.Itoa(providers.TalosAPIPort))
+}
+
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
+
+// writePatch writes machine-config documents to path, or stdout if path is "-"
+func writePatch(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func addMTUFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("kubespan-mtu", 0, "KubeSpan MTU to configure or check against (0 for the Talos default)")
+	cmd.Flags().Bool("pppoe", false, "The home uplink uses PPPoE")
+	cmd.Flags().Int("link-mtu", 1500, "MTU of the local link used for probing")
+}
+
+// discoverKubeSpanMTU probes every endpoint, prints the results and returns the
+// KubeSpan MTU that is safe for all of them. Clusters in the state file whose
+// MTU disagrees are flagged.
+func discoverKubeSpanMTU(cmd *cobra.Command, endpoints []string, clusterName string) (int, error) {
+	configured, _ := cmd.Flags().GetInt("kubespan-mtu")
+	pppoe, _ := cmd.Flags().GetBool("pppoe")
+	linkMTU, _ := cmd.Flags().GetInt("link-mtu")
+
+	parsed, err := network.ParseEndpoints(endpoints)
+	if err != nil {
+		return 0, err
+	}
+
+	discovery := network.NewMTUDiscovery(network.NewICMPProber(2*time.Second, 2))
+	discovery.PPPoE = pppoe
+	discovery.LinkMTU = linkMTU
+
+	var reports []network.MTUReport
+	var warnings []string
+	for _, endpoint := range parsed {
+		report, err := discovery.Probe(cmd.Context(), endpoint)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", endpoint, err)
+		}
+		reports = append(reports, report)
+		warnings = append(warnings, report.Warnings...)
+	}
+
+	mtu, planWarnings := network.PlanMTU(reports, configured)
+	warnings = append(warnings, planWarnings...)
+
+	// KubeSpan on the home nodes has a single MTU for every peer
+	st, err := stateStore(cmd).Load()
+	if err != nil {
+		return 0, err
+	}
+	recorded := make(map[string]int)
+	for _, cluster := range st.ConnectedClusters() {
+		if cluster.Name != clusterName {
+			recorded[cluster.Name] = cluster.KubeSpanMTU
+		}
+	}
+	warnings = append(warnings, network.MTUMismatches(mtu, recorded)...)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tFAMILY\tPATH MTU\tKUBESPAN MTU")
+	for _, report := range reports {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", report.Endpoint, report.Family, report.PathMTU, report.SafeMTU)
+	}
+	w.Flush()
+
+	fmt.Printf("KubeSpan MTU: %d\n", mtu)
+	for _, warning := range warnings {
+		fmt.Printf("WARNING: %s\n", warning)
+	}
+
+	return mtu, nil
+}
+
+// headscaleAPIKey returns the --headscale-api-key flag or $HEADSCALE_API_KEY
+func headscaleAPIKey(cmd *cobra.Command) string {
+	if key, _ := cmd.Flags().GetString("headscale-api-key"); key != "" {
+		return key
+	}
+	return os.Getenv("HEADSCALE_API_KEY")
+}
+
+func recordTypeLabel(recordType string) string {
+	if recordType == "" {
+		return "A/AAAA"
+	}
+	return recordType
+}
+
+// bootstrapCluster bootstraps the nodes of a created cluster and records the
+// control plane endpoint. Without a configured endpoint the Kubernetes API is
+// reached through the cluster load balancer, or on the first control plane
+// node.
+func bootstrapCluster(cmd *cobra.Command, cloudProvider providers.CloudProvider, cluster talos.ClusterConfig, pools []providers.NodePool, secrets []byte, recoverFrom string) error {
+	timeout, _ := cmd.Flags().GetDuration("bootstrap-timeout")
+
+	status, err := cloudProvider.GetClusterStatus(cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	nodes := bootstrapNodes(status, nil)
+	for _, node := range nodes {
+		if node.ControlPlane {
+			cluster.ControlPlanes = append(cluster.ControlPlanes, node.Address)
+		}
+	}
+	if len(cluster.ControlPlanes) == 0 {
+		return fmt.Errorf("no control plane node with a public address found")
+	}
+	if cluster.Endpoint == "" {
+		address := cluster.ControlPlanes[0]
+		if len(status.Endpoints.LoadBalancer) > 0 {
+			address = status.Endpoints.LoadBalancer[0]
+		}
+		cluster.Endpoint = "https://" + net.JoinHostPort(address, "6443")
+	}
+
+	configs, err := talos.GenerateConfigs(cluster, secrets)
+	if err != nil {
+		return err
+	}
+
+	nodeConfigs, err := poolConfigs(pools, configs)
+	if err != nil {
+		return err
+	}
+	nodes = bootstrapNodes(status, nodeConfigs)
+
+	kubernetes, err := talos.NewKubernetesHealthCheck(cluster.Endpoint, configs.KubernetesCA)
+	if err != nil {
+		return err
+	}
+
+	bootstrapper := talos.NewBootstrapper(talos.NewConfigConnector(configs.Talosconfig), talos.NewInsecureConnector(), kubernetes)
+	bootstrapper.ControlPlaneConfig = configs.ControlPlane
+	bootstrapper.WorkerConfig = configs.Worker
+	bootstrapper.RecoverFrom = recoverFrom
+	bootstrapper.Timeout = timeout
+	bootstrapper.Report = func(phase talos.Phase, message string) {
+		fmt.Printf("[%s] %s\n", phase, message)
+	}
+
+	if err := bootstrapper.Run(cmd.Context(), nodes); err != nil {
+		return err
+	}
+
+	return stateStore(cmd).Update(func(st *state.State) error {
+		recorded, ok := st.Cluster(cluster.Name)
+		if !ok {
+			return fmt.Errorf("cluster %s is not recorded", cluster.Name)
+		}
+		recorded.ControlPlaneEndpoint = cluster.Endpoint
+		recorded.ControlPlanes = cluster.ControlPlanes
+		recorded.BootstrappedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// addStorageFlags adds the flags selecting where etcd snapshots are stored
+func addStorageFlags(flags *pflag.FlagSet) {
+	flags.String("storage", backup.DefaultLocation(), "Snapshot location: a directory or s3://bucket/prefix")
+	flags.String("s3-endpoint", backup.DefaultS3Endpoint, "Endpoint of the S3-compatible storage")
+	flags.String("s3-region", "", "Region of the S3 bucket")
+	flags.Bool("s3-insecure", false, "Use plain HTTP for the S3 endpoint")
+}
+
+func backupStore(cmd *cobra.Command) (backup.Store, error) {
+	location, _ := cmd.Flags().GetString("storage")
+	endpoint, _ := cmd.Flags().GetString("s3-endpoint")
+	region, _ := cmd.Flags().GetString("s3-region")
+	insecure, _ := cmd.Flags().GetBool("s3-insecure")
+
+	return backup.NewStore(location, backup.S3Options{
+		Endpoint: endpoint,
+		Region:   region,
+		Insecure: insecure,
+	})
+}
+
+// takeSnapshot saves an etcd snapshot from the first control plane node that
+// provides one
+func takeSnapshot(ctx context.Context, cluster *state.Cluster, store backup.Store) (backup.Snapshot, error) {
+	if len(cluster.ControlPlanes) == 0 {
+		return backup.Snapshot{}, fmt.Errorf("cluster %s has no recorded control plane nodes; bootstrap it first", cluster.Name)
+	}
+
+	talosconfig, err := talos.AdminTalosconfig(cluster.Name, cluster.ControlPlanes, []byte(cluster.Secrets))
+	if err != nil {
+		return backup.Snapshot{}, err
+	}
+	connect := talos.NewConfigConnector(talosconfig)
+
+	var lastErr error
+	for _, address := range cluster.ControlPlanes {
+		snapshot, err := snapshotNode(ctx, connect, cluster.Name, address, store)
+		if err == nil {
+			return snapshot, nil
+		}
+		fmt.Printf("Snapshot from %s failed: %v\n", address, err)
+		lastErr = err
+	}
+
+	return backup.Snapshot{}, lastErr
+}
+
+func snapshotNode(ctx context.Context, connect talos.Connector, cluster, address string, store backup.Store) (backup.Snapshot, error) {
+	c, err := connect(ctx, address)
+	if err != nil {
+		return backup.Snapshot{}, err
+	}
+	defer c.Close()
+
+	r, err := c.EtcdSnapshot(ctx)
+	if err != nil {
+		return backup.Snapshot{}, err
+	}
+	defer r.Close()
+
+	return backup.Save(ctx, store, cluster, r, time.Now())
+}
+
+// downloadSnapshot copies a snapshot to a temporary file, as recovery may need
+// to upload it more than once
+func downloadSnapshot(ctx context.Context, store backup.Store, snapshot backup.Snapshot) (string, error) {
+	r, err := store.Open(ctx, snapshot.Key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp("", "etcd-snapshot-*.db")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// clusterSpec returns the provisioning parameters of a recorded cluster
+func clusterSpec(cluster *state.Cluster) providers.ClusterSpec {
+	spec := providers.ClusterSpec{
+		Name:         cluster.Name,
+		TalosVersion: cluster.TalosVersion,
+		Pools:        clusterPools(cluster),
+		Image:        cluster.Image,
+		Extensions:   cluster.Extensions,
+		KernelArgs:   cluster.KernelArgs,
+		SchematicID:  cluster.Schematic,
+		ImageFactory: cluster.ImageFactory,
+		Firewall: providers.FirewallSpec{
+			Disabled:     cluster.Firewall.Disabled,
+			AdminCIDRs:   cluster.Firewall.AdminCIDRs,
+			IngressPorts: cluster.Firewall.IngressPorts,
+			IngressCIDRs: cluster.Firewall.IngressCIDRs,
+		},
+		VPC: providers.VPCSpec{
+			Enabled: cluster.VPC.Enabled,
+			Name:    cluster.VPC.Name,
+			Subnet:  cluster.VPC.Subnet,
+		},
+		LoadBalancer: cluster.LoadBalancer,
+		IngressIP:    cluster.IngressIP,
+		Placement:    cluster.Placement,
+	}
+	if len(cluster.Networks.Pods) > 0 {
+		spec.PodCIDR = cluster.Networks.Pods[0]
+	}
+	if len(cluster.Networks.Services) > 0 {
+		spec.ServiceCIDR = cluster.Networks.Services[0]
+	}
+	return spec
+}
+
+// restoreSpec returns the provisioning parameters the cluster was created
+// with, overridden by the restore flags
+func restoreSpec(cmd *cobra.Command, cluster *state.Cluster) (providers.ClusterSpec, error) {
+	poolsFile, _ := cmd.Flags().GetString("pools-file")
+	image, _ := cmd.Flags().GetString("image")
+
+	spec := clusterSpec(cluster)
+	if poolsFile != "" {
+		data, err := os.ReadFile(poolsFile)
+		if err != nil {
+			return providers.ClusterSpec{}, fmt.Errorf("failed to read node pools: %v", err)
+		}
+		if spec.Pools, err = providers.LoadNodePools(data); err != nil {
+			return providers.ClusterSpec{}, err
+		}
+	}
+	if image != "" {
+		spec.Image = image
+	}
+	return spec, spec.Validate()
+}
+
+// restoreEndpoint keeps an explicitly configured Kubernetes API endpoint. One
+// pointing at an old control plane node or load balancer address is dropped
+// so the bootstrap picks the new load balancer or first control plane node.
+func restoreEndpoint(cluster *state.Cluster, override string) string {
+	if override != "" {
+		return override
+	}
+
+	endpoint := cluster.ControlPlaneEndpoint
+	if u, err := url.Parse(endpoint); err == nil {
+		if cluster.LoadBalancer && net.ParseIP(u.Hostname()) != nil {
+			return ""
+		}
+		for _, address := range cluster.ControlPlanes {
+			if u.Hostname() == address {
+				return ""
+			}
+		}
+	}
+	return endpoint
+}
+
+// registerSchematic registers the schematic for extensions and kernel args with
+// the Image Factory and returns its ID, or "" when the image is not customized
+func registerSchematic(ctx context.Context, factoryURL string, extensions, kernelArgs []string) (string, error) {
+	schematic, err := imagefactory.NewSchematic(extensions, kernelArgs)
+	if err != nil {
+		return "", err
+	}
+	if schematic.IsZero() {
+		return "", nil
+	}
+
+	return imagefactory.NewClient(factoryURL).CreateSchematic(ctx, schematic)
+}
+
+// clusterInstallerImage returns the Image Factory installer for the cluster's
+// schematic, or "" for clusters using the official installer
+func clusterInstallerImage(cluster *state.Cluster, version string) string {
+	if cluster.Schematic == "" {
+		return ""
+	}
+	return imagefactory.NewClient(cluster.ImageFactory).InstallerImage(cluster.Schematic, version)
+}
+
+// clusterTalosConfig returns the Talos cluster parameters of a recorded cluster
+func clusterTalosConfig(cluster *state.Cluster) talos.ClusterConfig {
+	config := talos.ClusterConfig{
+		Name:              cluster.Name,
+		Endpoint:          cluster.ControlPlaneEndpoint,
+		TalosVersion:      cluster.TalosVersion,
+		KubernetesVersion: cluster.KubernetesVersion,
+		InstallImage:      clusterInstallerImage(cluster, cluster.TalosVersion),
+	}
+	if cluster.VPC.Enabled {
+		config.PrivateSubnet = cluster.VPC.Subnet
+	}
+	if len(cluster.Networks.Pods) > 0 {
+		config.PodCIDR = cluster.Networks.Pods[0]
+	}
+	if len(cluster.Networks.Services) > 0 {
+		config.ServiceCIDR = cluster.Networks.Services[0]
+	}
+	return config
+}
+
+// bootstrapNodes converts provider node status to bootstrap nodes, preferring
+// the IPv4 address for the Talos API. configs are the machine configs by pool
+// name.
+func bootstrapNodes(status providers.ClusterStatus, configs map[string][]byte) []talos.Node {
+	nodes := make([]talos.Node, 0, len(status.Nodes))
+	for _, node := range status.Nodes {
+		address := ""
+		for _, ip := range node.PublicIPs {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+				address = ip
+				break
+			}
+		}
+		if address == "" && len(node.PublicIPs) > 0 {
+			address = node.PublicIPs[0]
+		}
+
+		nodes = append(nodes, talos.Node{
+			Name:           node.Label,
+			Address:        address,
+			ControlPlane:   node.Role == providers.RoleControlPlane,
+			ConfigInjected: node.ConfigInjected,
+			Config:         configs[node.Pool],
+		})
+	}
+	return nodes
+}
+
+// poolConfigs customizes the machine config of each pool's role with the
+// pool's node labels, taints and patches
+func poolConfigs(pools []providers.NodePool, configs talos.MachineConfigs) (map[string][]byte, error) {
+	customized := make(map[string][]byte, len(pools))
+	for _, pool := range pools {
+		config := configs.Worker
+		if pool.Role == providers.RoleControlPlane {
+			config = configs.ControlPlane
+		}
+
+		config, err := talos.CustomizeConfig(config, talos.NodeCustomization{
+			Labels:  pool.Labels,
+			Taints:  pool.Taints,
+			Patches: pool.Patches,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("pool %s: %v", pool.Name, err)
+		}
+		customized[pool.Name] = config
+	}
+	return customized, nil
+}
+
+// injectPoolConfigs generates the machine config of every pool of the spec so
+// nodes boot configured
+func injectPoolConfigs(spec *providers.ClusterSpec, cluster talos.ClusterConfig, secrets []byte) error {
+	configs, err := talos.GenerateConfigs(cluster, secrets)
+	if err != nil {
+		return err
+	}
+
+	customized, err := poolConfigs(spec.Pools, configs)
+	if err != nil {
+		return err
+	}
+	for i := range spec.Pools {
+		spec.Pools[i].Config = customized[spec.Pools[i].Name]
+	}
+	return nil
+}
+
+// clusterPools returns the recorded node pools of a cluster
+func clusterPools(cluster *state.Cluster) []providers.NodePool {
+	pools := make([]providers.NodePool, 0, len(cluster.Pools))
+	for _, pool := range cluster.Pools {
+		pools = append(pools, providers.NodePool{
+			Name:    pool.Name,
+			Role:    pool.Role,
+			Count:   pool.Count,
+			Size:    pool.Size,
+			Labels:  pool.Labels,
+			Taints:  pool.Taints,
+			Patches: pool.Patches,
+		})
+	}
+	return pools
+}
+
+// recordPools converts node pools to their state records
+func recordPools(pools []providers.NodePool) []state.NodePool {
+	records := make([]state.NodePool, 0, len(pools))
+	for _, pool := range pools {
+		records = append(records, state.NodePool{
+			Name:    pool.Name,
+			Role:    pool.Role,
+			Count:   pool.Count,
+			Size:    pool.Size,
+			Labels:  pool.Labels,
+			Taints:  pool.Taints,
+			Patches: pool.Patches,
+		})
+	}
+	return records
+}
+
+// queryNodeStatus adds the Talos version and Kubernetes Ready condition each
+// node reports through the Talos API
+func queryNodeStatus(ctx context.Context, cluster *state.Cluster, status *providers.ClusterStatus, timeout time.Duration) error {
+	if cluster.Secrets == "" || len(status.Endpoints.Talos) == 0 {
+		return nil
+	}
+
+	talosconfig, err := talos.AdminTalosconfig(cluster.Name, status.Endpoints.Talos, []byte(cluster.Secrets))
+	if err != nil {
+		return err
+	}
+
+	nodes := bootstrapNodes(*status, nil)
+	infos := talos.QueryNodes(ctx, talos.NewConfigConnector(talosconfig), nodes, timeout)
+	for i, node := range nodes {
+		info := infos[node.Name]
+		status.Nodes[i].TalosVersion = info.TalosVersion
+		status.Nodes[i].KubernetesReady = info.KubernetesReady
+	}
+	return nil
+}
+
+// printClusterStatus writes the cluster status as a table, JSON or YAML
+func printClusterStatus(out io.Writer, format string, status providers.ClusterStatus, now time.Time) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	case "yaml":
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(status)
+	}
+
+	fmt.Fprintf(out, "Cluster %s: %s (%d/%d nodes ready)\n", status.Name, status.State, status.ReadyNodeCount, status.NodeCount)
+	if status.Endpoints.Kubernetes != "" {
+		fmt.Fprintf(out, "Kubernetes API: %s\n", status.Endpoints.Kubernetes)
+	}
+	if len(status.Endpoints.Talos) > 0 {
+		fmt.Fprintf(out, "Talos API: %s\n", strings.Join(status.Endpoints.Talos, ", "))
+	}
+	if len(status.Endpoints.LoadBalancer) > 0 {
+		fmt.Fprintf(out, "Load balancer: %s\n", strings.Join(status.Endpoints.LoadBalancer, ", "))
+	}
+	if status.Endpoints.IngressIP != "" {
+		fmt.Fprintf(out, "Ingress IP: %s on %s\n", status.Endpoints.IngressIP, orDash(status.Endpoints.IngressNode))
+	}
+	if placement := status.Placement; placement != nil {
+		compliance := "compliant"
+		if !placement.Compliant {
+			compliance = "not compliant"
+			if len(placement.NonCompliant) > 0 {
+				compliance += ": " + strings.Join(placement.NonCompliant, ", ") + " share a host"
+			}
+		}
+		fmt.Fprintf(out, "Placement: %s (%s), %s\n", placement.Group, placement.Policy, compliance)
+	}
+	for _, pool := range status.Pools {
+		fmt.Fprintf(out, "Pool %s (%s): %d/%d ready\n", pool.Name, pool.Role, pool.Ready, pool.Count)
+	}
+	if status.Error != "" {
+		fmt.Fprintf(out, "Error: %s\n", status.Error)
+	}
+
+	if len(status.Nodes) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tPOOL\tROLE\tSTATE\tPUBLIC IP\tPRIVATE IP\tTALOS\tREADY\tAGE")
+	for _, node := range status.Nodes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			node.Label, node.ID, node.Pool, node.Role, node.State,
+			joinOrDash(node.PublicIPs), joinOrDash(node.PrivateIPs),
+			orDash(node.TalosVersion), orDash(node.KubernetesReady), formatAge(node.Age(now)))
+	}
+	return w.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// formatAge renders a duration in the largest whole unit, like kubectl
+func formatAge(age time.Duration) string {
+	switch {
+	case age <= 0:
+		return "-"
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
+
+// clusterProvider creates the cloud provider a recorded cluster runs on
+func clusterProvider(cluster *state.Cluster, apiKey string) (providers.CloudProvider, error) {
+	return providers.NewProviderFactory().CreateProvider(providers.Provider{
+		Name:   cluster.Provider,
+		Region: cluster.Region,
+		Credentials: map[string]string{
+			"api_key": apiKey,
+		},
+	})
+}
+
+func printUpgradeReport(report talos.UpgradeReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLE\tFROM\tTO\tRESULT")
+
+	for _, node := range report.Nodes {
+		role := providers.RoleWorker
+		if node.ControlPlane {
+			role = providers.RoleControlPlane
+		}
+		from := node.From
+		if from == "" {
+			from = "-"
+		}
+		result := node.Result
+		if node.Error != "" {
+			result += ": " + node.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", node.Node, role, from, node.To, result)
+	}
+
+	w.Flush()
+}
+
+// clusterWithSecrets loads a recorded cluster that has Talos secrets
+func clusterWithSecrets(cmd *cobra.Command, name string) (*state.Cluster, error) {
+	st, err := stateStore(cmd).Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %v", err)
+	}
+
+	cluster, ok := st.Cluster(name)
+	if !ok {
+		return nil, fmt.Errorf("cluster %s is not recorded", name)
+	}
+	if cluster.Secrets == "" {
+		return nil, fmt.Errorf("cluster %s has no stored secrets; it was not created by this tool", name)
+	}
+
+	return cluster, nil
+}
+
+// flagPools returns the node pools of --pools-file, or of --nodes, --size and
+// --controlplanes without one
+func flagPools(cmd *cobra.Command) ([]providers.NodePool, error) {
+	poolsFile, _ := cmd.Flags().GetString("pools-file")
+	if poolsFile == "" {
+		nodeCount, _ := cmd.Flags().GetInt("nodes")
+		nodeSize, _ := cmd.Flags().GetString("size")
+		controlPlanes, _ := cmd.Flags().GetInt("controlplanes")
+		return providers.DefaultNodePools(nodeCount, nodeSize, controlPlanes), nil
+	}
+
+	data, err := os.ReadFile(poolsFile)
+	if err != nil {
+		return nil, err
+	}
+	return providers.LoadNodePools(data)
+}
+
+// costEstimator returns the pricing of a provider
+func costEstimator(provider, region, apiKey string) (providers.CostEstimator, error) {
+	cloudProvider, err := providers.NewProviderFactory().CreateProvider(providers.Provider{
+		Name:   provider,
+		Region: region,
+		Credentials: map[string]string{
+			"api_key": apiKey,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	estimator, ok := cloudProvider.(providers.CostEstimator)
+	if !ok {
+		return nil, fmt.Errorf("provider %s has no pricing catalog", provider)
+	}
+	return estimator, nil
+}
+
+// clusterCost prices the nodes a recorded cluster runs now
+func clusterCost(cluster *state.Cluster, apiKey string) (providers.CostEstimate, error) {
+	estimator, err := costEstimator(cluster.Provider, cluster.Region, apiKey)
+	if err != nil {
+		return providers.CostEstimate{}, err
+	}
+	return estimator.ClusterCost(cluster.Name)
+}
+
+// enforceBudget prints the estimated cost of spec and checks it against the
+// budget, less the run-rate of the other recorded clusters. In downsize mode
+// the resizable pools may move to cheaper sizes, which updates spec. Only
+// clusters on the same provider are priced, since apiKey is that provider's;
+// clusters that cannot be priced are left out with a warning.
+func enforceBudget(st *state.State, provider string, cloudProvider providers.CloudProvider, spec *providers.ClusterSpec, resizable []string, apiKey string) error {
+	estimator, ok := cloudProvider.(providers.CostEstimator)
+	if !ok {
+		if st.Budget != nil {
+			return fmt.Errorf("%w: the provider has no pricing catalog to check the budget with", providers.ErrOverBudget)
+		}
+		return nil
+	}
+
+	if st.Budget == nil {
+		estimate, err := estimator.EstimateCost(*spec)
+		if err != nil {
+			fmt.Printf("Could not estimate the cluster cost: %v\n", err)
+			return nil
+		}
+		printCostEstimate(os.Stdout, estimate)
+		return nil
+	}
+
+	others := 0.0
+	for _, name := range st.ClusterNames() {
+		cluster, _ := st.Cluster(name)
+		if name == spec.Name || cluster.Deleted() {
+			continue
+		}
+		switch cluster.Provider {
+		case provider:
+		case "":
+			fmt.Printf("Warning: cluster %s has no provider recorded and is not counted against the budget\n", name)
+			continue
+		default:
+			fmt.Printf("Warning: cluster %s runs on %s and is not counted against the budget\n", name, cluster.Provider)
+			continue
+		}
+		estimate, err := clusterCost(cluster, apiKey)
+		if err != nil {
+			fmt.Printf("Warning: cluster %s is not counted against the budget: %v\n", name, err)
+			continue
+		}
+		others += estimate.Total.Monthly
+	}
+
+	limit := st.Budget.Monthly - others
+	estimate, resizes, err := providers.FitBudget(spec, limit, st.Budget.Downsize, resizable, estimator)
+	if err != nil {
+		if errors.Is(err, providers.ErrOverBudget) {
+			return fmt.Errorf("%w (monthly budget %s, other clusters %s)", err, formatDollars(st.Budget.Monthly), formatDollars(others))
+		}
+		return err
+	}
+	for _, resize := range resizes {
+		fmt.Printf("Downsizing pool %s from %s to %s to stay within the budget\n", resize.Pool, resize.From, resize.To)
+	}
+	printCostEstimate(os.Stdout, estimate)
+	fmt.Printf("Monthly budget: %s, other clusters %s\n", formatDollars(st.Budget.Monthly), formatDollars(others))
+	return nil
+}
+
+// printCostEstimate prints the cost of each pool and the total
+func printCostEstimate(out io.Writer, estimate providers.CostEstimate) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POOL\tROLE\tNODES\tSIZE\tHOURLY\tMONTHLY")
+	for _, pool := range estimate.Pools {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", pool.Pool, pool.Role, pool.Count, pool.Size,
+			formatDollars(pool.Total.Hourly), formatDollars(pool.Total.Monthly))
+	}
+	fmt.Fprintf(w, "TOTAL\t\t\t\t%s\t%s\n", formatDollars(estimate.Total.Hourly), formatDollars(estimate.Total.Monthly))
+	w.Flush()
+}
+
+// formatDollars formats a price, with sub-cent precision for hourly prices
+func formatDollars(amount float64) string {
+	if amount != 0 && amount < 1 {
+		return fmt.Sprintf("$%.4f", amount)
+	}
+	return fmt.Sprintf("$%.2f", amount)
+}
+
+// confirm asks a yes/no question on the command's input; anything but yes is no
+func confirm(cmd *cobra.Command, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// stateStore returns the state store selected by the --state flag
+func stateStore(cmd *cobra.Command) *state.Store {
+	path, _ := cmd.Flags().GetString("state")
+	return state.NewStore(path)
+}
+
+// failoverIngress moves the shared ingress IP of each reported cluster off a
+// node that is not running or whose peer is down
+func failoverIngress(st *state.State, apiKey string, reports []network.ClusterPeerReport) {
+	for _, report := range reports {
+		cluster, ok := st.Cluster(report.Cluster)
+		if !ok || !cluster.IngressIP {
+			continue
+		}
+
+		cloudProvider, err := clusterProvider(cluster, apiKey)
+		if err != nil {
+			fmt.Printf("Ingress failover for %s: %v\n", cluster.Name, err)
+			continue
+		}
+		failover, ok := cloudProvider.(providers.IngressFailover)
+		if !ok {
+			fmt.Printf("Ingress failover for %s: not supported by provider %s\n", cluster.Name, cluster.Provider)
+			continue
+		}
+
+		down := map[string]bool{}
+		for _, peer := range report.Peers {
+			if peer.State == network.PeerDown {
+				down[peer.Label] = true
+			}
+		}
+		node, err := failover.FailoverIngress(cluster.Name, func(node providers.NodeStatus) bool {
+			return !down[node.Label]
+		})
+		if err != nil {
+			fmt.Printf("Ingress failover for %s: %v\n", cluster.Name, err)
+			continue
+		}
+		fmt.Printf("Ingress IP of %s is on %s\n", cluster.Name, node)
+	}
+}
+
+func printPeerReports(reports []network.ClusterPeerReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tTRANSPORT\tPEER\tENDPOINT\tSTATE\tLAST HANDSHAKE\tRX\tTX")
+
+	for _, report := range reports {
+		if report.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\terror: %s\t-\t-\t-\n", report.Cluster, report.Transport, report.Error)
+			continue
+		}
+		if len(report.Peers) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\tno peers\t-\t-\t-\n", report.Cluster, report.Transport)
+			continue
+		}
+
+		for _, peer := range report.Peers {
+			handshake := "never"
+			if !peer.LastHandshake.IsZero() {
+				handshake = report.CheckedAt.Sub(peer.LastHandshake).Round(time.Second).String() + " ago"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n", report.Cluster, report.Transport, peer.Label,
+				peer.Endpoint, peer.State, handshake, peer.ReceiveBytes, peer.TransmitBytes)
+		}
+	}
+
+	w.Flush()
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
+	os.Exit(exitCode)
 }
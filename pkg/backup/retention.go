@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"context"
+	"time"
+)
+
+// RetentionPolicy decides which snapshots are deleted after a backup. The
+// newest snapshot is always kept.
+type RetentionPolicy struct {
+	// Keep is the number of newest snapshots to keep; zero keeps all
+	Keep int
+	// MaxAge deletes snapshots older than this; zero disables it
+	MaxAge time.Duration
+}
+
+// Expired returns the snapshots the policy deletes. snapshots must be sorted
+// newest first, as returned by List.
+func (p RetentionPolicy) Expired(snapshots []Snapshot, now time.Time) []Snapshot {
+	var expired []Snapshot
+	for i, snapshot := range snapshots {
+		if i == 0 {
+			continue
+		}
+		if (p.Keep > 0 && i >= p.Keep) || (p.MaxAge > 0 && now.Sub(snapshot.CreatedAt) > p.MaxAge) {
+			expired = append(expired, snapshot)
+		}
+	}
+	return expired
+}
+
+// Prune deletes the snapshots of cluster expired by the policy and returns them
+func Prune(ctx context.Context, store Store, cluster string, policy RetentionPolicy, now time.Time) ([]Snapshot, error) {
+	snapshots, err := List(ctx, store, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := policy.Expired(snapshots, now)
+	for i, snapshot := range expired {
+		if err := store.Delete(ctx, snapshot.Key); err != nil {
+			return expired[:i], err
+		}
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	var snapshots []Snapshot
+	for days := 0; days < 5; days++ {
+		createdAt := now.Add(-time.Duration(days) * 24 * time.Hour)
+		snapshots = append(snapshots, Snapshot{Name: SnapshotName("cloud1", createdAt), CreatedAt: createdAt})
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   int
+	}{
+		{name: "keep all", policy: RetentionPolicy{}, want: 0},
+		{name: "keep last 3", policy: RetentionPolicy{Keep: 3}, want: 2},
+		{name: "max age", policy: RetentionPolicy{MaxAge: 36 * time.Hour}, want: 3},
+		{name: "both", policy: RetentionPolicy{Keep: 4, MaxAge: 72 * time.Hour}, want: 1},
+		{name: "newest is kept", policy: RetentionPolicy{Keep: 1, MaxAge: time.Minute}, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expired := tt.policy.Expired(snapshots, now)
+			if len(expired) != tt.want {
+				t.Errorf("Expired() returned %d snapshots, expected %d", len(expired), tt.want)
+			}
+			for _, snapshot := range expired {
+				if snapshot.Name == snapshots[0].Name {
+					t.Error("Expected the newest snapshot to be kept")
+				}
+			}
+		})
+	}
+
+	// the newest snapshot survives even when it is older than MaxAge
+	if expired := (RetentionPolicy{MaxAge: time.Hour}).Expired(snapshots[4:], now); len(expired) != 0 {
+		t.Errorf("Expected the only snapshot to be kept, got %d expired", len(expired))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	store := NewLocalStore(t.TempDir())
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	saveSnapshots(t, store, "cloud1", now, now.Add(-time.Hour), now.Add(-2*time.Hour))
+
+	pruned, err := Prune(context.Background(), store, "cloud1", RetentionPolicy{Keep: 2}, now)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(pruned) != 1 || !pruned[0].CreatedAt.Equal(now.Add(-2*time.Hour)) {
+		t.Errorf("Prune() = %v, expected the oldest snapshot", pruned)
+	}
+
+	remaining, err := List(context.Background(), store, "cloud1")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(remaining) != 2 {
+		t.Errorf("Expected 2 remaining snapshots, got %d", len(remaining))
+	}
+}
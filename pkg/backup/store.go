@@ -0,0 +1,176 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotTimeFormat is used in snapshot names so they sort chronologically
+const snapshotTimeFormat = "20060102T150405Z"
+
+const snapshotSuffix = ".db"
+
+// Store keeps etcd snapshots as objects addressed by slash separated keys
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Delete(ctx context.Context, key string) error
+	// Location describes where the store keeps objects, for messages
+	Location() string
+}
+
+// Object is a stored object
+type Object struct {
+	Key  string
+	Size int64
+}
+
+// Snapshot is a stored etcd snapshot of a cluster
+type Snapshot struct {
+	Cluster   string
+	Name      string
+	Key       string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// S3Options configures access to S3-compatible storage
+type S3Options struct {
+	Endpoint string
+	Region   string
+	// AccessKey and SecretKey default to AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY
+	AccessKey string
+	SecretKey string
+	Insecure  bool
+}
+
+// NewStore opens the store at location: s3://bucket/prefix for S3-compatible
+// storage, or a local directory
+func NewStore(location string, s3 S3Options) (Store, error) {
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid S3 location %q: no bucket", location)
+		}
+		return NewS3Store(bucket, prefix, s3)
+	}
+
+	if location == "" {
+		return nil, fmt.Errorf("no backup location")
+	}
+	return NewLocalStore(location), nil
+}
+
+// DefaultLocation returns the local backup directory, honouring
+// TALOS_AUTOEXTENDER_BACKUPS
+func DefaultLocation() string {
+	if location := os.Getenv("TALOS_AUTOEXTENDER_BACKUPS"); location != "" {
+		return location
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "talos-autoextender-backups"
+	}
+
+	return filepath.Join(home, ".talos-autoextender", "backups")
+}
+
+// SnapshotName returns the name of a snapshot of cluster taken at createdAt
+func SnapshotName(cluster string, createdAt time.Time) string {
+	return cluster + "-" + createdAt.UTC().Format(snapshotTimeFormat) + snapshotSuffix
+}
+
+// parseSnapshotName returns the creation time encoded in a snapshot name
+func parseSnapshotName(cluster, name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, cluster+"-")
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, snapshotSuffix)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	createdAt, err := time.Parse(snapshotTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
+
+// Save stores a snapshot of cluster read from r
+func Save(ctx context.Context, store Store, cluster string, r io.Reader, createdAt time.Time) (Snapshot, error) {
+	name := SnapshotName(cluster, createdAt)
+	key := path.Join(cluster, name)
+
+	size, err := store.Put(ctx, key, r)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to store snapshot %s: %v", name, err)
+	}
+
+	return Snapshot{Cluster: cluster, Name: name, Key: key, CreatedAt: createdAt.UTC(), Size: size}, nil
+}
+
+// List returns the snapshots of cluster, newest first
+func List(ctx context.Context, store Store, cluster string) ([]Snapshot, error) {
+	objects, err := store.List(ctx, cluster+"/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots in %s: %v", store.Location(), err)
+	}
+
+	var snapshots []Snapshot
+	for _, object := range objects {
+		name := path.Base(object.Key)
+		createdAt, ok := parseSnapshotName(cluster, name)
+		if !ok {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{
+			Cluster:   cluster,
+			Name:      name,
+			Key:       object.Key,
+			CreatedAt: createdAt,
+			Size:      object.Size,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+
+	return snapshots, nil
+}
+
+// Find returns the snapshot of cluster with the given name, or the newest
+// snapshot if name is "latest" or empty
+func Find(ctx context.Context, store Store, cluster, name string) (Snapshot, error) {
+	snapshots, err := List(ctx, store, cluster)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return Snapshot{}, fmt.Errorf("no snapshots of cluster %s in %s", cluster, store.Location())
+	}
+
+	if name == "" || name == "latest" {
+		return snapshots[0], nil
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name || snapshot.Name == name+snapshotSuffix {
+			return snapshot, nil
+		}
+	}
+
+	return Snapshot{}, fmt.Errorf("snapshot %s of cluster %s not found in %s", name, cluster, store.Location())
+}
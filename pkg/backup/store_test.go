@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func saveSnapshots(t *testing.T, store Store, cluster string, times ...time.Time) {
+	t.Helper()
+
+	for _, createdAt := range times {
+		if _, err := Save(context.Background(), store, cluster, strings.NewReader("snapshot"), createdAt); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+}
+
+func TestLocalStoreSaveAndFind(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(dir)
+	ctx := context.Background()
+
+	older := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	newer := older.Add(24 * time.Hour)
+	saveSnapshots(t, store, "cloud1", older, newer)
+	saveSnapshots(t, store, "cloud10", newer)
+
+	if err := os.WriteFile(filepath.Join(dir, "cloud1", "notes.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := List(ctx, store, "cloud1")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots of cloud1, got %d", len(snapshots))
+	}
+	if !snapshots[0].CreatedAt.Equal(newer) {
+		t.Errorf("Expected newest snapshot first, got %v", snapshots[0].CreatedAt)
+	}
+	if snapshots[0].Size != int64(len("snapshot")) {
+		t.Errorf("Expected size %d, got %d", len("snapshot"), snapshots[0].Size)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(snapshots[0].Key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected snapshot mode 0600, got %o", info.Mode().Perm())
+	}
+
+	tests := []struct {
+		name        string
+		want        time.Time
+		shouldError bool
+	}{
+		{name: "", want: newer},
+		{name: "latest", want: newer},
+		{name: SnapshotName("cloud1", older), want: older},
+		{name: strings.TrimSuffix(SnapshotName("cloud1", older), ".db"), want: older},
+		{name: "cloud1-20200101T000000Z.db", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		snapshot, err := Find(ctx, store, "cloud1", tt.name)
+		if (err != nil) != tt.shouldError {
+			t.Errorf("Find(%q) error = %v, shouldError %v", tt.name, err, tt.shouldError)
+			continue
+		}
+		if !tt.shouldError && !snapshot.CreatedAt.Equal(tt.want) {
+			t.Errorf("Find(%q) = %v, expected %v", tt.name, snapshot.CreatedAt, tt.want)
+		}
+	}
+
+	r, err := store.Open(ctx, snapshots[1].Key)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+	if data, _ := io.ReadAll(r); string(data) != "snapshot" {
+		t.Errorf("Expected snapshot contents, got %q", data)
+	}
+}
+
+func TestFindWithoutSnapshots(t *testing.T) {
+	store := NewLocalStore(filepath.Join(t.TempDir(), "missing"))
+
+	if _, err := Find(context.Background(), store, "cloud1", "latest"); err == nil {
+		t.Error("Find() error = nil, expected an error without snapshots")
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	tests := []struct {
+		location    string
+		want        string
+		shouldError bool
+	}{
+		{location: "/var/backups/talos", want: "/var/backups/talos"},
+		{location: "s3://backups/talos/etcd", want: "s3://backups/talos/etcd"},
+		{location: "s3://backups", want: "s3://backups"},
+		{location: "s3://", shouldError: true},
+		{location: "", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		store, err := NewStore(tt.location, S3Options{Endpoint: "minio.example.com:9000", AccessKey: "key", SecretKey: "secret"})
+		if (err != nil) != tt.shouldError {
+			t.Errorf("NewStore(%q) error = %v, shouldError %v", tt.location, err, tt.shouldError)
+			continue
+		}
+		if !tt.shouldError && store.Location() != tt.want {
+			t.Errorf("NewStore(%q).Location() = %q, expected %q", tt.location, store.Location(), tt.want)
+		}
+	}
+}
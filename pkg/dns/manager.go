@@ -3,6 +3,7 @@ package dns
 
 import (
 	"fmt"
+	"net/netip"
 )
 
 type DNSManager struct {
@@ -40,6 +41,10 @@ func (d *DNSManager) UpsertRecord(name, recordType, content string) error {
 		return fmt.Errorf("name, type, and content are required")
 	}
 
+	if err := validateAddress(recordType, content); err != nil {
+		return err
+	}
+
 	record := Record{
 		Name:    name,
 		Type:    recordType,
@@ -53,3 +58,57 @@ func (d *DNSManager) UpsertRecord(name, recordType, content string) error {
 func (d *DNSManager) ListRecords() ([]Record, error) {
 	return d.records, nil
 }
+
+// UpsertAddressRecords replaces the A and AAAA records for name with one record
+// per address, choosing the record type from each address family
+func (d *DNSManager) UpsertAddressRecords(name string, addresses []string) error {
+	if name == "" || len(addresses) == 0 {
+		return fmt.Errorf("name and at least one address are required")
+	}
+
+	records := make([]Record, 0, len(addresses))
+	for _, address := range addresses {
+		addr, err := netip.ParseAddr(address)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %v", address, err)
+		}
+
+		recordType := "A"
+		if !addr.Unmap().Is4() {
+			recordType = "AAAA"
+		}
+		records = append(records, Record{Name: name, Type: recordType, Content: addr.Unmap().String()})
+	}
+
+	kept := d.records[:0]
+	for _, record := range d.records {
+		if record.Name == name && (record.Type == "A" || record.Type == "AAAA") {
+			continue
+		}
+		kept = append(kept, record)
+	}
+	d.records = append(kept, records...)
+
+	return nil
+}
+
+// validateAddress checks that A records hold IPv4 and AAAA records hold IPv6 addresses
+func validateAddress(recordType, content string) error {
+	if recordType != "A" && recordType != "AAAA" {
+		return nil
+	}
+
+	addr, err := netip.ParseAddr(content)
+	if err != nil {
+		return fmt.Errorf("%s record content must be an IP address: %v", recordType, err)
+	}
+
+	if recordType == "A" && !addr.Unmap().Is4() {
+		return fmt.Errorf("A record content must be an IPv4 address, got %s", content)
+	}
+	if recordType == "AAAA" && (!addr.Is6() || addr.Is4In6()) {
+		return fmt.Errorf("AAAA record content must be an IPv6 address, got %s", content)
+	}
+
+	return nil
+}
@@ -182,6 +182,75 @@ func TestListRecords(t *testing.T) {
 	}
 }
 
+func TestUpsertRecordAddressValidation(t *testing.T) {
+	manager := NewDNSManager("cloudflare", "example.com")
+
+	tests := []struct {
+		name        string
+		recordType  string
+		content     string
+		shouldError bool
+	}{
+		{name: "A with IPv4", recordType: "A", content: "203.0.113.1", shouldError: false},
+		{name: "AAAA with IPv6", recordType: "AAAA", content: "2001:db8::1", shouldError: false},
+		{name: "A with IPv6", recordType: "A", content: "2001:db8::1", shouldError: true},
+		{name: "AAAA with IPv4", recordType: "AAAA", content: "203.0.113.1", shouldError: true},
+		{name: "AAAA with IPv4-mapped", recordType: "AAAA", content: "::ffff:203.0.113.1", shouldError: true},
+		{name: "A with hostname", recordType: "A", content: "www.example.com", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := manager.UpsertRecord("ingress", tt.recordType, tt.content)
+			if (err != nil) != tt.shouldError {
+				t.Errorf("UpsertRecord() error = %v, shouldError %v", err, tt.shouldError)
+			}
+		})
+	}
+}
+
+func TestUpsertAddressRecords(t *testing.T) {
+	manager := NewDNSManager("cloudflare", "example.com")
+
+	if err := manager.UpsertRecord("mail", "MX", "mail.example.com"); err != nil {
+		t.Fatalf("Failed to add record: %v", err)
+	}
+	if err := manager.UpsertAddressRecords("ingress", []string{"203.0.113.1", "2001:db8::1"}); err != nil {
+		t.Fatalf("UpsertAddressRecords() error = %v, expected nil", err)
+	}
+
+	// A second upsert replaces the previous address records
+	if err := manager.UpsertAddressRecords("ingress", []string{"203.0.113.2", "2001:db8::2"}); err != nil {
+		t.Fatalf("UpsertAddressRecords() error = %v, expected nil", err)
+	}
+
+	records, _ := manager.ListRecords()
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d: %v", len(records), records)
+	}
+
+	types := make(map[string]string)
+	for _, record := range records {
+		types[record.Type] = record.Content
+	}
+	if types["A"] != "203.0.113.2" {
+		t.Errorf("Expected A record 203.0.113.2, got %s", types["A"])
+	}
+	if types["AAAA"] != "2001:db8::2" {
+		t.Errorf("Expected AAAA record 2001:db8::2, got %s", types["AAAA"])
+	}
+	if types["MX"] != "mail.example.com" {
+		t.Error("Expected MX record to be preserved")
+	}
+
+	if err := manager.UpsertAddressRecords("ingress", []string{"not-an-ip"}); err == nil {
+		t.Error("Expected error for invalid address")
+	}
+	if err := manager.UpsertAddressRecords("ingress", nil); err == nil {
+		t.Error("Expected error for missing addresses")
+	}
+}
+
 // Test for future implementation of DNS provider integration
 func TestCloudflareIntegration(t *testing.T) {
 	t.Skip("Cloudflare integration not yet implemented")
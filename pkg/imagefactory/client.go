@@ -0,0 +1,119 @@
+package imagefactory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the public Talos Image Factory
+const DefaultURL = "https://factory.talos.dev"
+
+// Client registers schematics with an Image Factory and builds the URLs of
+// the images it serves for them
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+func NewClient(factoryURL string) *Client {
+	if factoryURL == "" {
+		factoryURL = DefaultURL
+	}
+
+	return &Client{
+		url:  strings.TrimRight(factoryURL, "/"),
+		http: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// URL returns the base URL of the factory
+func (c *Client) URL() string {
+	return c.url
+}
+
+// CreateSchematic registers the schematic so the factory serves images for
+// it and returns its ID. Registering an existing schematic is a no-op.
+func (c *Client) CreateSchematic(ctx context.Context, s Schematic) (string, error) {
+	body, err := s.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/schematics", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create schematic: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to create schematic: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode schematic response: %v", err)
+	}
+	if created.ID == "" {
+		return "", fmt.Errorf("factory returned an empty schematic ID")
+	}
+
+	return created.ID, nil
+}
+
+// InstallerImage returns the installer image reference for a schematic, used
+// for installs and upgrades
+func (c *Client) InstallerImage(schematicID, version string) string {
+	host := c.url
+	if u, err := url.Parse(c.url); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return fmt.Sprintf("%s/installer/%s:%s", host, schematicID, normalizeVersion(version))
+}
+
+// DiskImageURL returns the URL of a disk image for a platform, e.g.
+// DiskImageURL(id, "v1.7.0", "akamai", "amd64", "raw.gz")
+func (c *Client) DiskImageURL(schematicID, version, platform, arch, format string) string {
+	return fmt.Sprintf("%s/image/%s/%s/%s-%s.%s", c.url, schematicID, normalizeVersion(version), platform, arch, format)
+}
+
+// Download opens a disk image for reading
+func (c *Client) Download(ctx context.Context, imageURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Images are built on demand and can be large, so the download is only
+	// bounded by ctx
+	download := &http.Client{Transport: c.http.Transport}
+	resp, err := download.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", imageURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %s", imageURL, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+func normalizeVersion(version string) string {
+	return "v" + strings.TrimPrefix(version, "v")
+}
@@ -0,0 +1,61 @@
+package imagefactory
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateSchematic(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/schematics" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"ce4c980550dd2ab1b17bbf2b08801c7eb59418eafe8f279833297925d67c7515"}`))
+	}))
+	defer server.Close()
+
+	schematic, _ := NewSchematic([]string{"qemu-guest-agent"}, nil)
+	id, err := NewClient(server.URL).CreateSchematic(context.Background(), schematic)
+	if err != nil {
+		t.Fatalf("CreateSchematic() error = %v", err)
+	}
+
+	if want, _ := schematic.ID(); id != want {
+		t.Errorf("CreateSchematic() = %s, expected %s", id, want)
+	}
+	if want := "customization:\n    systemExtensions:\n        officialExtensions:\n            - siderolabs/qemu-guest-agent\n"; received != want {
+		t.Errorf("Expected schematic body %q, got %q", want, received)
+	}
+}
+
+func TestCreateSchematicError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown extension", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	schematic, _ := NewSchematic([]string{"no-such-extension"}, nil)
+	if _, err := NewClient(server.URL).CreateSchematic(context.Background(), schematic); err == nil {
+		t.Error("CreateSchematic() error = nil, expected an error")
+	}
+}
+
+func TestImageReferences(t *testing.T) {
+	c := NewClient("")
+	id := "ce4c980550dd2ab1b17bbf2b08801c7eb59418eafe8f279833297925d67c7515"
+
+	if got, want := c.InstallerImage(id, "1.7.0"), "factory.talos.dev/installer/"+id+":v1.7.0"; got != want {
+		t.Errorf("InstallerImage() = %s, expected %s", got, want)
+	}
+	if got, want := c.DiskImageURL(id, "v1.7.0", "akamai", "amd64", "raw.gz"), "https://factory.talos.dev/image/"+id+"/v1.7.0/akamai-amd64.raw.gz"; got != want {
+		t.Errorf("DiskImageURL() = %s, expected %s", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+package imagefactory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// officialPrefix is prepended to extension names given without a namespace
+const officialPrefix = "siderolabs/"
+
+// Schematic describes the customization of a Talos image. Its ID is the
+// SHA-256 of its YAML encoding, the same way the Image Factory computes it.
+type Schematic struct {
+	Customization Customization `yaml:"customization"`
+}
+
+// Customization holds the extra kernel arguments and system extensions
+type Customization struct {
+	ExtraKernelArgs  []string         `yaml:"extraKernelArgs,omitempty"`
+	SystemExtensions SystemExtensions `yaml:"systemExtensions,omitempty"`
+}
+
+// SystemExtensions lists the official extensions baked into the image
+type SystemExtensions struct {
+	OfficialExtensions []string `yaml:"officialExtensions,omitempty"`
+}
+
+// NewSchematic creates a schematic for extensions and kernel args. Extensions
+// may omit the siderolabs/ namespace, e.g. qemu-guest-agent, and are sorted so
+// the same set always yields the same ID. Kernel args keep their order.
+func NewSchematic(extensions, kernelArgs []string) (Schematic, error) {
+	var s Schematic
+
+	seen := map[string]bool{}
+	for _, extension := range extensions {
+		extension = strings.TrimSpace(extension)
+		if extension == "" {
+			return Schematic{}, fmt.Errorf("empty system extension name")
+		}
+		if !strings.Contains(extension, "/") {
+			extension = officialPrefix + extension
+		}
+		if !seen[extension] {
+			seen[extension] = true
+			s.Customization.SystemExtensions.OfficialExtensions = append(s.Customization.SystemExtensions.OfficialExtensions, extension)
+		}
+	}
+	sort.Strings(s.Customization.SystemExtensions.OfficialExtensions)
+
+	for _, arg := range kernelArgs {
+		arg = strings.TrimSpace(arg)
+		if arg == "" {
+			return Schematic{}, fmt.Errorf("empty kernel argument")
+		}
+		s.Customization.ExtraKernelArgs = append(s.Customization.ExtraKernelArgs, arg)
+	}
+
+	return s, nil
+}
+
+// IsZero reports whether the schematic does not customize the image
+func (s Schematic) IsZero() bool {
+	return len(s.Customization.ExtraKernelArgs) == 0 && len(s.Customization.SystemExtensions.OfficialExtensions) == 0
+}
+
+// Marshal encodes the schematic as the Image Factory expects it
+func (s Schematic) Marshal() ([]byte, error) {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schematic: %v", err)
+	}
+	return out, nil
+}
+
+// ID returns the schematic ID
+func (s Schematic) ID() (string, error) {
+	data, err := s.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,80 @@
+package imagefactory
+
+import (
+	"testing"
+)
+
+func TestSchematicID(t *testing.T) {
+	tests := []struct {
+		name        string
+		extensions  []string
+		kernelArgs  []string
+		want        string
+		shouldError bool
+	}{
+		{
+			name: "vanilla",
+			want: "376567988ad370138ad8b2698212367b8edcb69b5fd68c80be1f2ec7d603b4ba",
+		},
+		{
+			name:       "qemu guest agent",
+			extensions: []string{"qemu-guest-agent"},
+			want:       "ce4c980550dd2ab1b17bbf2b08801c7eb59418eafe8f279833297925d67c7515",
+		},
+		{
+			name:       "namespaced and duplicate",
+			extensions: []string{"siderolabs/qemu-guest-agent", "qemu-guest-agent"},
+			want:       "ce4c980550dd2ab1b17bbf2b08801c7eb59418eafe8f279833297925d67c7515",
+		},
+		{
+			name:        "empty extension",
+			extensions:  []string{" "},
+			shouldError: true,
+		},
+		{
+			name:        "empty kernel arg",
+			kernelArgs:  []string{""},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schematic, err := NewSchematic(tt.extensions, tt.kernelArgs)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("NewSchematic() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if tt.shouldError {
+				return
+			}
+
+			id, err := schematic.ID()
+			if err != nil {
+				t.Fatalf("ID() error = %v", err)
+			}
+			if id != tt.want {
+				t.Errorf("ID() = %s, expected %s", id, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchematicOrdering(t *testing.T) {
+	a, _ := NewSchematic([]string{"iscsi-tools", "qemu-guest-agent"}, []string{"console=ttyS0,19200n8", "net.ifnames=0"})
+	b, _ := NewSchematic([]string{"qemu-guest-agent", "iscsi-tools"}, []string{"console=ttyS0,19200n8", "net.ifnames=0"})
+	c, _ := NewSchematic([]string{"qemu-guest-agent", "iscsi-tools"}, []string{"net.ifnames=0", "console=ttyS0,19200n8"})
+
+	idA, _ := a.ID()
+	idB, _ := b.ID()
+	idC, _ := c.ID()
+
+	if idA != idB {
+		t.Error("Expected extension order not to change the schematic ID")
+	}
+	if idA == idC {
+		t.Error("Expected kernel argument order to change the schematic ID")
+	}
+	if a.IsZero() {
+		t.Error("Expected customized schematic not to be zero")
+	}
+}
@@ -0,0 +1,324 @@
+package network
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+)
+
+// HomeCluster is the name the CIDR planner records the home cluster under
+const HomeCluster = "home"
+
+// Defaults for ranges allocated for new cloud clusters
+const (
+	DefaultSupernet         = "10.128.0.0/9"
+	DefaultPodPrefixLen     = 16
+	DefaultServicePrefixLen = 20
+)
+
+// Talos default cluster networks, assumed for the home cluster until recorded
+const (
+	TalosDefaultPodCIDR     = "10.244.0.0/16"
+	TalosDefaultServiceCIDR = "10.96.0.0/12"
+)
+
+// ClusterNetworks are the address ranges used by one cluster
+type ClusterNetworks struct {
+	Pods     []netip.Prefix
+	Services []netip.Prefix
+	Nodes    []netip.Prefix
+}
+
+// ParseNetworks parses pod, service and node CIDRs
+func ParseNetworks(pods, services, nodes []string) (ClusterNetworks, error) {
+	var networks ClusterNetworks
+	var err error
+
+	if networks.Pods, err = parsePrefixes(pods); err != nil {
+		return ClusterNetworks{}, fmt.Errorf("invalid pod CIDR: %v", err)
+	}
+	if networks.Services, err = parsePrefixes(services); err != nil {
+		return ClusterNetworks{}, fmt.Errorf("invalid service CIDR: %v", err)
+	}
+	if networks.Nodes, err = parsePrefixes(nodes); err != nil {
+		return ClusterNetworks{}, fmt.Errorf("invalid node CIDR: %v", err)
+	}
+
+	return networks, nil
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		if prefix.Masked() != prefix {
+			return nil, fmt.Errorf("%s has host bits set, did you mean %s?", cidr, prefix.Masked())
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// Strings returns the pod, service and node CIDRs as strings
+func (n ClusterNetworks) Strings() (pods, services, nodes []string) {
+	return prefixStrings(n.Pods), prefixStrings(n.Services), prefixStrings(n.Nodes)
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	if len(prefixes) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		out = append(out, prefix.String())
+	}
+	return out
+}
+
+// namedRange is one range of a cluster, tagged with its kind
+type namedRange struct {
+	cluster string
+	kind    string
+	prefix  netip.Prefix
+}
+
+func (n ClusterNetworks) ranges(cluster string) []namedRange {
+	var ranges []namedRange
+	for _, prefix := range n.Pods {
+		ranges = append(ranges, namedRange{cluster, "pods", prefix})
+	}
+	for _, prefix := range n.Services {
+		ranges = append(ranges, namedRange{cluster, "services", prefix})
+	}
+	for _, prefix := range n.Nodes {
+		ranges = append(ranges, namedRange{cluster, "nodes", prefix})
+	}
+	return ranges
+}
+
+// CIDRConflict is a pair of overlapping ranges
+type CIDRConflict struct {
+	Cluster      string
+	Kind         string
+	CIDR         netip.Prefix
+	OtherCluster string
+	OtherKind    string
+	OtherCIDR    netip.Prefix
+}
+
+func (c CIDRConflict) String() string {
+	return fmt.Sprintf("%s %s %s overlaps %s %s %s", c.Cluster, c.Kind, c.CIDR, c.OtherCluster, c.OtherKind, c.OtherCIDR)
+}
+
+// CIDRPlanner records the ranges of the home cluster and every cloud cluster so
+// pod-to-pod routing across the mesh stays unambiguous
+type CIDRPlanner struct {
+	supernet         netip.Prefix
+	PodPrefixLen     int
+	ServicePrefixLen int
+	clusters         map[string]ClusterNetworks
+}
+
+// NewCIDRPlanner creates a planner allocating new ranges from supernet
+func NewCIDRPlanner(supernet string) (*CIDRPlanner, error) {
+	prefix, err := netip.ParsePrefix(supernet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid supernet: %v", err)
+	}
+
+	return &CIDRPlanner{
+		supernet:         prefix.Masked(),
+		PodPrefixLen:     DefaultPodPrefixLen,
+		ServicePrefixLen: DefaultServicePrefixLen,
+		clusters:         make(map[string]ClusterNetworks),
+	}, nil
+}
+
+// Record stores the ranges of a cluster, replacing any previous record
+func (p *CIDRPlanner) Record(cluster string, networks ClusterNetworks) {
+	p.clusters[cluster] = networks
+}
+
+// Networks returns the recorded ranges of a cluster
+func (p *CIDRPlanner) Networks(cluster string) (ClusterNetworks, bool) {
+	networks, ok := p.clusters[cluster]
+	return networks, ok
+}
+
+// Conflicts returns every overlap between the given ranges for cluster and the
+// ranges of all other recorded clusters, as well as overlaps within cluster
+func (p *CIDRPlanner) Conflicts(cluster string, networks ClusterNetworks) []CIDRConflict {
+	own := networks.ranges(cluster)
+
+	var conflicts []CIDRConflict
+	for i, a := range own {
+		// Overlaps between the cluster's own pod, service and node ranges
+		for _, b := range own[i+1:] {
+			if a.prefix.Overlaps(b.prefix) {
+				conflicts = append(conflicts, newConflict(a, b))
+			}
+		}
+
+		for _, name := range p.clusterNames() {
+			if name == cluster {
+				continue
+			}
+			for _, b := range p.clusters[name].ranges(name) {
+				if a.prefix.Overlaps(b.prefix) {
+					conflicts = append(conflicts, newConflict(a, b))
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// AllConflicts returns the overlaps between every pair of recorded clusters
+func (p *CIDRPlanner) AllConflicts() []CIDRConflict {
+	var conflicts []CIDRConflict
+	seen := make(map[string]bool)
+
+	for _, name := range p.clusterNames() {
+		for _, conflict := range p.Conflicts(name, p.clusters[name]) {
+			key := conflict.String()
+			reverse := newConflict(
+				namedRange{conflict.OtherCluster, conflict.OtherKind, conflict.OtherCIDR},
+				namedRange{conflict.Cluster, conflict.Kind, conflict.CIDR},
+			).String()
+			if seen[key] || seen[reverse] {
+				continue
+			}
+			seen[key] = true
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	return conflicts
+}
+
+func newConflict(a, b namedRange) CIDRConflict {
+	return CIDRConflict{
+		Cluster:      a.cluster,
+		Kind:         a.kind,
+		CIDR:         a.prefix,
+		OtherCluster: b.cluster,
+		OtherKind:    b.kind,
+		OtherCIDR:    b.prefix,
+	}
+}
+
+// Allocate picks pod and service ranges for a new cluster from the supernet
+// that do not overlap any recorded range, and records them together with the
+// given node ranges
+func (p *CIDRPlanner) Allocate(cluster string, nodes []netip.Prefix) (ClusterNetworks, error) {
+	used := p.usedPrefixes(cluster)
+	used = append(used, nodes...)
+
+	pods, err := nextFree(p.supernet, p.PodPrefixLen, used)
+	if err != nil {
+		return ClusterNetworks{}, fmt.Errorf("no free pod range: %v", err)
+	}
+	used = append(used, pods)
+
+	services, err := nextFree(p.supernet, p.ServicePrefixLen, used)
+	if err != nil {
+		return ClusterNetworks{}, fmt.Errorf("no free service range: %v", err)
+	}
+
+	networks := ClusterNetworks{
+		Pods:     []netip.Prefix{pods},
+		Services: []netip.Prefix{services},
+		Nodes:    nodes,
+	}
+	p.Record(cluster, networks)
+
+	return networks, nil
+}
+
+// AllocateNodes picks a node range of the given size from within, such as a
+// VPC subnet, that does not overlap any range recorded for other clusters.
+// Nothing is recorded; the range is passed on to Allocate or Record.
+func (p *CIDRPlanner) AllocateNodes(cluster string, within netip.Prefix, bits int) (netip.Prefix, error) {
+	nodes, err := nextFree(within.Masked(), bits, p.usedPrefixes(cluster))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("no free node range: %v", err)
+	}
+	return nodes, nil
+}
+
+// nextFree returns the first aligned block of the given size in supernet that
+// does not overlap any used prefix
+func nextFree(supernet netip.Prefix, bits int, used []netip.Prefix) (netip.Prefix, error) {
+	if bits < supernet.Bits() || bits > supernet.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("cannot fit a /%d into supernet %s", bits, supernet)
+	}
+
+	candidate := netip.PrefixFrom(supernet.Addr(), bits)
+	for supernet.Contains(candidate.Addr()) {
+		free := true
+		for _, prefix := range used {
+			if candidate.Overlaps(prefix) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return candidate, nil
+		}
+
+		next, ok := nextPrefix(candidate)
+		if !ok {
+			break
+		}
+		candidate = next
+	}
+
+	return netip.Prefix{}, fmt.Errorf("supernet %s is exhausted", supernet)
+}
+
+// nextPrefix returns the block of the same size directly after prefix
+func nextPrefix(prefix netip.Prefix) (netip.Prefix, bool) {
+	addr := prefix.Addr().AsSlice()
+	bits := prefix.Bits()
+
+	// Add 1 at the last bit of the network part, carrying towards the front
+	byteIndex := (bits - 1) / 8
+	increment := byte(1) << (7 - uint((bits-1)%8))
+	for i := byteIndex; i >= 0; i-- {
+		sum := uint16(addr[i]) + uint16(increment)
+		addr[i] = byte(sum)
+		if sum <= 0xff {
+			next, _ := netip.AddrFromSlice(addr)
+			return netip.PrefixFrom(next, bits), true
+		}
+		increment = 1
+	}
+
+	return netip.Prefix{}, false
+}
+
+func (p *CIDRPlanner) usedPrefixes(except string) []netip.Prefix {
+	var used []netip.Prefix
+	for name, networks := range p.clusters {
+		if name == except {
+			continue
+		}
+		for _, r := range networks.ranges(name) {
+			used = append(used, r.prefix)
+		}
+	}
+	return used
+}
+
+func (p *CIDRPlanner) clusterNames() []string {
+	names := make([]string, 0, len(p.clusters))
+	for name := range p.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,257 @@
+package network
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func mustNetworks(t *testing.T, pods, services, nodes []string) ClusterNetworks {
+	t.Helper()
+	networks, err := ParseNetworks(pods, services, nodes)
+	if err != nil {
+		t.Fatalf("ParseNetworks() error = %v", err)
+	}
+	return networks
+}
+
+func TestParseNetworks(t *testing.T) {
+	tests := []struct {
+		name        string
+		pods        []string
+		shouldError bool
+	}{
+		{name: "valid", pods: []string{"10.244.0.0/16", "fd00:10:244::/56"}},
+		{name: "not a CIDR", pods: []string{"10.244.0.0"}, shouldError: true},
+		{name: "host bits set", pods: []string{"10.244.1.0/16"}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseNetworks(tt.pods, nil, nil)
+			if (err != nil) != tt.shouldError {
+				t.Errorf("ParseNetworks() error = %v, shouldError %v", err, tt.shouldError)
+			}
+		})
+	}
+}
+
+func TestCIDRPlannerConflicts(t *testing.T) {
+	planner, err := NewCIDRPlanner(DefaultSupernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planner.Record(HomeCluster, mustNetworks(t, []string{"10.244.0.0/16"}, []string{"10.96.0.0/12"}, []string{"192.168.1.0/24"}))
+	planner.Record("cloud1", mustNetworks(t, []string{"10.128.0.0/16"}, []string{"10.129.0.0/20"}, nil))
+
+	tests := []struct {
+		name      string
+		networks  ClusterNetworks
+		conflicts int
+	}{
+		{
+			name:      "no overlap",
+			networks:  mustNetworks(t, []string{"10.130.0.0/16"}, []string{"10.131.0.0/20"}, []string{"192.168.128.0/17"}),
+			conflicts: 0,
+		},
+		{
+			name:      "pods overlap home pods",
+			networks:  mustNetworks(t, []string{"10.244.0.0/16"}, []string{"10.131.0.0/20"}, nil),
+			conflicts: 1,
+		},
+		{
+			name:      "services inside home service range",
+			networks:  mustNetworks(t, []string{"10.130.0.0/16"}, []string{"10.100.0.0/20"}, nil),
+			conflicts: 1,
+		},
+		{
+			name:      "pods overlap another cloud cluster and home nodes",
+			networks:  mustNetworks(t, []string{"10.128.0.0/16", "192.168.0.0/16"}, []string{"10.131.0.0/20"}, nil),
+			conflicts: 2,
+		},
+		{
+			name:      "pods overlap own services",
+			networks:  mustNetworks(t, []string{"10.130.0.0/16"}, []string{"10.130.16.0/20"}, nil),
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts := planner.Conflicts("cloud2", tt.networks)
+			if len(conflicts) != tt.conflicts {
+				t.Errorf("Expected %d conflicts, got %v", tt.conflicts, conflicts)
+			}
+		})
+	}
+
+	// A cluster never conflicts with its own previous record
+	cloud1, _ := planner.Networks("cloud1")
+	if conflicts := planner.Conflicts("cloud1", cloud1); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts with own record, got %v", conflicts)
+	}
+}
+
+func TestCIDRPlannerAllConflicts(t *testing.T) {
+	planner, _ := NewCIDRPlanner(DefaultSupernet)
+	planner.Record(HomeCluster, mustNetworks(t, []string{"10.244.0.0/16"}, []string{"10.96.0.0/12"}, nil))
+	planner.Record("cloud1", mustNetworks(t, []string{"10.244.0.0/16"}, []string{"10.129.0.0/20"}, nil))
+
+	conflicts := planner.AllConflicts()
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %v", conflicts)
+	}
+	if conflicts[0].String() != "cloud1 pods 10.244.0.0/16 overlaps home pods 10.244.0.0/16" {
+		t.Errorf("Unexpected conflict: %s", conflicts[0])
+	}
+}
+
+func TestCIDRPlannerAllocate(t *testing.T) {
+	planner, _ := NewCIDRPlanner(DefaultSupernet)
+	planner.Record(HomeCluster, mustNetworks(t, []string{"10.244.0.0/16"}, []string{"10.96.0.0/12"}, nil))
+	planner.Record("cloud1", mustNetworks(t, []string{"10.128.0.0/16"}, []string{"10.129.0.0/20"}, nil))
+
+	networks, err := planner.Allocate("cloud2", nil)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v, expected nil", err)
+	}
+
+	if networks.Pods[0] != netip.MustParsePrefix("10.130.0.0/16") {
+		t.Errorf("Expected pods 10.130.0.0/16, got %s", networks.Pods[0])
+	}
+	if networks.Services[0] != netip.MustParsePrefix("10.129.16.0/20") {
+		t.Errorf("Expected services 10.129.16.0/20, got %s", networks.Services[0])
+	}
+
+	if _, ok := planner.Networks("cloud2"); !ok {
+		t.Error("Expected allocated networks to be recorded")
+	}
+	if conflicts := planner.AllConflicts(); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts after allocation, got %v", conflicts)
+	}
+
+	// The next allocation avoids everything recorded so far
+	next, err := planner.Allocate("cloud3", nil)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v, expected nil", err)
+	}
+	if next.Pods[0] != netip.MustParsePrefix("10.131.0.0/16") {
+		t.Errorf("Expected pods 10.131.0.0/16, got %s", next.Pods[0])
+	}
+}
+
+func TestCIDRPlannerAllocateSkipsHomeRanges(t *testing.T) {
+	// The supernet contains the home pod range, which must be skipped
+	planner, _ := NewCIDRPlanner("10.244.0.0/14")
+	planner.Record(HomeCluster, mustNetworks(t, []string{"10.244.0.0/16"}, nil, nil))
+
+	networks, err := planner.Allocate("cloud1", nil)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v, expected nil", err)
+	}
+	if networks.Pods[0] != netip.MustParsePrefix("10.245.0.0/16") {
+		t.Errorf("Expected pods 10.245.0.0/16, got %s", networks.Pods[0])
+	}
+	if networks.Services[0] != netip.MustParsePrefix("10.246.0.0/20") {
+		t.Errorf("Expected services 10.246.0.0/20, got %s", networks.Services[0])
+	}
+}
+
+func TestCIDRPlannerAllocateExhausted(t *testing.T) {
+	// Only one /16 is left after the home pods, leaving no room for services
+	planner, _ := NewCIDRPlanner("10.244.0.0/15")
+	planner.Record(HomeCluster, mustNetworks(t, []string{"10.244.0.0/16"}, nil, nil))
+
+	if _, err := planner.Allocate("cloud1", nil); err == nil {
+		t.Error("Expected error when the supernet is exhausted")
+	}
+	if _, ok := planner.Networks("cloud1"); ok {
+		t.Error("Expected nothing to be recorded when allocation fails")
+	}
+}
+
+func TestCIDRPlannerAllocateNodes(t *testing.T) {
+	planner, _ := NewCIDRPlanner(DefaultSupernet)
+	planner.Record("cloud1", mustNetworks(t, []string{"10.128.0.0/16"}, []string{"10.129.0.0/20"}, []string{"172.30.0.0/24"}))
+	planner.Record("cloud2", mustNetworks(t, nil, nil, []string{"172.30.2.0/24"}))
+	within := netip.MustParsePrefix("172.30.0.0/16")
+
+	tests := []struct {
+		name        string
+		cluster     string
+		bits        int
+		want        string
+		shouldError bool
+	}{
+		{
+			name:    "skips the ranges of other clusters",
+			cluster: "cloud3",
+			bits:    24,
+			want:    "172.30.1.0/24",
+		},
+		{
+			name:    "reuses the cluster's own range",
+			cluster: "cloud1",
+			bits:    24,
+			want:    "172.30.0.0/24",
+		},
+		{
+			name:        "larger than the range",
+			cluster:     "cloud3",
+			bits:        12,
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := planner.AllocateNodes(tt.cluster, within, tt.bits)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("AllocateNodes() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("AllocateNodes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := planner.Networks("cloud3"); ok {
+		t.Error("Expected AllocateNodes not to record anything")
+	}
+}
+
+func TestNextPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+		ok     bool
+	}{
+		{prefix: "10.128.0.0/16", want: "10.129.0.0/16", ok: true},
+		{prefix: "10.128.240.0/20", want: "10.129.0.0/20", ok: true},
+		{prefix: "fd00::/64", want: "fd00:0:0:1::/64", ok: true},
+		{prefix: "255.255.0.0/16", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			next, ok := nextPrefix(netip.MustParsePrefix(tt.prefix))
+			if ok != tt.ok {
+				t.Fatalf("nextPrefix() ok = %v, expected %v", ok, tt.ok)
+			}
+			if ok && next.String() != tt.want {
+				t.Errorf("nextPrefix() = %s, expected %s", next, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCIDRPlannerInvalidSupernet(t *testing.T) {
+	if _, err := NewCIDRPlanner("not-a-cidr"); err == nil {
+		t.Error("Expected error for invalid supernet")
+	}
+
+	planner, _ := NewCIDRPlanner("10.0.0.0/24")
+	if _, err := planner.Allocate("cloud1", nil); err == nil {
+		t.Error("Expected error when the pod prefix does not fit the supernet")
+	}
+}
@@ -0,0 +1,174 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// AddressFamily identifies the IP version of an endpoint
+type AddressFamily string
+
+const (
+	IPv4 AddressFamily = "ipv4"
+	IPv6 AddressFamily = "ipv6"
+	// Unresolved is used for endpoints given as hostnames
+	Unresolved AddressFamily = "hostname"
+)
+
+// FamilyPreference selects which address family KubeSpan should use between clusters
+type FamilyPreference string
+
+const (
+	// PreferAuto uses IPv6 when both sides have it and falls back to IPv4
+	PreferAuto FamilyPreference = "auto"
+	PreferIPv4 FamilyPreference = "ipv4"
+	PreferIPv6 FamilyPreference = "ipv6"
+	// PreferDual lets KubeSpan use both families
+	PreferDual FamilyPreference = "dual"
+)
+
+// Endpoint is a parsed host:port pair
+type Endpoint struct {
+	Host string
+	Port string
+	// Addr is set when Host is an IP literal
+	Addr netip.Addr
+}
+
+// ParseEndpoint parses a host:port string. IPv6 literals must be bracketed.
+func ParseEndpoint(endpoint string) (Endpoint, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("invalid endpoint format: %v", err)
+	}
+
+	if host == "" || port == "" {
+		return Endpoint{}, fmt.Errorf("both host and port are required")
+	}
+
+	ep := Endpoint{Host: host, Port: port}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		ep.Addr = addr.Unmap()
+	}
+
+	return ep, nil
+}
+
+// ParseEndpoints parses a list of host:port strings
+func ParseEndpoints(endpoints []string) ([]Endpoint, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("at least one endpoint is required")
+	}
+
+	parsed := make([]Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		ep, err := ParseEndpoint(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", endpoint, err)
+		}
+		parsed = append(parsed, ep)
+	}
+
+	return parsed, nil
+}
+
+// Family returns the address family of the endpoint
+func (e Endpoint) Family() AddressFamily {
+	switch {
+	case !e.Addr.IsValid():
+		return Unresolved
+	case e.Addr.Is4():
+		return IPv4
+	default:
+		return IPv6
+	}
+}
+
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, e.Port)
+}
+
+// Families reports which IP families are present in a set of endpoints
+func Families(endpoints []Endpoint) map[AddressFamily]bool {
+	families := make(map[AddressFamily]bool)
+	for _, ep := range endpoints {
+		families[ep.Family()] = true
+	}
+	return families
+}
+
+// SelectFamily picks the address family KubeSpan should use between two sides.
+// With PreferAuto IPv6 wins when both sides have an IPv6 endpoint.
+func SelectFamily(local, remote []Endpoint, preference FamilyPreference) (AddressFamily, error) {
+	localFamilies := Families(local)
+	remoteFamilies := Families(remote)
+	common := func(family AddressFamily) bool {
+		return localFamilies[family] && remoteFamilies[family]
+	}
+
+	switch preference {
+	case PreferIPv4, PreferIPv6:
+		family := AddressFamily(preference)
+		if !common(family) {
+			return "", fmt.Errorf("both sides need an %s endpoint", family)
+		}
+		return family, nil
+	case PreferAuto, PreferDual, "":
+		if common(IPv6) {
+			return IPv6, nil
+		}
+		if common(IPv4) {
+			return IPv4, nil
+		}
+		// Hostnames may resolve to either family, so let KubeSpan decide
+		if localFamilies[Unresolved] || remoteFamilies[Unresolved] {
+			return Unresolved, nil
+		}
+		return "", fmt.Errorf("home and cloud endpoints share no address family")
+	default:
+		return "", fmt.Errorf("unknown address family preference: %s", preference)
+	}
+}
+
+// SelectEndpoint returns the first endpoint of the given family, falling back to
+// the first endpoint if none matches
+func SelectEndpoint(endpoints []Endpoint, family AddressFamily) Endpoint {
+	for _, ep := range endpoints {
+		if ep.Family() == family {
+			return ep
+		}
+	}
+	return endpoints[0]
+}
+
+// Public endpoint filters for KubeSpan. Private and link-local ranges are
+// excluded so peers do not waste handshakes on addresses they cannot reach.
+var (
+	ipv4EndpointFilters = []string{"0.0.0.0/0", "!10.0.0.0/8", "!172.16.0.0/12", "!192.168.0.0/16", "!100.64.0.0/10", "!169.254.0.0/16"}
+	ipv6EndpointFilters = []string{"::/0", "!fc00::/7", "!fe80::/10"}
+)
+
+// EndpointFilters returns the KubeSpan endpoint filters for the selected family.
+// An explicit IPv4 or IPv6 preference restricts KubeSpan to that family; with
+// PreferAuto both families stay allowed, the selected one listed first, so peers
+// with only the other family remain reachable. PreferDual and unresolved
+// endpoints list IPv6 first.
+func EndpointFilters(family AddressFamily, preference FamilyPreference) []string {
+	var filters []string
+
+	switch {
+	case preference == PreferIPv4:
+		filters = append(filters, ipv4EndpointFilters...)
+	case preference == PreferIPv6:
+		filters = append(filters, ipv6EndpointFilters...)
+	case family == IPv4 && preference != PreferDual:
+		filters = append(filters, ipv4EndpointFilters...)
+		filters = append(filters, ipv6EndpointFilters...)
+	default:
+		filters = append(filters, ipv6EndpointFilters...)
+		filters = append(filters, ipv4EndpointFilters...)
+	}
+
+	return filters
+}
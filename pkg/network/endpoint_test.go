@@ -0,0 +1,120 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		endpoint    string
+		family      AddressFamily
+		shouldError bool
+	}{
+		{name: "ipv4", endpoint: "192.168.1.1:50000", family: IPv4},
+		{name: "ipv6", endpoint: "[2001:db8::1]:50000", family: IPv6},
+		{name: "ipv4-mapped ipv6", endpoint: "[::ffff:10.0.0.1]:50000", family: IPv4},
+		{name: "hostname", endpoint: "home.example.com:50000", family: Unresolved},
+		{name: "unbracketed ipv6", endpoint: "2001:db8::1:50000", shouldError: true},
+		{name: "missing port", endpoint: "192.168.1.1", shouldError: true},
+		{name: "empty host", endpoint: ":50000", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep, err := ParseEndpoint(tt.endpoint)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("ParseEndpoint() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if err == nil && ep.Family() != tt.family {
+				t.Errorf("Family() = %s, expected %s", ep.Family(), tt.family)
+			}
+		})
+	}
+}
+
+func TestEndpointString(t *testing.T) {
+	ep, err := ParseEndpoint("[2001:db8::1]:50000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.String() != "[2001:db8::1]:50000" {
+		t.Errorf("Expected bracketed IPv6 endpoint, got %s", ep.String())
+	}
+}
+
+func TestSelectFamily(t *testing.T) {
+	v4 := []string{"198.51.100.1:51820"}
+	v6 := []string{"[2001:db8::1]:51820"}
+	dual := []string{"198.51.100.2:51820", "[2001:db8::2]:51820"}
+	hostname := []string{"home.example.com:51820"}
+
+	tests := []struct {
+		name        string
+		local       []string
+		remote      []string
+		preference  FamilyPreference
+		expected    AddressFamily
+		shouldError bool
+	}{
+		{name: "dual-stack both sides prefers ipv6", local: dual, remote: dual, preference: PreferAuto, expected: IPv6},
+		{name: "ipv6 only on one side", local: v4, remote: dual, preference: PreferAuto, expected: IPv4},
+		{name: "ipv4 forced", local: dual, remote: dual, preference: PreferIPv4, expected: IPv4},
+		{name: "ipv6 forced without support", local: v4, remote: dual, preference: PreferIPv6, shouldError: true},
+		{name: "no common family", local: v4, remote: v6, preference: PreferAuto, shouldError: true},
+		{name: "hostname defers to kubespan", local: hostname, remote: v4, preference: PreferAuto, expected: Unresolved},
+		{name: "unknown preference", local: dual, remote: dual, preference: "ipx", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, _ := ParseEndpoints(tt.local)
+			remote, _ := ParseEndpoints(tt.remote)
+
+			family, err := SelectFamily(local, remote, tt.preference)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("SelectFamily() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if family != tt.expected {
+				t.Errorf("SelectFamily() = %s, expected %s", family, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSelectEndpoint(t *testing.T) {
+	endpoints, _ := ParseEndpoints([]string{"198.51.100.2:51820", "[2001:db8::2]:51820"})
+
+	if ep := SelectEndpoint(endpoints, IPv6); ep.String() != "[2001:db8::2]:51820" {
+		t.Errorf("Expected IPv6 endpoint, got %s", ep)
+	}
+	if ep := SelectEndpoint(endpoints, Unresolved); ep.String() != "198.51.100.2:51820" {
+		t.Errorf("Expected fallback to first endpoint, got %s", ep)
+	}
+}
+
+func TestEndpointFilters(t *testing.T) {
+	tests := []struct {
+		name       string
+		family     AddressFamily
+		preference FamilyPreference
+		expected   []string
+	}{
+		{name: "ipv4", family: IPv4, preference: PreferAuto, expected: append(append([]string{}, ipv4EndpointFilters...), ipv6EndpointFilters...)},
+		{name: "ipv6", family: IPv6, preference: PreferAuto, expected: append(append([]string{}, ipv6EndpointFilters...), ipv4EndpointFilters...)},
+		{name: "ipv4 only", family: IPv4, preference: PreferIPv4, expected: []string{"0.0.0.0/0", "!10.0.0.0/8", "!172.16.0.0/12", "!192.168.0.0/16", "!100.64.0.0/10", "!169.254.0.0/16"}},
+		{name: "ipv6 only", family: IPv6, preference: PreferIPv6, expected: []string{"::/0", "!fc00::/7", "!fe80::/10"}},
+		{name: "dual", family: IPv6, preference: PreferDual, expected: append(append([]string{}, ipv6EndpointFilters...), ipv4EndpointFilters...)},
+		{name: "hostname", family: Unresolved, preference: PreferAuto, expected: append(append([]string{}, ipv6EndpointFilters...), ipv4EndpointFilters...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := EndpointFilters(tt.family, tt.preference)
+			if !reflect.DeepEqual(filters, tt.expected) {
+				t.Errorf("EndpointFilters() = %v, expected %v", filters, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HeadscaleClient is a minimal client for the Headscale REST API
+type HeadscaleClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// HeadscalePreAuthKey is a pre-authentication key nodes use to join the tailnet
+type HeadscalePreAuthKey struct {
+	ID         string    `json:"id"`
+	Key        string    `json:"key"`
+	User       string    `json:"user"`
+	Reusable   bool      `json:"reusable"`
+	Ephemeral  bool      `json:"ephemeral"`
+	Used       bool      `json:"used"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// HeadscaleNode is a node registered with Headscale
+type HeadscaleNode struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	GivenName   string    `json:"givenName"`
+	IPAddresses []string  `json:"ipAddresses"`
+	Online      bool      `json:"online"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+func NewHeadscaleClient(baseURL, apiKey string) *HeadscaleClient {
+	return &HeadscaleClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// EnsureUser creates the Headscale user if it does not exist yet
+func (c *HeadscaleClient) EnsureUser(ctx context.Context, name string) error {
+	var users struct {
+		Users []struct {
+			Name string `json:"name"`
+		} `json:"users"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/user", nil, &users); err != nil {
+		return fmt.Errorf("failed to list Headscale users: %v", err)
+	}
+
+	for _, user := range users.Users {
+		if user.Name == name {
+			return nil
+		}
+	}
+
+	body := map[string]string{"name": name}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/user", body, nil); err != nil {
+		return fmt.Errorf("failed to create Headscale user %s: %v", name, err)
+	}
+
+	return nil
+}
+
+// CreatePreAuthKey creates a pre-authentication key for user
+func (c *HeadscaleClient) CreatePreAuthKey(ctx context.Context, user string, reusable bool, expiration time.Time) (HeadscalePreAuthKey, error) {
+	body := map[string]interface{}{
+		"user":       user,
+		"reusable":   reusable,
+		"ephemeral":  false,
+		"expiration": expiration.UTC().Format(time.RFC3339),
+	}
+
+	var resp struct {
+		PreAuthKey HeadscalePreAuthKey `json:"preAuthKey"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/preauthkey", body, &resp); err != nil {
+		return HeadscalePreAuthKey{}, fmt.Errorf("failed to create Headscale pre-auth key: %v", err)
+	}
+
+	if resp.PreAuthKey.Key == "" {
+		return HeadscalePreAuthKey{}, fmt.Errorf("Headscale returned an empty pre-auth key")
+	}
+
+	return resp.PreAuthKey, nil
+}
+
+// ListNodes lists the nodes registered by user
+func (c *HeadscaleClient) ListNodes(ctx context.Context, user string) ([]HeadscaleNode, error) {
+	var resp struct {
+		Nodes []HeadscaleNode `json:"nodes"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/node?user="+url.QueryEscape(user), nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list Headscale nodes: %v", err)
+	}
+
+	return resp.Nodes, nil
+}
+
+func (c *HeadscaleClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// HeadscaleTransport connects cloud clusters through a Headscale-coordinated
+// tailnet, using the Tailscale system extension on the Talos nodes
+type HeadscaleTransport struct {
+	client      *HeadscaleClient
+	loginServer string
+	// KeyTTL is how long the generated pre-auth keys stay valid
+	KeyTTL time.Duration
+	now    func() time.Time
+}
+
+func NewHeadscaleTransport(client *HeadscaleClient) *HeadscaleTransport {
+	return &HeadscaleTransport{
+		client:      client,
+		loginServer: client.baseURL,
+		KeyTTL:      24 * time.Hour,
+		now:         time.Now,
+	}
+}
+
+func (t *HeadscaleTransport) Name() string {
+	return "headscale"
+}
+
+// Register creates a Headscale user for the cluster and a reusable pre-auth key
+// its nodes use to join. The endpoints are not needed as Headscale handles NAT
+// traversal itself.
+func (t *HeadscaleTransport) Register(ctx context.Context, cluster string, endpoints []string) (NodeConfig, error) {
+	if cluster == "" {
+		return NodeConfig{}, fmt.Errorf("cluster name is required")
+	}
+
+	if err := t.client.EnsureUser(ctx, cluster); err != nil {
+		return NodeConfig{}, err
+	}
+
+	key, err := t.client.CreatePreAuthKey(ctx, cluster, true, t.now().Add(t.KeyTTL))
+	if err != nil {
+		return NodeConfig{}, err
+	}
+
+	doc, err := TailscaleExtensionConfig(key.Key, t.loginServer)
+	if err != nil {
+		return NodeConfig{}, err
+	}
+
+	return NodeConfig{Transport: t.Name(), Documents: [][]byte{doc}}, nil
+}
+
+// Peers reports the nodes the cluster has registered with Headscale
+func (t *HeadscaleTransport) Peers(ctx context.Context, cluster string) ([]PeerStatus, error) {
+	nodes, err := t.client.ListNodes(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]PeerStatus, 0, len(nodes))
+	for _, node := range nodes {
+		label := node.GivenName
+		if label == "" {
+			label = node.Name
+		}
+
+		state := PeerDown
+		if node.Online {
+			state = PeerUp
+		}
+
+		peers = append(peers, PeerStatus{
+			Label:         label,
+			Endpoint:      strings.Join(node.IPAddresses, ","),
+			State:         state,
+			LastHandshake: node.LastSeen,
+		})
+	}
+
+	return peers, nil
+}
+
+type extensionServiceConfig struct {
+	APIVersion  string   `yaml:"apiVersion"`
+	Kind        string   `yaml:"kind"`
+	Name        string   `yaml:"name"`
+	Environment []string `yaml:"environment"`
+}
+
+// TailscaleExtensionConfig renders the ExtensionServiceConfig document for the
+// siderolabs/tailscale system extension pointing at a Headscale login server
+func TailscaleExtensionConfig(authKey, loginServer string) ([]byte, error) {
+	doc := extensionServiceConfig{
+		APIVersion: "v1alpha1",
+		Kind:       "ExtensionServiceConfig",
+		Name:       "tailscale",
+		Environment: []string{
+			"TS_AUTHKEY=" + authKey,
+			"TS_EXTRA_ARGS=--login-server=" + loginServer,
+		},
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render Tailscale extension config: %v", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,163 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHeadscale is an in-memory stand-in for the Headscale REST API
+type fakeHeadscale struct {
+	users []string
+	keys  []map[string]interface{}
+	nodes map[string][]HeadscaleNode
+}
+
+func (f *fakeHeadscale) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/api/v1/user" && r.Method == http.MethodGet:
+			users := make([]map[string]string, 0, len(f.users))
+			for _, name := range f.users {
+				users = append(users, map[string]string{"name": name})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"users": users})
+		case r.URL.Path == "/api/v1/user" && r.Method == http.MethodPost:
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.users = append(f.users, body["name"])
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"user": body})
+		case r.URL.Path == "/api/v1/preauthkey" && r.Method == http.MethodPost:
+			var body map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.keys = append(f.keys, body)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"preAuthKey": map[string]interface{}{
+					"id":         "1",
+					"key":        "hskey-auth-abc123",
+					"user":       body["user"],
+					"reusable":   body["reusable"],
+					"expiration": body["expiration"],
+				},
+			})
+		case r.URL.Path == "/api/v1/node" && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"nodes": f.nodes[r.URL.Query().Get("user")]})
+		default:
+			t.Logf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+}
+
+func TestHeadscaleTransportRegister(t *testing.T) {
+	fake := &fakeHeadscale{users: []string{"existing"}}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	transport := NewHeadscaleTransport(NewHeadscaleClient(server.URL+"/", "test-key"))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	transport.now = func() time.Time { return now }
+
+	config, err := transport.Register(context.Background(), "cloud1", nil)
+	if err != nil {
+		t.Fatalf("Register() error = %v, expected nil", err)
+	}
+
+	if len(fake.users) != 2 || fake.users[1] != "cloud1" {
+		t.Errorf("Expected user cloud1 to be created, got %v", fake.users)
+	}
+
+	if len(fake.keys) != 1 {
+		t.Fatalf("Expected one pre-auth key, got %d", len(fake.keys))
+	}
+	if fake.keys[0]["reusable"] != true || fake.keys[0]["expiration"] != "2024-01-02T00:00:00Z" {
+		t.Errorf("Unexpected pre-auth key request: %v", fake.keys[0])
+	}
+
+	doc := string(config.YAML())
+	for _, want := range []string{
+		"kind: ExtensionServiceConfig",
+		"name: tailscale",
+		"TS_AUTHKEY=hskey-auth-abc123",
+		"TS_EXTRA_ARGS=--login-server=" + server.URL,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("Expected node config to contain %q, got:\n%s", want, doc)
+		}
+	}
+
+	// Registering again reuses the existing user
+	if _, err := transport.Register(context.Background(), "cloud1", nil); err != nil {
+		t.Fatalf("Register() error = %v, expected nil", err)
+	}
+	if len(fake.users) != 2 {
+		t.Errorf("Expected existing user to be reused, got %v", fake.users)
+	}
+}
+
+func TestHeadscaleTransportPeers(t *testing.T) {
+	lastSeen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := &fakeHeadscale{
+		nodes: map[string][]HeadscaleNode{
+			"cloud1": {
+				{ID: "1", Name: "talos-node-0", GivenName: "cloud1-cp-0", IPAddresses: []string{"100.64.0.1", "fd7a:115c:a1e0::1"}, Online: true, LastSeen: lastSeen},
+				{ID: "2", Name: "talos-node-1", IPAddresses: []string{"100.64.0.2"}, Online: false},
+			},
+		},
+	}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	transport := NewHeadscaleTransport(NewHeadscaleClient(server.URL, "test-key"))
+
+	peers, err := transport.Peers(context.Background(), "cloud1")
+	if err != nil {
+		t.Fatalf("Peers() error = %v, expected nil", err)
+	}
+
+	if len(peers) != 2 {
+		t.Fatalf("Expected 2 peers, got %d", len(peers))
+	}
+	if peers[0].Label != "cloud1-cp-0" || peers[0].State != PeerUp || !peers[0].LastHandshake.Equal(lastSeen) {
+		t.Errorf("Unexpected first peer: %+v", peers[0])
+	}
+	if peers[0].Endpoint != "100.64.0.1,fd7a:115c:a1e0::1" {
+		t.Errorf("Expected tailnet addresses as endpoint, got %s", peers[0].Endpoint)
+	}
+	if peers[1].Label != "talos-node-1" || peers[1].State != PeerDown {
+		t.Errorf("Unexpected second peer: %+v", peers[1])
+	}
+}
+
+func TestHeadscaleClientErrors(t *testing.T) {
+	server := httptest.NewServer((&fakeHeadscale{}).handler(t))
+	defer server.Close()
+
+	transport := NewHeadscaleTransport(NewHeadscaleClient(server.URL, "wrong-key"))
+
+	if _, err := transport.Register(context.Background(), "cloud1", nil); err == nil {
+		t.Error("Expected error with invalid API key")
+	}
+	if _, err := transport.Peers(context.Background(), "cloud1"); err == nil {
+		t.Error("Expected error with invalid API key")
+	}
+	if _, err := transport.Register(context.Background(), "", nil); err == nil {
+		t.Error("Expected error for missing cluster name")
+	}
+}
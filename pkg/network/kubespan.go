@@ -1,51 +1,103 @@
-
 package network
 
 import (
 	"fmt"
-	"net"
 )
 
 // KubeSpanManager handles mesh networking between clusters
 type KubeSpanManager struct {
+	// HomeClusterEndpoint is the first home endpoint, kept for single-stack callers
 	HomeClusterEndpoint string
-	CloudClusters       []string
+	HomeEndpoints       []string
+	// CloudClusters holds the selected endpoint of every added cloud cluster
+	CloudClusters []string
+	// Preference controls which address family is used when both are available
+	Preference FamilyPreference
+	// MTU is emitted into generated patches when set, see MTUDiscovery
+	MTU   int
+	peers map[string]CloudPeer
+}
+
+// CloudPeer is a cloud cluster added to the mesh
+type CloudPeer struct {
+	Name      string
+	Endpoints []Endpoint
+	Family    AddressFamily
+	Selected  Endpoint
 }
 
-func NewKubeSpanManager(homeEndpoint string) *KubeSpanManager {
-	return &KubeSpanManager{
-		HomeClusterEndpoint: homeEndpoint,
-		CloudClusters:       make([]string, 0),
+func NewKubeSpanManager(homeEndpoints ...string) *KubeSpanManager {
+	manager := &KubeSpanManager{
+		HomeEndpoints: homeEndpoints,
+		CloudClusters: make([]string, 0),
+		Preference:    PreferAuto,
+		peers:         make(map[string]CloudPeer),
 	}
+	if len(homeEndpoints) > 0 {
+		manager.HomeClusterEndpoint = homeEndpoints[0]
+	}
+	return manager
 }
 
 func (k *KubeSpanManager) ValidateEndpoint() error {
 	if k.HomeClusterEndpoint == "" {
 		return fmt.Errorf("home cluster endpoint is required")
 	}
-	
-	host, port, err := net.SplitHostPort(k.HomeClusterEndpoint)
+
+	_, err := ParseEndpoints(k.HomeEndpoints)
+	return err
+}
+
+// AddCloudCluster adds a cloud cluster reachable on one or more endpoints and
+// selects the endpoint to use based on the families both sides support
+func (k *KubeSpanManager) AddCloudCluster(name string, endpoints ...string) error {
+	if name == "" || len(endpoints) == 0 || endpoints[0] == "" {
+		return fmt.Errorf("both name and endpoint are required")
+	}
+
+	cloud, err := ParseEndpoints(endpoints)
 	if err != nil {
-		return fmt.Errorf("invalid endpoint format: %v", err)
+		return err
 	}
 
-	if host == "" || port == "" {
-		return fmt.Errorf("both host and port are required")
+	home, err := ParseEndpoints(k.HomeEndpoints)
+	if err != nil {
+		return fmt.Errorf("invalid home endpoint: %v", err)
 	}
 
+	family, err := SelectFamily(home, cloud, k.Preference)
+	if err != nil {
+		return err
+	}
+
+	peer := CloudPeer{
+		Name:      name,
+		Endpoints: cloud,
+		Family:    family,
+		Selected:  SelectEndpoint(cloud, family),
+	}
+	k.peers[name] = peer
+
+	k.CloudClusters = append(k.CloudClusters, peer.Selected.String())
 	return nil
 }
 
-func (k *KubeSpanManager) AddCloudCluster(name, endpoint string) error {
-	if name == "" || endpoint == "" {
-		return fmt.Errorf("both name and endpoint are required")
-	}
+// CloudPeer returns a previously added cloud cluster
+func (k *KubeSpanManager) CloudPeer(name string) (CloudPeer, bool) {
+	peer, ok := k.peers[name]
+	return peer, ok
+}
 
-	_, _, err := net.SplitHostPort(endpoint)
-	if err != nil {
-		return fmt.Errorf("invalid endpoint format: %v", err)
+// MachineConfigPatch returns the KubeSpan machine-config patch for a cloud cluster
+func (k *KubeSpanManager) MachineConfigPatch(name string) (KubeSpanPatch, error) {
+	peer, ok := k.peers[name]
+	if !ok {
+		return KubeSpanPatch{}, fmt.Errorf("unknown cloud cluster: %s", name)
 	}
 
-	k.CloudClusters = append(k.CloudClusters, endpoint)
-	return nil
+	return KubeSpanPatch{
+		Enabled:         true,
+		EndpointFilters: EndpointFilters(peer.Family, k.Preference),
+		MTU:             k.MTU,
+	}, nil
 }
@@ -115,6 +115,62 @@ func TestAddCloudCluster(t *testing.T) {
 	}
 }
 
+func TestAddCloudClusterDualStack(t *testing.T) {
+	manager := NewKubeSpanManager("198.51.100.1:50000", "[2001:db8::1]:50000")
+
+	if err := manager.ValidateEndpoint(); err != nil {
+		t.Fatalf("ValidateEndpoint() error = %v, expected nil", err)
+	}
+
+	if err := manager.AddCloudCluster("cloud1", "203.0.113.1:50000", "[2001:db8:1::1]:50000"); err != nil {
+		t.Fatalf("AddCloudCluster() error = %v, expected nil", err)
+	}
+
+	peer, ok := manager.CloudPeer("cloud1")
+	if !ok {
+		t.Fatal("Expected cloud1 to be registered")
+	}
+	if peer.Family != IPv6 {
+		t.Errorf("Expected IPv6 to be preferred, got %s", peer.Family)
+	}
+	if len(peer.Endpoints) != 2 {
+		t.Errorf("Expected 2 endpoints, got %d", len(peer.Endpoints))
+	}
+	if manager.CloudClusters[0] != "[2001:db8:1::1]:50000" {
+		t.Errorf("Expected IPv6 endpoint to be selected, got %s", manager.CloudClusters[0])
+	}
+
+	patch, err := manager.MachineConfigPatch("cloud1")
+	if err != nil {
+		t.Fatalf("MachineConfigPatch() error = %v, expected nil", err)
+	}
+	if patch.EndpointFilters[0] != "::/0" {
+		t.Errorf("Expected IPv6 endpoint filters, got %v", patch.EndpointFilters)
+	}
+
+	if _, err := manager.MachineConfigPatch("unknown"); err == nil {
+		t.Error("Expected error for unknown cluster")
+	}
+}
+
+func TestAddCloudClusterFamilyPreference(t *testing.T) {
+	manager := NewKubeSpanManager("198.51.100.1:50000", "[2001:db8::1]:50000")
+	manager.Preference = PreferIPv4
+
+	if err := manager.AddCloudCluster("cloud1", "203.0.113.1:50000", "[2001:db8:1::1]:50000"); err != nil {
+		t.Fatalf("AddCloudCluster() error = %v, expected nil", err)
+	}
+	if manager.CloudClusters[0] != "203.0.113.1:50000" {
+		t.Errorf("Expected IPv4 endpoint to be selected, got %s", manager.CloudClusters[0])
+	}
+
+	// An IPv6-only cloud cluster cannot reach an IPv4-only home
+	v4Home := NewKubeSpanManager("198.51.100.1:50000")
+	if err := v4Home.AddCloudCluster("cloud2", "[2001:db8:2::1]:50000"); err == nil {
+		t.Error("Expected error when no address family is shared")
+	}
+}
+
 // Tests for functionality that will be implemented in the future
 func TestFutureFeatures(t *testing.T) {
 	// These tests indicate functionality that will be added in the future
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"context"
+	"fmt"
+
+	"talos-autoextender/pkg/talos"
+)
+
+// KubeSpanTransport is the default Transport, using Talos' built-in WireGuard mesh
+type KubeSpanTransport struct {
+	manager   *KubeSpanManager
+	connect   talos.Connector
+	endpoints map[string]string
+}
+
+func NewKubeSpanTransport(manager *KubeSpanManager, connect talos.Connector) *KubeSpanTransport {
+	return &KubeSpanTransport{
+		manager:   manager,
+		connect:   connect,
+		endpoints: make(map[string]string),
+	}
+}
+
+func (t *KubeSpanTransport) Name() string {
+	return "kubespan"
+}
+
+// Register adds the cloud cluster to the KubeSpan manager and returns the
+// machine-config patch enabling KubeSpan with matching endpoint filters
+func (t *KubeSpanTransport) Register(ctx context.Context, cluster string, endpoints []string) (NodeConfig, error) {
+	if err := t.manager.AddCloudCluster(cluster, endpoints...); err != nil {
+		return NodeConfig{}, err
+	}
+
+	patch, err := t.manager.MachineConfigPatch(cluster)
+	if err != nil {
+		return NodeConfig{}, err
+	}
+
+	doc, err := patch.YAML()
+	if err != nil {
+		return NodeConfig{}, err
+	}
+
+	return NodeConfig{Transport: t.Name(), Documents: [][]byte{doc}}, nil
+}
+
+// Track records the Talos API endpoint (IP:PORT) of a cluster's node that
+// peer statuses are queried through. It is not the WireGuard peer endpoint
+// the cluster was registered with.
+func (t *KubeSpanTransport) Track(cluster, endpoint string) {
+	t.endpoints[cluster] = endpoint
+}
+
+// Peers queries the KubeSpan peer statuses through the cluster's Talos API
+func (t *KubeSpanTransport) Peers(ctx context.Context, cluster string) ([]PeerStatus, error) {
+	endpoint, ok := t.endpoints[cluster]
+	if !ok {
+		return nil, fmt.Errorf("unknown cloud cluster: %s", cluster)
+	}
+
+	client, err := t.connect(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	statuses, err := client.KubeSpanPeerStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]PeerStatus, 0, len(statuses))
+	for _, status := range statuses {
+		peers = append(peers, PeerStatus{
+			Label:         status.Label,
+			Endpoint:      status.Endpoint,
+			State:         status.State,
+			LastHandshake: status.LastHandshake,
+			ReceiveBytes:  status.ReceiveBytes,
+			TransmitBytes: status.TransmitBytes,
+		})
+	}
+
+	return peers, nil
+}
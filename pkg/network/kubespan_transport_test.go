@@ -0,0 +1,85 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"talos-autoextender/pkg/talos"
+)
+
+// fakeTalosClient returns canned KubeSpan peer statuses. Methods not used by
+// the transport are left to the embedded nil interface.
+type fakeTalosClient struct {
+	talos.Client
+	peers []talos.KubeSpanPeerStatus
+	err   error
+}
+
+func (f *fakeTalosClient) KubeSpanPeerStatuses(ctx context.Context) ([]talos.KubeSpanPeerStatus, error) {
+	return f.peers, f.err
+}
+
+func (f *fakeTalosClient) Close() error {
+	return nil
+}
+
+func fakeConnector(clients map[string]*fakeTalosClient) talos.Connector {
+	return func(ctx context.Context, endpoint string) (talos.Client, error) {
+		client, ok := clients[endpoint]
+		if !ok {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return client, nil
+	}
+}
+
+func TestKubeSpanTransportRegister(t *testing.T) {
+	transport := NewKubeSpanTransport(NewKubeSpanManager("198.51.100.1:50000"), nil)
+
+	if transport.Name() != "kubespan" {
+		t.Errorf("Expected name 'kubespan', got '%s'", transport.Name())
+	}
+
+	config, err := transport.Register(context.Background(), "cloud1", []string{"203.0.113.1:51820"})
+	if err != nil {
+		t.Fatalf("Register() error = %v, expected nil", err)
+	}
+
+	if len(config.Documents) != 1 || !strings.Contains(string(config.YAML()), "kubespan") {
+		t.Errorf("Expected a KubeSpan patch, got:\n%s", config.YAML())
+	}
+
+	if _, err := transport.Register(context.Background(), "cloud2", []string{"203.0.113.2"}); err == nil {
+		t.Error("Expected error for invalid endpoint")
+	}
+}
+
+func TestKubeSpanTransportPeers(t *testing.T) {
+	transport := NewKubeSpanTransport(NewKubeSpanManager(), fakeConnector(map[string]*fakeTalosClient{
+		"10.0.0.1:50000": {
+			peers: []talos.KubeSpanPeerStatus{
+				{Label: "home-cp", State: "up", Endpoint: "203.0.113.4:51820", ReceiveBytes: 10, TransmitBytes: 20},
+			},
+		},
+		"10.0.0.2:50000": {err: fmt.Errorf("permission denied")},
+	}))
+	transport.Track("cloud1", "10.0.0.1:50000")
+	transport.Track("cloud2", "10.0.0.2:50000")
+	transport.Track("cloud3", "10.0.0.3:50000")
+
+	peers, err := transport.Peers(context.Background(), "cloud1")
+	if err != nil {
+		t.Fatalf("Peers() error = %v, expected nil", err)
+	}
+	if len(peers) != 1 || peers[0].State != PeerUp || peers[0].TransmitBytes != 20 {
+		t.Errorf("Unexpected peers: %+v", peers)
+	}
+
+	for _, cluster := range []string{"cloud2", "cloud3", "unknown"} {
+		if _, err := transport.Peers(context.Background(), cluster); err == nil {
+			t.Errorf("Expected error for %s", cluster)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ClusterPeerReport is the peer state reported by one connected cluster
+type ClusterPeerReport struct {
+	Cluster   string
+	Transport string
+	CheckedAt time.Time
+	Peers     []PeerStatus
+	Error     string
+}
+
+// Healthy reports whether the cluster was reachable and every peer is up
+func (r ClusterPeerReport) Healthy() bool {
+	if r.Error != "" || len(r.Peers) == 0 {
+		return false
+	}
+	for _, peer := range r.Peers {
+		if peer.State != PeerUp {
+			return false
+		}
+	}
+	return true
+}
+
+// PeerMonitor queries peer status on connected clusters through their transports
+type PeerMonitor struct {
+	clusters map[string]Transport
+	now      func() time.Time
+}
+
+func NewPeerMonitor() *PeerMonitor {
+	return &PeerMonitor{
+		clusters: make(map[string]Transport),
+		now:      time.Now,
+	}
+}
+
+// Add monitors a cluster through the transport it was connected with
+func (m *PeerMonitor) Add(cluster string, transport Transport) {
+	m.clusters[cluster] = transport
+}
+
+// Check queries every cluster in name order. A cluster that cannot be queried
+// is reported with an error rather than failing the whole check.
+func (m *PeerMonitor) Check(ctx context.Context) []ClusterPeerReport {
+	names := make([]string, 0, len(m.clusters))
+	for name := range m.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reports := make([]ClusterPeerReport, 0, len(names))
+	for _, name := range names {
+		reports = append(reports, m.checkCluster(ctx, name, m.clusters[name]))
+	}
+
+	return reports
+}
+
+func (m *PeerMonitor) checkCluster(ctx context.Context, name string, transport Transport) ClusterPeerReport {
+	report := ClusterPeerReport{
+		Cluster:   name,
+		Transport: transport.Name(),
+		CheckedAt: m.now(),
+	}
+
+	peers, err := transport.Peers(ctx, name)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Label < peers[j].Label
+	})
+	report.Peers = peers
+
+	return report
+}
+
+// Watch runs Check every interval until ctx is cancelled, passing each round of
+// reports to fn. The first check runs immediately.
+func (m *PeerMonitor) Watch(ctx context.Context, interval time.Duration, fn func([]ClusterPeerReport)) error {
+	if interval <= 0 {
+		return fmt.Errorf("watch interval must be positive")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(m.Check(ctx))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeTransport returns canned peer statuses
+type fakeTransport struct {
+	peers []PeerStatus
+	err   error
+}
+
+func (f *fakeTransport) Name() string {
+	return "fake"
+}
+
+func (f *fakeTransport) Register(ctx context.Context, cluster string, endpoints []string) (NodeConfig, error) {
+	return NodeConfig{Transport: f.Name()}, nil
+}
+
+func (f *fakeTransport) Peers(ctx context.Context, cluster string) ([]PeerStatus, error) {
+	return f.peers, f.err
+}
+
+func TestPeerMonitorCheck(t *testing.T) {
+	handshake := time.Now().Add(-time.Minute)
+
+	monitor := NewPeerMonitor()
+	monitor.Add("cloud2", &fakeTransport{
+		peers: []PeerStatus{{Label: "home-cp", State: PeerDown}},
+	})
+	monitor.Add("cloud1", &fakeTransport{
+		peers: []PeerStatus{
+			{Label: "home-worker", State: PeerUp, Endpoint: "203.0.113.5:51820", LastHandshake: handshake, ReceiveBytes: 10, TransmitBytes: 20},
+			{Label: "home-cp", State: PeerUp, Endpoint: "203.0.113.4:51820", LastHandshake: handshake},
+		},
+	})
+	monitor.Add("cloud3", &fakeTransport{err: fmt.Errorf("permission denied")})
+	monitor.Add("cloud4", &fakeTransport{})
+
+	reports := monitor.Check(context.Background())
+	if len(reports) != 4 {
+		t.Fatalf("Expected 4 reports, got %d", len(reports))
+	}
+
+	tests := []struct {
+		cluster  string
+		peers    int
+		healthy  bool
+		hasError bool
+	}{
+		{cluster: "cloud1", peers: 2, healthy: true},
+		{cluster: "cloud2", peers: 1, healthy: false},
+		{cluster: "cloud3", peers: 0, healthy: false, hasError: true},
+		{cluster: "cloud4", peers: 0, healthy: false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.cluster, func(t *testing.T) {
+			report := reports[i]
+			if report.Cluster != tt.cluster {
+				t.Errorf("Expected report for %s, got %s", tt.cluster, report.Cluster)
+			}
+			if report.Transport != "fake" {
+				t.Errorf("Expected transport 'fake', got '%s'", report.Transport)
+			}
+			if len(report.Peers) != tt.peers {
+				t.Errorf("Expected %d peers, got %d", tt.peers, len(report.Peers))
+			}
+			if report.Healthy() != tt.healthy {
+				t.Errorf("Healthy() = %v, expected %v", report.Healthy(), tt.healthy)
+			}
+			if (report.Error != "") != tt.hasError {
+				t.Errorf("Error = %q, hasError %v", report.Error, tt.hasError)
+			}
+		})
+	}
+
+	// Peers are sorted by label
+	if reports[0].Peers[0].Label != "home-cp" {
+		t.Errorf("Expected peers sorted by label, got %s first", reports[0].Peers[0].Label)
+	}
+}
+
+func TestPeerMonitorWatch(t *testing.T) {
+	monitor := NewPeerMonitor()
+	monitor.Add("cloud1", &fakeTransport{peers: []PeerStatus{{Label: "home-cp", State: PeerUp}}})
+
+	if err := monitor.Watch(context.Background(), 0, func([]ClusterPeerReport) {}); err == nil {
+		t.Error("Expected error for non-positive interval")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rounds := 0
+	err := monitor.Watch(ctx, time.Millisecond, func(reports []ClusterPeerReport) {
+		rounds++
+		if rounds == 3 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Errorf("Watch() error = %v, expected nil", err)
+	}
+	if rounds < 3 {
+		t.Errorf("Expected at least 3 rounds, got %d", rounds)
+	}
+}
@@ -0,0 +1,199 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+)
+
+// Per-packet overhead of WireGuard tunnels, in bytes
+const (
+	ipv4HeaderSize  = 20
+	ipv6HeaderSize  = 40
+	udpHeaderSize   = 8
+	wireguardHeader = 32 // message type, receiver index, counter and Poly1305 tag
+	pppoeOverhead   = 8
+	ethernetMTU     = 1500
+	ipv4MinimumMTU  = 576
+	ipv6MinimumMTU  = 1280
+
+	// DefaultKubeSpanMTU is the MTU Talos uses when none is configured
+	DefaultKubeSpanMTU = 1420
+)
+
+// Prober sends a single probe with fragmentation disabled
+type Prober interface {
+	// Probe reports whether an IP packet of size bytes reaches addr unfragmented
+	Probe(ctx context.Context, addr netip.Addr, size int) (bool, error)
+}
+
+// MTUDiscovery finds the path MTU to cloud endpoints and derives a KubeSpan MTU
+// that avoids fragmentation or blackholing of tunnelled packets
+type MTUDiscovery struct {
+	prober Prober
+	// LinkMTU is the largest packet the local link sends
+	LinkMTU int
+	// PPPoE accounts for PPPoE encapsulation on the home uplink, which probes
+	// sent from behind a router may not see
+	PPPoE bool
+	// Resolver looks up hostname endpoints
+	Resolver *net.Resolver
+}
+
+// MTUReport is the outcome of probing one endpoint
+type MTUReport struct {
+	Endpoint string
+	Family   AddressFamily
+	PathMTU  int
+	// SafeMTU is the largest KubeSpan MTU that fits the path
+	SafeMTU  int
+	Warnings []string
+}
+
+func NewMTUDiscovery(prober Prober) *MTUDiscovery {
+	return &MTUDiscovery{
+		prober:   prober,
+		LinkMTU:  ethernetMTU,
+		Resolver: net.DefaultResolver,
+	}
+}
+
+// PathMTU binary searches the largest packet size that reaches addr
+func (d *MTUDiscovery) PathMTU(ctx context.Context, addr netip.Addr) (int, error) {
+	low := ipv4MinimumMTU
+	if addr.Is6() && !addr.Is4In6() {
+		low = ipv6MinimumMTU
+	}
+	high := d.LinkMTU
+
+	ok, err := d.prober.Probe(ctx, addr, low)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("%s is unreachable even with %d byte packets", addr, low)
+	}
+
+	// Invariant: low is known to pass, anything above high is assumed to fail
+	for low < high {
+		mid := (low + high + 1) / 2
+
+		ok, err := d.prober.Probe(ctx, addr, mid)
+		if err != nil {
+			return 0, err
+		}
+
+		if ok {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	return low, nil
+}
+
+// Probe discovers the path MTU to an endpoint and computes the KubeSpan MTU
+func (d *MTUDiscovery) Probe(ctx context.Context, endpoint Endpoint) (MTUReport, error) {
+	report := MTUReport{Endpoint: endpoint.String()}
+
+	addr := endpoint.Addr
+	if !addr.IsValid() {
+		addrs, err := d.Resolver.LookupNetIP(ctx, "ip", endpoint.Host)
+		if err != nil || len(addrs) == 0 {
+			return report, fmt.Errorf("failed to resolve %s: %v", endpoint.Host, err)
+		}
+		addr = addrs[0].Unmap()
+	}
+
+	report.Family = IPv4
+	if addr.Is6() {
+		report.Family = IPv6
+	}
+
+	pathMTU, err := d.PathMTU(ctx, addr)
+	if err != nil {
+		return report, err
+	}
+
+	report.PathMTU = pathMTU
+	report.SafeMTU = SafeKubeSpanMTU(pathMTU, report.Family, d.PPPoE)
+
+	if report.SafeMTU < ipv6MinimumMTU {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(
+			"KubeSpan MTU %d is below the IPv6 minimum of %d; IPv6 traffic inside the tunnel will fail",
+			report.SafeMTU, ipv6MinimumMTU))
+	}
+
+	return report, nil
+}
+
+// SafeKubeSpanMTU subtracts the outer IP, UDP and WireGuard headers, and the
+// PPPoE header if present, from a path MTU
+func SafeKubeSpanMTU(pathMTU int, family AddressFamily, pppoe bool) int {
+	if pppoe && pathMTU > ethernetMTU-pppoeOverhead {
+		pathMTU = ethernetMTU - pppoeOverhead
+	}
+
+	overhead := ipv4HeaderSize + udpHeaderSize + wireguardHeader
+	if family == IPv6 {
+		overhead = ipv6HeaderSize + udpHeaderSize + wireguardHeader
+	}
+
+	return pathMTU - overhead
+}
+
+// PlanMTU returns the KubeSpan MTU that is safe for every probed path, since a
+// node uses a single MTU for its KubeSpan interface. A configured MTU (0 for the
+// Talos default) that exceeds the safe MTU of any path is flagged; a smaller
+// configured MTU is kept.
+func PlanMTU(reports []MTUReport, configured int) (int, []string) {
+	current := configured
+	if current == 0 {
+		current = DefaultKubeSpanMTU
+	}
+
+	mtu := 0
+	var warnings []string
+	for _, report := range reports {
+		if mtu == 0 || report.SafeMTU < mtu {
+			mtu = report.SafeMTU
+		}
+
+		if report.SafeMTU < current {
+			warnings = append(warnings, fmt.Sprintf(
+				"MTU %d is too large for the path to %s (path MTU %d, safe KubeSpan MTU %d)",
+				current, report.Endpoint, report.PathMTU, report.SafeMTU))
+		}
+	}
+
+	if mtu == 0 || (configured > 0 && configured < mtu) {
+		mtu = current
+	}
+
+	return mtu, warnings
+}
+
+// MTUMismatches flags connected clusters whose recorded KubeSpan MTU differs
+// from mtu. Home nodes share one KubeSpan interface for all peers, so every
+// cluster in the mesh should agree.
+func MTUMismatches(mtu int, clusters map[string]int) []string {
+	var warnings []string
+	for _, name := range sortedKeys(clusters) {
+		if other := clusters[name]; other != 0 && other != mtu {
+			warnings = append(warnings, fmt.Sprintf("cluster %s uses KubeSpan MTU %d, not %d", name, other, mtu))
+		}
+	}
+	return warnings
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,131 @@
+//go:build linux
+
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// icmpProber probes with ICMP echo requests that have the don't-fragment bit
+// set. It uses unprivileged ping sockets, so the user's group must be allowed
+// by net.ipv4.ping_group_range.
+type icmpProber struct {
+	timeout  time.Duration
+	attempts int
+}
+
+// NewICMPProber returns a Prober sending ICMP echo requests with fragmentation
+// disabled. A probe fails if no reply arrives within timeout after attempts tries.
+func NewICMPProber(timeout time.Duration, attempts int) Prober {
+	return &icmpProber{timeout: timeout, attempts: attempts}
+}
+
+func (p *icmpProber) Probe(ctx context.Context, addr netip.Addr, size int) (bool, error) {
+	for attempt := 0; attempt < p.attempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		ok, err := p.probeOnce(addr, size, attempt)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func (p *icmpProber) probeOnce(addr netip.Addr, size, seq int) (bool, error) {
+	domain, proto, headerSize := unix.AF_INET, unix.IPPROTO_ICMP, ipv4HeaderSize
+	var echoType icmp.Type = ipv4.ICMPTypeEcho
+	var replyType icmp.Type = ipv4.ICMPTypeEchoReply
+	if addr.Is6() && !addr.Is4In6() {
+		domain, proto, headerSize = unix.AF_INET6, unix.IPPROTO_ICMPV6, ipv6HeaderSize
+		echoType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	addr = addr.Unmap()
+
+	fd, err := unix.Socket(domain, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return false, fmt.Errorf("failed to open ICMP socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	// Set DF and ignore any cached path MTU so the probe size is sent as-is
+	if domain == unix.AF_INET {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+	} else {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to disable fragmentation: %v", err)
+	}
+
+	tv := unix.NsecToTimeval(p.timeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return false, fmt.Errorf("failed to set probe timeout: %v", err)
+	}
+
+	payload := size - headerSize - 8
+	if payload < 0 {
+		return false, fmt.Errorf("probe size %d is too small", size)
+	}
+
+	msg := icmp.Message{
+		Type: echoType,
+		Body: &icmp.Echo{ID: os.Getpid() & 0xffff, Seq: seq, Data: make([]byte, payload)},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+
+	var sa unix.Sockaddr
+	if domain == unix.AF_INET {
+		sa = &unix.SockaddrInet4{Addr: addr.As4()}
+	} else {
+		sa = &unix.SockaddrInet6{Addr: addr.As16()}
+	}
+
+	if err := unix.Sendto(fd, data, 0, sa); err != nil {
+		// The kernel already knows the packet is larger than the route MTU
+		if errors.Is(err, unix.EMSGSIZE) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to send probe: %v", err)
+	}
+
+	buf := make([]byte, size+64)
+	deadline := time.Now().Add(p.timeout)
+	for time.Now().Before(deadline) {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				return false, nil
+			}
+			if errors.Is(err, unix.EMSGSIZE) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to read probe reply: %v", err)
+		}
+
+		reply, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		if echo, ok := reply.Body.(*icmp.Echo); ok && reply.Type == replyType && echo.Seq == seq {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
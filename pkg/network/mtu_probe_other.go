@@ -0,0 +1,22 @@
+//go:build !linux
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+type unsupportedProber struct{}
+
+// NewICMPProber returns a Prober that always fails: setting the don't-fragment
+// bit on unprivileged ICMP sockets is only implemented on Linux
+func NewICMPProber(timeout time.Duration, attempts int) Prober {
+	return unsupportedProber{}
+}
+
+func (unsupportedProber) Probe(ctx context.Context, addr netip.Addr, size int) (bool, error) {
+	return false, fmt.Errorf("path MTU probing is not supported on this platform")
+}
@@ -0,0 +1,150 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// thresholdProber passes probes up to a fixed path MTU
+type thresholdProber struct {
+	pathMTU int
+	probes  int
+	err     error
+}
+
+func (p *thresholdProber) Probe(ctx context.Context, addr netip.Addr, size int) (bool, error) {
+	p.probes++
+	return size <= p.pathMTU, p.err
+}
+
+func TestPathMTU(t *testing.T) {
+	tests := []struct {
+		name        string
+		addr        string
+		pathMTU     int
+		linkMTU     int
+		expected    int
+		shouldError bool
+	}{
+		{name: "full ethernet", addr: "203.0.113.1", pathMTU: 1500, linkMTU: 1500, expected: 1500},
+		{name: "pppoe path", addr: "203.0.113.1", pathMTU: 1492, linkMTU: 1500, expected: 1492},
+		{name: "tunnel in path", addr: "2001:db8::1", pathMTU: 1420, linkMTU: 1500, expected: 1420},
+		{name: "path above link", addr: "203.0.113.1", pathMTU: 9000, linkMTU: 1500, expected: 1500},
+		{name: "ipv6 below minimum", addr: "2001:db8::1", pathMTU: 1200, linkMTU: 1500, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prober := &thresholdProber{pathMTU: tt.pathMTU}
+			discovery := NewMTUDiscovery(prober)
+			discovery.LinkMTU = tt.linkMTU
+
+			mtu, err := discovery.PathMTU(context.Background(), netip.MustParseAddr(tt.addr))
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("PathMTU() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if mtu != tt.expected {
+				t.Errorf("PathMTU() = %d, expected %d", mtu, tt.expected)
+			}
+			if prober.probes > 12 {
+				t.Errorf("Expected a binary search, got %d probes", prober.probes)
+			}
+		})
+	}
+}
+
+func TestPathMTUProbeError(t *testing.T) {
+	discovery := NewMTUDiscovery(&thresholdProber{pathMTU: 1500, err: fmt.Errorf("operation not permitted")})
+
+	if _, err := discovery.PathMTU(context.Background(), netip.MustParseAddr("203.0.113.1")); err == nil {
+		t.Error("Expected prober error to be returned")
+	}
+}
+
+func TestSafeKubeSpanMTU(t *testing.T) {
+	tests := []struct {
+		name     string
+		pathMTU  int
+		family   AddressFamily
+		pppoe    bool
+		expected int
+	}{
+		{name: "ipv4", pathMTU: 1500, family: IPv4, expected: 1440},
+		{name: "ipv6", pathMTU: 1500, family: IPv6, expected: 1420},
+		{name: "ipv4 pppoe", pathMTU: 1500, family: IPv4, pppoe: true, expected: 1432},
+		{name: "ipv6 pppoe", pathMTU: 1500, family: IPv6, pppoe: true, expected: 1412},
+		{name: "pppoe already in path", pathMTU: 1480, family: IPv4, pppoe: true, expected: 1420},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if mtu := SafeKubeSpanMTU(tt.pathMTU, tt.family, tt.pppoe); mtu != tt.expected {
+				t.Errorf("SafeKubeSpanMTU() = %d, expected %d", mtu, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMTUDiscoveryProbe(t *testing.T) {
+	discovery := NewMTUDiscovery(&thresholdProber{pathMTU: 1300})
+
+	endpoint, _ := ParseEndpoint("[2001:db8::1]:51820")
+	report, err := discovery.Probe(context.Background(), endpoint)
+	if err != nil {
+		t.Fatalf("Probe() error = %v, expected nil", err)
+	}
+
+	if report.Family != IPv6 || report.PathMTU != 1300 || report.SafeMTU != 1220 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "IPv6 minimum") {
+		t.Errorf("Expected IPv6 minimum warning, got %v", report.Warnings)
+	}
+}
+
+func TestPlanMTU(t *testing.T) {
+	reports := []MTUReport{
+		{Endpoint: "203.0.113.1:51820", PathMTU: 1500, SafeMTU: 1440},
+		{Endpoint: "[2001:db8::1]:51820", PathMTU: 1492, SafeMTU: 1412},
+	}
+
+	tests := []struct {
+		name       string
+		reports    []MTUReport
+		configured int
+		expected   int
+		warnings   int
+	}{
+		{name: "default exceeds one path", reports: reports, configured: 0, expected: 1412, warnings: 1},
+		{name: "configured too large", reports: reports, configured: 1440, expected: 1412, warnings: 1},
+		{name: "configured smaller is kept", reports: reports, configured: 1380, expected: 1380, warnings: 0},
+		{name: "no reports", reports: nil, configured: 0, expected: DefaultKubeSpanMTU, warnings: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mtu, warnings := PlanMTU(tt.reports, tt.configured)
+			if mtu != tt.expected {
+				t.Errorf("PlanMTU() = %d, expected %d", mtu, tt.expected)
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("Expected %d warnings, got %v", tt.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestMTUMismatches(t *testing.T) {
+	warnings := MTUMismatches(1412, map[string]int{
+		"cloud1": 1412,
+		"cloud2": 1440,
+		"cloud3": 0,
+	})
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "cloud2") {
+		t.Errorf("Expected a single mismatch for cloud2, got %v", warnings)
+	}
+}
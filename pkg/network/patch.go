@@ -0,0 +1,50 @@
+package network
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KubeSpanPatch describes the KubeSpan section of a Talos machine config
+type KubeSpanPatch struct {
+	Enabled         bool
+	EndpointFilters []string
+	// MTU of the KubeSpan interface; 0 keeps the Talos default
+	MTU int
+}
+
+type machineConfigPatch struct {
+	Machine struct {
+		Network struct {
+			KubeSpan kubeSpanConfig `yaml:"kubespan"`
+		} `yaml:"network"`
+	} `yaml:"machine"`
+}
+
+type kubeSpanConfig struct {
+	Enabled bool `yaml:"enabled"`
+	MTU     int  `yaml:"mtu,omitempty"`
+	Filters *struct {
+		Endpoints []string `yaml:"endpoints"`
+	} `yaml:"filters,omitempty"`
+}
+
+// YAML renders the patch as a strategic merge patch for talosctl or the Talos API
+func (p KubeSpanPatch) YAML() ([]byte, error) {
+	var patch machineConfigPatch
+	patch.Machine.Network.KubeSpan.Enabled = p.Enabled
+	patch.Machine.Network.KubeSpan.MTU = p.MTU
+
+	if len(p.EndpointFilters) > 0 {
+		patch.Machine.Network.KubeSpan.Filters = &struct {
+			Endpoints []string `yaml:"endpoints"`
+		}{Endpoints: p.EndpointFilters}
+	}
+
+	out, err := yaml.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render KubeSpan patch: %v", err)
+	}
+	return out, nil
+}
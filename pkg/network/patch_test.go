@@ -0,0 +1,53 @@
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKubeSpanPatchYAML(t *testing.T) {
+	patch := KubeSpanPatch{
+		Enabled:         true,
+		EndpointFilters: []string{"::/0", "!fc00::/7"},
+	}
+
+	out, err := patch.YAML()
+	if err != nil {
+		t.Fatalf("YAML() error = %v, expected nil", err)
+	}
+
+	expected := `machine:
+    network:
+        kubespan:
+            enabled: true
+            filters:
+                endpoints:
+                    - ::/0
+                    - '!fc00::/7'
+`
+	if string(out) != expected {
+		t.Errorf("Unexpected patch:\n%s", out)
+	}
+}
+
+func TestKubeSpanPatchWithoutFilters(t *testing.T) {
+	out, err := KubeSpanPatch{Enabled: true}.YAML()
+	if err != nil {
+		t.Fatalf("YAML() error = %v, expected nil", err)
+	}
+
+	if strings.Contains(string(out), "filters") {
+		t.Errorf("Expected no filters section, got:\n%s", out)
+	}
+}
+
+func TestKubeSpanPatchMTU(t *testing.T) {
+	out, err := KubeSpanPatch{Enabled: true, MTU: 1412}.YAML()
+	if err != nil {
+		t.Fatalf("YAML() error = %v, expected nil", err)
+	}
+
+	if !strings.Contains(string(out), "mtu: 1412") {
+		t.Errorf("Expected MTU in patch, got:\n%s", out)
+	}
+}
@@ -0,0 +1,46 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// Transport links a cloud cluster to the home cluster over some overlay network
+type Transport interface {
+	// Name identifies the transport, e.g. "kubespan"
+	Name() string
+	// Register prepares the transport for a cloud cluster reachable on the given
+	// endpoints and returns the configuration its nodes need to join
+	Register(ctx context.Context, cluster string, endpoints []string) (NodeConfig, error)
+	// Peers reports the connectivity of the cluster's peers
+	Peers(ctx context.Context, cluster string) ([]PeerStatus, error)
+}
+
+// NodeConfig holds the Talos machine-config documents or patches a cloud
+// cluster's nodes need to join the transport
+type NodeConfig struct {
+	Transport string
+	Documents [][]byte
+}
+
+// YAML joins the documents into a single multi-document YAML stream
+func (c NodeConfig) YAML() []byte {
+	return bytes.Join(c.Documents, []byte("---\n"))
+}
+
+// PeerStatus is the transport-independent view of one peer link
+type PeerStatus struct {
+	Label         string
+	Endpoint      string
+	State         string // up, down or unknown
+	LastHandshake time.Time
+	ReceiveBytes  int64
+	TransmitBytes int64
+}
+
+const (
+	PeerUp      = "up"
+	PeerDown    = "down"
+	PeerUnknown = "unknown"
+)
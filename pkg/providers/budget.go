@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Downsizer is implemented by providers that can offer cheaper instance types
+// of the same kind as a size
+type Downsizer interface {
+	// CheaperSizes returns the sizes cheaper than size, most expensive first
+	CheaperSizes(size string) ([]string, error)
+}
+
+// Resize is a pool moved to a cheaper size to fit a budget
+type Resize struct {
+	Pool string
+	From string
+	To   string
+}
+
+// FitBudget checks that spec costs at most limit US dollars per month. With
+// downsize, the pools named in resizable move to cheaper sizes, the pool
+// costing the most first, until spec fits, and the changes are returned. spec
+// is left unchanged when it does not fit; the error then wraps ErrOverBudget.
+func FitBudget(spec *ClusterSpec, limit float64, downsize bool, resizable []string, estimator CostEstimator) (CostEstimate, []Resize, error) {
+	estimate, err := estimator.EstimateCost(*spec)
+	if err != nil {
+		return CostEstimate{}, nil, err
+	}
+	if estimate.Total.Monthly <= limit {
+		return estimate, nil, nil
+	}
+	overBudget := fmt.Errorf("%w: cluster %s would cost $%.2f per month, $%.2f of the budget is left",
+		ErrOverBudget, spec.Name, estimate.Total.Monthly, limit)
+	if !downsize {
+		return estimate, nil, overBudget
+	}
+	downsizer, ok := estimator.(Downsizer)
+	if !ok {
+		return estimate, nil, fmt.Errorf("%w; the provider offers no cheaper sizes", overBudget)
+	}
+
+	// Downsize the most expensive pools first
+	pools := map[string]PoolCost{}
+	for _, pool := range estimate.Pools {
+		pools[pool.Pool] = pool
+	}
+	candidates := append([]string(nil), resizable...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return pools[candidates[i]].Total.Monthly > pools[candidates[j]].Total.Monthly
+	})
+
+	original := append([]NodePool(nil), spec.Pools...)
+	var resizes []Resize
+	for _, name := range candidates {
+		pool, ok := spec.Pool(name)
+		if !ok || pool.Count == 0 {
+			continue
+		}
+		sizes, err := downsizer.CheaperSizes(pool.Size)
+		if err != nil {
+			return estimate, nil, err
+		}
+		if len(sizes) == 0 {
+			continue
+		}
+
+		// Take the largest cheaper size that fits, or the cheapest and move on
+		from := pool.Size
+		for _, size := range sizes {
+			pool.Size = size
+			if estimate, err = estimator.EstimateCost(*spec); err != nil {
+				return estimate, nil, err
+			}
+			if estimate.Total.Monthly <= limit {
+				break
+			}
+		}
+		resizes = append(resizes, Resize{Pool: name, From: from, To: pool.Size})
+		if estimate.Total.Monthly <= limit {
+			return estimate, resizes, nil
+		}
+	}
+
+	copy(spec.Pools, original)
+	return estimate, nil, fmt.Errorf("%w: cluster %s would still cost $%.2f per month with the cheapest sizes, $%.2f of the budget is left",
+		ErrOverBudget, spec.Name, estimate.Total.Monthly, limit)
+}
@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// priceList prices sizes from a table and offers the cheaper sizes of the
+// list as downsizes
+type priceList struct {
+	sizes  []string // most expensive first
+	prices map[string]float64
+}
+
+func (p priceList) EstimateCost(spec ClusterSpec) (CostEstimate, error) {
+	return estimateCost(spec.Name, spec.Pools, func(size string) (Price, error) {
+		return Price{Monthly: p.prices[size]}, nil
+	})
+}
+
+func (p priceList) ClusterCost(name string) (CostEstimate, error) {
+	return CostEstimate{Cluster: name}, nil
+}
+
+func (p priceList) CheaperSizes(size string) ([]string, error) {
+	for i, s := range p.sizes {
+		if s == size {
+			return p.sizes[i+1:], nil
+		}
+	}
+	return nil, nil
+}
+
+// fixedPrices has no cheaper sizes to offer
+type fixedPrices struct{ prices priceList }
+
+func (f fixedPrices) EstimateCost(spec ClusterSpec) (CostEstimate, error) {
+	return f.prices.EstimateCost(spec)
+}
+
+func (f fixedPrices) ClusterCost(name string) (CostEstimate, error) {
+	return f.prices.ClusterCost(name)
+}
+
+func TestFitBudget(t *testing.T) {
+	prices := priceList{
+		sizes:  []string{"large", "medium", "small"},
+		prices: map[string]float64{"large": 48, "medium": 24, "small": 12},
+	}
+	pools := func() []NodePool {
+		return []NodePool{
+			{Name: "controlplane", Role: RoleControlPlane, Count: 1, Size: "medium"},
+			{Name: "worker", Role: RoleWorker, Count: 2, Size: "large"},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		limit       float64
+		downsize    bool
+		resizable   []string
+		estimator   CostEstimator
+		want        []Resize
+		wantSizes   []string
+		shouldError bool
+	}{
+		{
+			name:      "within budget",
+			limit:     120,
+			estimator: prices,
+			wantSizes: []string{"medium", "large"},
+		},
+		{
+			name:        "over budget",
+			limit:       100,
+			estimator:   prices,
+			wantSizes:   []string{"medium", "large"},
+			shouldError: true,
+		},
+		{
+			name:      "downsize the most expensive pool",
+			limit:     80,
+			downsize:  true,
+			resizable: []string{"controlplane", "worker"},
+			estimator: prices,
+			want:      []Resize{{Pool: "worker", From: "large", To: "medium"}},
+			wantSizes: []string{"medium", "medium"},
+		},
+		{
+			name:      "downsize several pools",
+			limit:     36,
+			downsize:  true,
+			resizable: []string{"controlplane", "worker"},
+			estimator: prices,
+			want:      []Resize{{Pool: "worker", From: "large", To: "small"}, {Pool: "controlplane", From: "medium", To: "small"}},
+			wantSizes: []string{"small", "small"},
+		},
+		{
+			name:        "only new pools are resized",
+			limit:       80,
+			downsize:    true,
+			resizable:   []string{"controlplane"},
+			estimator:   prices,
+			wantSizes:   []string{"medium", "large"},
+			shouldError: true,
+		},
+		{
+			name:        "no cheaper sizes",
+			limit:       80,
+			downsize:    true,
+			resizable:   []string{"worker"},
+			estimator:   fixedPrices{prices},
+			wantSizes:   []string{"medium", "large"},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := ClusterSpec{Name: "test", Pools: pools()}
+			estimate, resizes, err := FitBudget(&spec, tt.limit, tt.downsize, tt.resizable, tt.estimator)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("FitBudget() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if tt.shouldError && !errors.Is(err, ErrOverBudget) {
+				t.Errorf("Expected ErrOverBudget, got %v", err)
+			}
+			if !tt.shouldError && estimate.Total.Monthly > tt.limit {
+				t.Errorf("FitBudget() estimate %.2f exceeds the limit %.2f", estimate.Total.Monthly, tt.limit)
+			}
+			if !reflect.DeepEqual(resizes, tt.want) {
+				t.Errorf("FitBudget() resizes = %+v, want %+v", resizes, tt.want)
+			}
+			for i, size := range tt.wantSizes {
+				if spec.Pools[i].Size != size {
+					t.Errorf("Expected pool %s to have size %s, got %s", spec.Pools[i].Name, size, spec.Pools[i].Size)
+				}
+			}
+		})
+	}
+}
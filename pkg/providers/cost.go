@@ -0,0 +1,66 @@
+package providers
+
+// Price is a cost in US dollars
+type Price struct {
+	Hourly  float64 `json:"hourly" yaml:"hourly"`
+	Monthly float64 `json:"monthly" yaml:"monthly"`
+}
+
+// Add returns the sum of two prices
+func (p Price) Add(other Price) Price {
+	return Price{Hourly: p.Hourly + other.Hourly, Monthly: p.Monthly + other.Monthly}
+}
+
+// Times returns the price of n units
+func (p Price) Times(n int) Price {
+	return Price{Hourly: p.Hourly * float64(n), Monthly: p.Monthly * float64(n)}
+}
+
+// PoolCost is the cost of the nodes of one size in a pool
+type PoolCost struct {
+	Pool  string `json:"pool" yaml:"pool"`
+	Role  string `json:"role" yaml:"role"`
+	Size  string `json:"size" yaml:"size"`
+	Count int    `json:"count" yaml:"count"`
+	// Node is the price of a single node, Total of all of them
+	Node  Price `json:"node" yaml:"node"`
+	Total Price `json:"total" yaml:"total"`
+}
+
+// CostEstimate is the cost of a cluster's nodes at list prices. Load
+// balancers, extra addresses, backups and transfer overages are not included.
+type CostEstimate struct {
+	Cluster string     `json:"cluster" yaml:"cluster"`
+	Pools   []PoolCost `json:"pools" yaml:"pools"`
+	Total   Price      `json:"total" yaml:"total"`
+}
+
+// CostEstimator is implemented by providers that publish a pricing catalog
+type CostEstimator interface {
+	// EstimateCost prices the pools of spec at their node count
+	EstimateCost(spec ClusterSpec) (CostEstimate, error)
+	// ClusterCost prices the nodes the named cluster runs now
+	ClusterCost(name string) (CostEstimate, error)
+}
+
+// estimateCost prices pools with the price of their size
+func estimateCost(cluster string, pools []NodePool, price func(size string) (Price, error)) (CostEstimate, error) {
+	estimate := CostEstimate{Cluster: cluster}
+	for _, pool := range pools {
+		node, err := price(pool.Size)
+		if err != nil {
+			return CostEstimate{}, err
+		}
+		cost := PoolCost{
+			Pool:  pool.Name,
+			Role:  pool.Role,
+			Size:  pool.Size,
+			Count: pool.Count,
+			Node:  node,
+			Total: node.Times(pool.Count),
+		}
+		estimate.Pools = append(estimate.Pools, cost)
+		estimate.Total = estimate.Total.Add(cost.Total)
+	}
+	return estimate, nil
+}
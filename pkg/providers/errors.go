@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kinds of provider errors. Errors returned by providers wrap one of them when
+// the cause is known, so callers can tell them apart with errors.Is.
+var (
+	// ErrUnauthorized means the credentials are missing, invalid or lack a
+	// required permission
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound means a resource such as an instance or image does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrQuotaExceeded means an account limit was reached
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrInvalidRegion means the region does not exist or lacks a capability
+	ErrInvalidRegion = errors.New("invalid region")
+	// ErrInvalidSpec means the cluster spec cannot be provisioned as given
+	ErrInvalidSpec = errors.New("invalid cluster spec")
+	// ErrTransient means the call may succeed when retried, e.g. after rate
+	// limiting, a server error or a network failure
+	ErrTransient = errors.New("transient error")
+	// ErrOverBudget means provisioning was refused because the clusters would
+	// cost more than the configured budget
+	ErrOverBudget = errors.New("over budget")
+)
+
+// APIError is a failed call to a provider's API
+type APIError struct {
+	// Op describes the call, e.g. "create Linode instance"
+	Op string
+	// StatusCode is the HTTP status of the response, or 0 if there was none
+	StatusCode int
+	// Kind is one of the error kinds above, or nil if the cause is unknown
+	Kind error
+	// RetryAfter is how long the API asked clients to wait before retrying
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("failed to %s: %v", e.Op, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error's kind
+func (e *APIError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// IsTransient reports whether retrying the failed operation may succeed
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrTransient)
+}
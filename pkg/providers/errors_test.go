@@ -0,0 +1,77 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestLinodeError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		kind   error
+		status int
+	}{
+		{name: "invalid token", err: &linodego.Error{Code: 401, Message: "Invalid Token"}, kind: ErrUnauthorized, status: 401},
+		{name: "missing scope", err: &linodego.Error{Code: 403, Message: "Unauthorized"}, kind: ErrUnauthorized, status: 403},
+		{name: "not found", err: &linodego.Error{Code: 404, Message: "Not found"}, kind: ErrNotFound, status: 404},
+		{name: "rate limited", err: &linodego.Error{Code: 429, Message: "Too Many Requests"}, kind: ErrTransient, status: 429},
+		{name: "bad gateway", err: linodego.Error{Code: 502, Message: "Bad Gateway"}, kind: ErrTransient, status: 502},
+		{name: "account limit", err: &linodego.Error{Code: 400, Message: "Account Limit reached. Please open a support ticket."}, kind: ErrQuotaExceeded, status: 400},
+		{name: "invalid region", err: &linodego.Error{Code: 400, Message: "[region] region is not valid"}, kind: ErrInvalidRegion, status: 400},
+		{name: "invalid type", err: &linodego.Error{Code: 400, Message: "[type] A valid plan type by that ID was not found"}, kind: ErrInvalidSpec, status: 400},
+		{name: "network failure", err: linodego.NewError(fmt.Errorf("dial tcp: connection refused")), kind: ErrTransient},
+		{name: "deadline", err: fmt.Errorf("waiting: %w", context.DeadlineExceeded), kind: ErrTransient},
+		{name: "unknown", err: fmt.Errorf("unexpected"), kind: nil},
+	}
+
+	kinds := []error{ErrUnauthorized, ErrNotFound, ErrQuotaExceeded, ErrInvalidRegion, ErrInvalidSpec, ErrTransient}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := linodeError("create Linode instance", tt.err)
+
+			for _, kind := range kinds {
+				if got := errors.Is(err, kind); got != (kind == tt.kind) {
+					t.Errorf("errors.Is(%v, %v) = %v", err, kind, got)
+				}
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected an APIError, got %T", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, apiErr.StatusCode)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Error("Expected the linodego error to be wrapped")
+			}
+		})
+	}
+}
+
+func TestErrorKindsSurviveWrapping(t *testing.T) {
+	err := fmt.Errorf("creating cluster: %w", linodeError("list instances", &linodego.Error{Code: 503}))
+	if !IsTransient(err) {
+		t.Errorf("IsTransient(%v) = false, expected true", err)
+	}
+
+	spec := ClusterSpec{TalosVersion: "v1.6.0"}
+	if err := spec.Validate(); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("Validate() error = %v, expected ErrInvalidSpec", err)
+	}
+
+	_, _, err = planPoolScale(NodePool{Name: "controlplane", Role: RoleControlPlane, Count: 1}, poolInstances("cloud1", "controlplane", 0, 1))
+	if !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("planPoolScale() error = %v, expected ErrInvalidSpec", err)
+	}
+
+	if _, err := (&LinodeProvider{}).resolveImage(ClusterSpec{}); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("resolveImage() error = %v, expected ErrInvalidSpec without an image or schematic", err)
+	}
+}
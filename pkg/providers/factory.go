@@ -31,7 +31,7 @@ func (f *DefaultProviderFactory) RegisterProvider(name string, creator func(Prov
 // CreateProvider instantiates a cloud provider based on configuration
 func (f *DefaultProviderFactory) CreateProvider(config Provider) (CloudProvider, error) {
 	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid provider configuration: %v", err)
+		return nil, fmt.Errorf("invalid provider configuration: %w", err)
 	}
 
 	creator, ok := f.registeredProviders[config.Name]
@@ -0,0 +1,46 @@
+package providers
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Ports opened by the cluster firewall
+const (
+	TalosAPIPort      = 50000
+	TrustdPort        = 50001
+	KubernetesAPIPort = 6443
+	WireGuardPort     = 51820
+)
+
+// DefaultIngressPorts are opened to IngressCIDRs unless other ports are given
+var DefaultIngressPorts = []int{80, 443}
+
+// FirewallSpec restricts inbound traffic to the cluster nodes. Nodes reach
+// each other on any port; everything else not allowed here is dropped.
+type FirewallSpec struct {
+	// Disabled leaves the nodes without a firewall
+	Disabled bool
+	// AdminCIDRs may reach the Talos API, the Kubernetes API and WireGuard;
+	// empty allows any address
+	AdminCIDRs []string
+	// IngressPorts are the TCP ports opened to IngressCIDRs, which allows any
+	// address when empty
+	IngressPorts []int
+	IngressCIDRs []string
+}
+
+// Validate checks the CIDRs and ports
+func (f *FirewallSpec) Validate() error {
+	for _, cidr := range append(append([]string(nil), f.AdminCIDRs...), f.IngressCIDRs...) {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			return fmt.Errorf("invalid firewall CIDR %q: %w", cidr, err)
+		}
+	}
+	for _, port := range f.IngressPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid firewall ingress port %d", port)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"regexp"
+	"strings"
+)
+
+var nonPoolNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Importer is implemented by providers that can adopt instances created
+// outside the tool into a cluster
+type Importer interface {
+	// DiscoverNodes lists the instances in the provider's region carrying
+	// tag, or when tag is empty, whose label starts with labelPrefix
+	DiscoverNodes(tag, labelPrefix string) ([]NodeStatus, error)
+	// AdoptNodes tags the nodes as members of the named cluster, with the
+	// role and pool set on each node, so the tool manages them from then on
+	AdoptNodes(name string, nodes []NodeStatus) error
+}
+
+// InferPools groups nodes into pools by role and size and sets the pool of
+// each node; nodes without a known role are left out. A role with a single
+// size gets the default pool name; a role with several sizes gets one pool
+// per size, named after the size.
+func InferPools(nodes []NodeStatus) []NodePool {
+	sizes := map[string][]string{}
+	for _, node := range nodes {
+		if !containsString(sizes[node.Role], node.Size) {
+			sizes[node.Role] = append(sizes[node.Role], node.Size)
+		}
+	}
+
+	var pools []NodePool
+	index := map[string]int{}
+	for _, role := range []string{RoleControlPlane, RoleWorker} {
+		name := DefaultWorkerPool
+		if role == RoleControlPlane {
+			name = DefaultControlPlanePool
+		}
+		for _, size := range sizes[role] {
+			pool := NodePool{Name: name, Role: role, Size: size}
+			if len(sizes[role]) > 1 {
+				pool.Name = name + "-" + strings.Trim(nonPoolNameChars.ReplaceAllString(strings.ToLower(size), "-"), "-")
+			}
+			index[role+"/"+size] = len(pools)
+			pools = append(pools, pool)
+		}
+	}
+
+	for i := range nodes {
+		j, ok := index[nodes[i].Role+"/"+nodes[i].Size]
+		if !ok {
+			continue
+		}
+		pool := &pools[j]
+		pool.Count++
+		nodes[i].Pool = pool.Name
+	}
+	return pools
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
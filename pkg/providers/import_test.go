@@ -0,0 +1,40 @@
+package providers
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestInferPools(t *testing.T) {
+	nodes := []NodeStatus{
+		{Label: "cp-1", Role: RoleControlPlane, Size: "g6-standard-2"},
+		{Label: "w-1", Role: RoleWorker, Size: "g6-standard-4"},
+		{Label: "cp-2", Role: RoleControlPlane, Size: "g6-standard-2"},
+		{Label: "gpu-1", Role: RoleWorker, Size: "g1-gpu-rtx6000-1"},
+		{Label: "w-2", Role: RoleWorker, Size: "g6-standard-4"},
+		{Label: "unknown", Size: "g6-nanode-1"},
+	}
+
+	pools := InferPools(nodes)
+
+	expected := []NodePool{
+		{Name: "controlplane", Role: RoleControlPlane, Count: 2, Size: "g6-standard-2"},
+		{Name: "worker-g6-standard-4", Role: RoleWorker, Count: 2, Size: "g6-standard-4"},
+		{Name: "worker-g1-gpu-rtx6000-1", Role: RoleWorker, Count: 1, Size: "g1-gpu-rtx6000-1"},
+	}
+	if fmt.Sprint(pools) != fmt.Sprint(expected) {
+		t.Errorf("InferPools() = %v, expected %v", pools, expected)
+	}
+	for _, pool := range pools {
+		if err := pool.Validate(); err != nil {
+			t.Errorf("Expected inferred pool %s to be valid, got %v", pool.Name, err)
+		}
+	}
+
+	want := []string{"controlplane", "worker-g6-standard-4", "controlplane", "worker-g1-gpu-rtx6000-1", "worker-g6-standard-4", ""}
+	for i, node := range nodes {
+		if node.Pool != want[i] {
+			t.Errorf("Node %s pool = %q, expected %q", node.Label, node.Pool, want[i])
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package providers
+
+import "time"
+
+// Kinds of resources the tool creates
+const (
+	ResourceInstance     = "instance"
+	ResourceLoadBalancer = "loadbalancer"
+	ResourceFirewall     = "firewall"
+	ResourceImage        = "image"
+)
+
+// Resource is a provider resource created by the tool
+type Resource struct {
+	Kind  string `json:"kind" yaml:"kind"`
+	ID    string `json:"id" yaml:"id"`
+	Label string `json:"label" yaml:"label"`
+	// Cluster is the cluster the resource is tagged with, empty for shared
+	// resources such as images and for untagged legacy instances
+	Cluster   string    `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	CreatedAt time.Time `json:"createdAt,omitempty" yaml:"createdAt,omitempty"`
+	// Cost is the list price of keeping the resource
+	Cost Price `json:"cost" yaml:"cost"`
+}
+
+// Age returns how long ago the resource was created, or 0 if unknown
+func (r Resource) Age(now time.Time) time.Duration {
+	if r.CreatedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(r.CreatedAt)
+}
+
+// Inventory is implemented by providers that can list and delete everything
+// the tool created, across regions
+type Inventory interface {
+	// Resources lists the resources created by the tool, in the order they
+	// can be deleted in: instances before what they are attached to
+	Resources() ([]Resource, error)
+	// Owned reports whether a resource belongs to one of the clusters
+	Owned(resource Resource, clusters []ClusterSpec) bool
+	DeleteResource(resource Resource) error
+}
+
+// Orphans returns the resources none of the clusters own, in deletion order
+func Orphans(inventory Inventory, resources []Resource, clusters []ClusterSpec) []Resource {
+	var orphans []Resource
+	for _, resource := range resources {
+		if !inventory.Owned(resource, clusters) {
+			orphans = append(orphans, resource)
+		}
+	}
+	return orphans
+}
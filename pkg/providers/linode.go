@@ -2,10 +2,18 @@ package providers
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"talos-autoextender/pkg/imagefactory"
+
 	"github.com/linode/linodego"
 	"golang.org/x/oauth2"
 )
@@ -15,13 +23,15 @@ type LinodeProvider struct {
 	config  Provider
 	client  linodego.Client
 	context context.Context
+	retry   *Retrier
+	catalog linodeCatalog
 }
 
 // NewLinodeProvider creates a new Linode provider
 func NewLinodeProvider(config Provider) (*LinodeProvider, error) {
 	apiKey, ok := config.Credentials["api_key"]
 	if !ok {
-		return nil, fmt.Errorf("Linode API key not found in credentials")
+		return nil, fmt.Errorf("%w: Linode API key not found in credentials", ErrUnauthorized)
 	}
 
 	ctx := context.Background()
@@ -30,129 +40,316 @@ func NewLinodeProvider(config Provider) (*LinodeProvider, error) {
 
 	client := linodego.NewClient(oauth2Client)
 	client.SetDebug(false)
+	// Retries are left to the Retrier, which also covers network errors and
+	// gives up after a bounded number of attempts
+	client.SetRetryCount(0)
 
 	return &LinodeProvider{
 		config:  config,
 		client:  client,
 		context: ctx,
+		retry:   NewRetrier(),
 	}, nil
 }
 
+// call runs an idempotent Linode API call, retrying transient failures
+func (l *LinodeProvider) call(op string, fn func() error) error {
+	return l.retry.Do(l.context, op, func() error {
+		return linodeError(op, fn())
+	})
+}
+
+// callOnce runs a Linode API call that must not be repeated once accepted
+func (l *LinodeProvider) callOnce(op string, fn func() error) error {
+	return l.retry.DoOnce(l.context, op, func() error {
+		return linodeError(op, fn())
+	})
+}
+
 // CreateCluster creates a Talos cluster on Linode
 func (l *LinodeProvider) CreateCluster(spec ClusterSpec) error {
-	// Validate region
-	regions, err := l.client.ListRegions(l.context, nil)
+	if err := l.validateRegion(); err != nil {
+		return err
+	}
+	if err := l.validateSizes(spec.Pools); err != nil {
+		return err
+	}
+
+	imageID, err := l.resolveImage(spec)
 	if err != nil {
-		return fmt.Errorf("failed to list Linode regions: %v", err)
+		return err
 	}
 
-	validRegion := false
-	for _, region := range regions {
-		if region.ID == l.config.Region {
-			validRegion = true
-			break
+	attachments, err := l.nodeAttachments(spec)
+	if err != nil {
+		return err
+	}
+
+	// Create nodes pool by pool, control plane pools first
+	for _, pool := range orderedPools(spec.Pools) {
+		for i := 0; i < pool.Count; i++ {
+			if err := l.createNode(spec, pool, i, imageID, attachments); err != nil {
+				return err
+			}
 		}
 	}
 
-	if !validRegion {
-		return fmt.Errorf("invalid Linode region: %s", l.config.Region)
+	return l.syncAttachments(spec, attachments)
+}
+
+// nodeAttachments are the cluster resources new nodes are attached to
+type nodeAttachments struct {
+	firewallID       int
+	subnetID         int
+	placementGroupID int
+	nodeBalancer     *linodego.NodeBalancer
+}
+
+// nodeAttachments creates the firewall and VPC subnet of the spec before the
+// nodes so they are never exposed, and the placement group so control plane
+// nodes are spread from the start
+func (l *LinodeProvider) nodeAttachments(spec ClusterSpec) (nodeAttachments, error) {
+	var attachments nodeAttachments
+	var err error
+	if attachments.firewallID, err = l.ensureFirewall(spec); err != nil {
+		return nodeAttachments{}, err
+	}
+	if attachments.subnetID, err = l.ensureSubnet(spec); err != nil {
+		return nodeAttachments{}, err
+	}
+	if attachments.placementGroupID, err = l.ensurePlacementGroup(spec); err != nil {
+		return nodeAttachments{}, err
+	}
+	if attachments.nodeBalancer, err = l.ensureNodeBalancer(spec, attachments.firewallID); err != nil {
+		return nodeAttachments{}, err
+	}
+	return attachments, nil
+}
+
+// syncAttachments updates the firewall, placement group, load balancer and
+// shared ingress address to the cluster's current nodes
+func (l *LinodeProvider) syncAttachments(spec ClusterSpec, attachments nodeAttachments) error {
+	if err := l.syncFirewall(spec, attachments.firewallID); err != nil {
+		return err
+	}
+	if err := l.syncPlacementGroup(spec, attachments.placementGroupID); err != nil {
+		return err
+	}
+	if err := l.syncNodeBalancer(spec, attachments.nodeBalancer); err != nil {
+		return err
 	}
+	return l.ensureIngressIP(spec)
+}
 
-	// Get Talos image ID (in a real implementation, we would find or upload the proper image)
-	// For now, we'll use a Debian image as a placeholder
-	images, err := l.client.ListImages(l.context, nil)
+func (l *LinodeProvider) validateRegion() error {
+	regions, err := l.regions()
 	if err != nil {
-		return fmt.Errorf("failed to list Linode images: %v", err)
+		return err
 	}
 
-	var imageID string
-	for _, image := range images {
-		if image.ID == "linode/debian11" {
-			imageID = image.ID
-			break
+	for _, region := range regions {
+		if region.ID == l.config.Region {
+			return nil
 		}
 	}
 
-	if imageID == "" {
-		return fmt.Errorf("Debian 11 image not found")
+	return fmt.Errorf("%w: %s is not a Linode region", ErrInvalidRegion, l.config.Region)
+}
+
+// resolveImage returns the image nodes boot from: the given image or the
+// Image Factory build of the schematic. Without either nodes would not boot
+// Talos, so there is nothing to fall back to.
+func (l *LinodeProvider) resolveImage(spec ClusterSpec) (string, error) {
+	if spec.Image != "" {
+		return spec.Image, nil
 	}
+	if spec.SchematicID != "" {
+		return l.factoryImage(spec)
+	}
+	return "", fmt.Errorf("%w: a Talos image or Image Factory schematic is required to create nodes", ErrInvalidSpec)
+}
 
-	// Create nodes
-	for i := 0; i < spec.NodeCount; i++ {
-		nodeName := fmt.Sprintf("talos-node-%d", i)
+// createNode creates node index of a pool and waits for it to boot
+func (l *LinodeProvider) createNode(spec ClusterSpec, pool NodePool, index int, imageID string, attachments nodeAttachments) error {
+	nodeName := fmt.Sprintf("talos-node-%d", index)
+	if spec.Name != "" {
+		nodeName = nodeLabel(spec.Name, pool.Name, index)
+	}
 
-		createOpts := linodego.InstanceCreateOptions{
-			Region:   l.config.Region,
-			Type:     spec.NodeSize,
-			Label:    nodeName,
-			Image:    imageID,
-			RootPass: generateRandomPassword(), // In production, use proper secret management
-			Tags:     []string{"talos-autoextender", "talos-node"},
-		}
+	tags := []string{"talos-autoextender", "talos-node", "role:" + pool.Role, "pool:" + pool.Name}
+	if spec.Name != "" {
+		tags = append(tags, clusterTag(spec.Name))
+	}
 
-		instance, err := l.client.CreateInstance(l.context, createOpts)
-		if err != nil {
-			return fmt.Errorf("failed to create Linode instance: %v", err)
-		}
+	createOpts := linodego.InstanceCreateOptions{
+		Region:     l.config.Region,
+		Type:       pool.Size,
+		Label:      nodeName,
+		Image:      imageID,
+		RootPass:   generateRandomPassword(), // In production, use proper secret management
+		Tags:       tags,
+		FirewallID: attachments.firewallID,
+	}
+	// NodeBalancers reach their backends on Linode private addresses
+	createOpts.PrivateIP = attachments.nodeBalancer != nil
+	if attachments.subnetID != 0 {
+		createOpts.Interfaces = vpcInterfaces(attachments.subnetID, createOpts.PrivateIP)
+		createOpts.Tags = append(createOpts.Tags, vpcTag)
+	}
+	if attachments.placementGroupID != 0 && pool.Role == RoleControlPlane {
+		createOpts.PlacementGroup = &linodego.InstanceCreatePlacementGroupOptions{ID: attachments.placementGroupID}
+	}
 
-		// Wait for instance to boot
-		err = waitForInstanceStatus(l.context, &l.client, instance.ID, linodego.InstanceRunning, 300)
-		if err != nil {
-			return fmt.Errorf("instance failed to start: %v", err)
+	// Talos reads its machine config from the metadata service
+	if len(pool.Config) > 0 {
+		createOpts.Metadata = &linodego.InstanceMetadataOptions{
+			UserData: base64.StdEncoding.EncodeToString(pool.Config),
 		}
 	}
 
+	var instance *linodego.Instance
+	err := l.callOnce("create Linode instance "+nodeName, func() (err error) {
+		instance, err = l.client.CreateInstance(l.context, createOpts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Wait for instance to boot
+	err = l.waitForInstanceStatus(instance.ID, linodego.InstanceRunning, 300)
+	if err != nil {
+		return fmt.Errorf("instance %s failed to start: %w", nodeName, err)
+	}
+
 	return nil
 }
 
+// factoryImage returns the private image built by the Image Factory for the
+// spec's schematic, uploading it on first use
+func (l *LinodeProvider) factoryImage(spec ClusterSpec) (string, error) {
+	if !supportsAkamaiPlatform(spec.TalosVersion) {
+		return "", fmt.Errorf("%w: Image Factory images for Linode require Talos v1.7 or later; upload an image and pass it as the image", ErrInvalidSpec)
+	}
+
+	label := factoryImageLabel(spec.SchematicID, spec.TalosVersion)
+
+	images, err := l.privateImages()
+	if err != nil {
+		return "", err
+	}
+	for _, image := range images {
+		if image.Label == label && image.Status == linodego.ImageStatusAvailable {
+			return image.ID, nil
+		}
+	}
+
+	factory := imagefactory.NewClient(spec.ImageFactory)
+	imageURL := factory.DiskImageURL(spec.SchematicID, spec.TalosVersion, "akamai", "amd64", "raw.gz")
+
+	disk, err := factory.Download(l.context, imageURL)
+	if err != nil {
+		return "", err
+	}
+	defer disk.Close()
+
+	// Not retried: the upload streams the download, which cannot be replayed
+	image, err := l.client.UploadImage(l.context, linodego.ImageUploadOptions{
+		Region:      l.config.Region,
+		Label:       label,
+		Description: fmt.Sprintf("Talos %s, schematic %s", spec.TalosVersion, spec.SchematicID),
+		Image:       disk,
+	})
+	if err != nil {
+		return "", linodeError("upload Talos image", err)
+	}
+
+	available, err := l.client.WaitForImageStatus(l.context, image.ID, linodego.ImageStatusAvailable, 1800)
+	if err != nil {
+		return "", fmt.Errorf("Talos image %s did not become available: %w", image.ID, linodeError("wait for image", err))
+	}
+	l.addImage(*available)
+
+	return image.ID, nil
+}
+
+// factoryImageLabel names uploaded factory images after the Talos version and
+// schematic, within Linode's 50 character label limit
+func factoryImageLabel(schematicID, version string) string {
+	if len(schematicID) > 12 {
+		schematicID = schematicID[:12]
+	}
+	return fmt.Sprintf("talos-%s-%s", strings.TrimPrefix(version, "v"), schematicID)
+}
+
+// supportsAkamaiPlatform reports whether the Talos version ships the akamai
+// platform that reads user data from the Linode metadata service
+func supportsAkamaiPlatform(version string) bool {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return major > 1 || (major == 1 && minor >= 7)
+}
+
 // DeleteCluster deletes a Talos cluster from Linode
 func (l *LinodeProvider) DeleteCluster(name string) error {
-	// Use tags to find the instances rather than trying to use a map as filter
-	options := linodego.NewListOptions(0, "")
-	instances, err := l.client.ListInstances(l.context, options)
+	// Resources are found by cluster name, so an empty one would match the
+	// nodes of every cluster
+	if name == "" {
+		return fmt.Errorf("%w: a cluster name is required to delete a cluster", ErrInvalidSpec)
+	}
+
+	instances, err := l.clusterInstances(name)
 	if err != nil {
-		return fmt.Errorf("failed to list instances: %v", err)
+		return err
 	}
 
-	// Filter instances with the talos-autoextender tag
+	// Subnets in VPCs joined by name are found through the nodes, so look
+	// them up before the nodes are gone
+	var joined []int
 	for _, instance := range instances {
-		hasTalosTag := false
-		for _, tag := range instance.Tags {
-			if tag == "talos-autoextender" {
-				hasTalosTag = true
-				break
-			}
+		if !hasTag(instance.Tags, vpcTag) {
+			continue
 		}
+		ids, err := l.instanceVPCIDs(instance)
+		if err != nil {
+			return err
+		}
+		joined = append(joined, ids...)
+	}
 
-		if hasTalosTag {
-			err := l.client.DeleteInstance(l.context, instance.ID)
-			if err != nil {
-				return fmt.Errorf("failed to delete instance %d: %v", instance.ID, err)
-			}
+	for _, instance := range instances {
+		if err := l.deleteInstance(instance); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	if err := l.deleteNodeBalancer(name); err != nil {
+		return err
+	}
+	if err := l.deletePlacementGroup(name); err != nil {
+		return err
+	}
+	if err := l.deleteFirewall(name); err != nil {
+		return err
+	}
+	return l.deleteVPC(name, joined)
 }
 
 // GetClusterStatus returns the status of a Talos cluster on Linode
 func (l *LinodeProvider) GetClusterStatus(name string) (ClusterStatus, error) {
-	// Use tags to find the instances rather than trying to use a map as filter
-	options := linodego.NewListOptions(0, "")
-	instances, err := l.client.ListInstances(l.context, options)
+	filteredInstances, err := l.clusterInstances(name)
 	if err != nil {
-		return ClusterStatus{}, fmt.Errorf("failed to list instances: %v", err)
-	}
-
-	// Count instances with the talos-autoextender tag
-	var filteredInstances []linodego.Instance
-	for _, instance := range instances {
-		for _, tag := range instance.Tags {
-			if tag == "talos-autoextender" {
-				filteredInstances = append(filteredInstances, instance)
-				break
-			}
-		}
+		return ClusterStatus{}, err
 	}
 
 	status := ClusterStatus{
@@ -165,9 +362,45 @@ func (l *LinodeProvider) GetClusterStatus(name string) (ClusterStatus, error) {
 		if instance.Status == linodego.InstanceRunning {
 			readyCount++
 		}
+
+		node := instanceStatus(instance)
+		if hasTag(instance.Tags, vpcTag) {
+			vpcIPs, err := l.instanceVPCIPs(instance)
+			if err != nil {
+				return ClusterStatus{}, err
+			}
+			node.PrivateIPs = append(vpcIPs, node.PrivateIPs...)
+		}
+		status.Nodes = append(status.Nodes, node)
+		if node.Role == RoleControlPlane {
+			status.Endpoints.Talos = append(status.Endpoints.Talos, node.PublicIPs...)
+		}
+	}
+
+	nodeBalancer, err := l.findNodeBalancer(name)
+	if err != nil {
+		return ClusterStatus{}, err
+	}
+	if nodeBalancer != nil {
+		status.Endpoints.LoadBalancer = nodeBalancerAddresses(nodeBalancer)
+	}
+	group, err := l.findPlacementGroup(name)
+	if err != nil {
+		return ClusterStatus{}, err
+	}
+	if group != nil {
+		status.Placement = placementStatus(*group, filteredInstances)
+	}
+	address, holder := ingressIP(filteredInstances)
+	status.Endpoints.IngressIP = address
+	if holder != nil {
+		status.Endpoints.IngressNode = holder.Label
 	}
 
 	status.ReadyNodeCount = readyCount
+	status.Pools = poolStatuses(status.Nodes, func(node NodeStatus) bool {
+		return node.State == string(linodego.InstanceRunning)
+	})
 
 	if readyCount == len(filteredInstances) && readyCount > 0 {
 		status.State = "ready"
@@ -182,18 +415,187 @@ func (l *LinodeProvider) GetClusterStatus(name string) (ClusterStatus, error) {
 	return status, nil
 }
 
-// UpdateCluster updates an existing Talos cluster on Linode
+// UpdateCluster scales each pool of the spec to its node count. New nodes take
+// the lowest free index; workers are removed from the highest index down.
+// Control plane pools are not scaled down as their etcd members would have
+// to be removed first.
 func (l *LinodeProvider) UpdateCluster(spec ClusterSpec) error {
-	// For now, just stub this out for the tests to compile
-	return nil
+	if err := l.validateSizes(spec.Pools); err != nil {
+		return err
+	}
+
+	instances, err := l.clusterInstances(spec.Name)
+	if err != nil {
+		return err
+	}
+
+	attachments, err := l.nodeAttachments(spec)
+	if err != nil {
+		return err
+	}
+
+	imageID := ""
+	for _, pool := range orderedPools(spec.Pools) {
+		var existing []linodego.Instance
+		for _, instance := range instances {
+			if instancePool(instance) == pool.Name {
+				existing = append(existing, instance)
+			}
+		}
+
+		create, remove, err := planPoolScale(pool, existing)
+		if err != nil {
+			return err
+		}
+
+		if len(create) > 0 && imageID == "" {
+			if imageID, err = l.resolveImage(spec); err != nil {
+				return err
+			}
+		}
+		for _, index := range create {
+			if err := l.createNode(spec, pool, index, imageID, attachments); err != nil {
+				return err
+			}
+		}
+
+		for _, instance := range remove {
+			if err := l.deleteInstance(instance); err != nil {
+				return err
+			}
+		}
+	}
+
+	return l.syncAttachments(spec, attachments)
+}
+
+// clusterInstances returns the instances of the named cluster
+func (l *LinodeProvider) clusterInstances(name string) ([]linodego.Instance, error) {
+	// The API filters on the cluster tag, or for instances created before
+	// clusters were tagged by name, on the cluster's label prefix; the tool
+	// tag and the exact label match are checked here. Page 0 fetches every
+	// page.
+	if name == "" {
+		return nil, fmt.Errorf("%w: a cluster name is required to list its instances", ErrInvalidSpec)
+	}
+	options := linodego.NewListOptions(0, clusterFilter(name))
+	var instances []linodego.Instance
+	err := l.call("list instances", func() (err error) {
+		instances, err = l.client.ListInstances(l.context, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []linodego.Instance
+	for _, instance := range instances {
+		if belongsToCluster(instance, name) {
+			filtered = append(filtered, instance)
+		}
+	}
+
+	return filtered, nil
+}
+
+// deleteInstance deletes an instance. A retried delete finding the instance
+// already gone succeeds.
+func (l *LinodeProvider) deleteInstance(instance linodego.Instance) error {
+	err := l.call("delete instance "+instance.Label, func() error {
+		return l.client.DeleteInstance(l.context, instance.ID)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// planPoolScale returns the node indexes to create and the instances to delete
+// to bring a pool to its count
+func planPoolScale(pool NodePool, existing []linodego.Instance) ([]int, []linodego.Instance, error) {
+	if len(existing) > pool.Count && pool.Role == RoleControlPlane {
+		return nil, nil, fmt.Errorf("%w: pool %s: scaling control plane nodes down from %d to %d is not supported", ErrInvalidSpec, pool.Name, len(existing), pool.Count)
+	}
+
+	used := map[int]bool{}
+	for _, instance := range existing {
+		used[instanceIndex(instance.Label)] = true
+	}
+
+	var create []int
+	for index := 0; len(existing)+len(create) < pool.Count; index++ {
+		if !used[index] {
+			create = append(create, index)
+		}
+	}
+
+	var remove []linodego.Instance
+	if excess := len(existing) - pool.Count; excess > 0 {
+		sorted := append([]linodego.Instance(nil), existing...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return instanceIndex(sorted[i].Label) > instanceIndex(sorted[j].Label)
+		})
+		remove = sorted[:excess]
+	}
+
+	return create, remove, nil
+}
+
+// orderedPools returns control plane pools first, keeping the given order
+func orderedPools(pools []NodePool) []NodePool {
+	ordered := make([]NodePool, 0, len(pools))
+	for _, pool := range pools {
+		if pool.Role == RoleControlPlane {
+			ordered = append(ordered, pool)
+		}
+	}
+	for _, pool := range pools {
+		if pool.Role != RoleControlPlane {
+			ordered = append(ordered, pool)
+		}
+	}
+	return ordered
+}
+
+func nodeLabel(cluster, pool string, index int) string {
+	return fmt.Sprintf("%s-%s-%d", cluster, pool, index)
+}
+
+// instanceIndex returns the index a node label ends with, or -1
+func instanceIndex(label string) int {
+	i := strings.LastIndex(label, "-")
+	if i < 0 {
+		return -1
+	}
+	index, err := strconv.Atoi(label[i+1:])
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+// instancePool returns the pool of an instance. Instances created before node
+// pools are in the default pool of their role.
+func instancePool(instance linodego.Instance) string {
+	if pool := tagValue(instance.Tags, "pool:"); pool != "" {
+		return pool
+	}
+	if tagValue(instance.Tags, "role:") == RoleControlPlane {
+		return DefaultControlPlanePool
+	}
+	return DefaultWorkerPool
 }
 
 // Helper functions
 
-func waitForInstanceStatus(ctx context.Context, client *linodego.Client, id int, status linodego.InstanceStatus, timeoutSeconds int) error {
+func (l *LinodeProvider) waitForInstanceStatus(id int, status linodego.InstanceStatus, timeoutSeconds int) error {
 	start := time.Now()
 	for {
-		instance, err := client.GetInstance(ctx, id)
+		var instance *linodego.Instance
+		err := l.call(fmt.Sprintf("get instance %d", id), func() (err error) {
+			instance, err = l.client.GetInstance(l.context, id)
+			return err
+		})
 		if err != nil {
 			return err
 		}
@@ -203,13 +605,174 @@ func waitForInstanceStatus(ctx context.Context, client *linodego.Client, id int,
 		}
 
 		if time.Since(start) >= time.Duration(timeoutSeconds)*time.Second {
-			return fmt.Errorf("timed out waiting for instance %d to reach status %s", id, status)
+			return fmt.Errorf("%w: timed out waiting for instance %d to reach status %s", ErrTransient, id, status)
 		}
 
 		time.Sleep(5 * time.Second)
 	}
 }
 
+// linodeError classifies a linodego error by its HTTP status. Linode reports
+// account limits as 400 responses mentioning the limit, and errors raised
+// before a response was received, such as network failures, carry a code
+// below 100.
+func linodeError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	apiErr := &APIError{Op: op, Err: err}
+
+	var e *linodego.Error
+	if !errors.As(err, &e) {
+		var value linodego.Error
+		if !errors.As(err, &value) {
+			if errors.Is(err, context.DeadlineExceeded) {
+				apiErr.Kind = ErrTransient
+			}
+			return apiErr
+		}
+		e = &value
+	}
+
+	if e.Code >= 100 {
+		apiErr.StatusCode = e.Code
+	}
+	if e.Response != nil {
+		apiErr.RetryAfter = retryAfter(e.Response.Header, time.Now())
+	}
+	apiErr.Kind = linodeErrorKind(e.Code, e.Message)
+	return apiErr
+}
+
+func linodeErrorKind(code int, message string) error {
+	switch {
+	case code < 100:
+		return ErrTransient
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrUnauthorized
+	case code == http.StatusNotFound:
+		return ErrNotFound
+	case code == http.StatusTooManyRequests || code >= 500:
+		return ErrTransient
+	case code == http.StatusBadRequest:
+		lower := strings.ToLower(message)
+		if strings.Contains(lower, "limit") || strings.Contains(lower, "quota") {
+			return ErrQuotaExceeded
+		}
+		if strings.Contains(lower, "region") {
+			return ErrInvalidRegion
+		}
+		return ErrInvalidSpec
+	}
+	return nil
+}
+
+func clusterTag(name string) string {
+	return "cluster:" + name
+}
+
+// clusterFilter builds an X-Filter header value matching the instances tagged
+// with the named cluster or labelled with its name as prefix
+func clusterFilter(name string) string {
+	filter := linodego.Or("", "",
+		&linodego.Comp{Column: "tags", Operator: linodego.Eq, Value: clusterTag(name)},
+		&linodego.Comp{Column: "label", Operator: linodego.Contains, Value: name + "-"},
+	)
+	data, err := filter.MarshalJSON()
+	if err != nil {
+		// Only reachable with values encoding/json cannot marshal
+		panic(err)
+	}
+	return string(data)
+}
+
+// belongsToCluster reports whether an instance was created for the named
+// cluster. Instances created before clusters were tagged by name have no
+// cluster tag; they belong only to the cluster whose node label they carry,
+// <name>-<pool>-<index>. An empty name matches no instance.
+func belongsToCluster(instance linodego.Instance, name string) bool {
+	if name == "" || !hasTag(instance.Tags, "talos-autoextender") {
+		return false
+	}
+
+	cluster := tagValue(instance.Tags, "cluster:")
+	switch {
+	case cluster != "":
+		return cluster == name
+	default:
+		return strings.HasPrefix(instance.Label, name+"-") && instanceIndex(instance.Label) >= 0
+	}
+}
+
+// hasTag reports whether tags contain tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// tagValue returns the value of the first tag with the given prefix
+func tagValue(tags []string, prefix string) string {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, prefix) {
+			return strings.TrimPrefix(tag, prefix)
+		}
+	}
+	return ""
+}
+
+// instanceStatus describes an instance of a cluster
+func instanceStatus(instance linodego.Instance) NodeStatus {
+	node := NodeStatus{
+		ID:             strconv.Itoa(instance.ID),
+		Label:          instance.Label,
+		Role:           tagValue(instance.Tags, "role:"),
+		Pool:           instancePool(instance),
+		Size:           instance.Type,
+		State:          string(instance.Status),
+		PublicIPs:      instancePublicIPs(instance),
+		PrivateIPs:     instancePrivateIPs(instance),
+		ConfigInjected: instance.HasUserData,
+	}
+	if instance.Created != nil {
+		node.CreatedAt = *instance.Created
+	}
+	return node
+}
+
+func instancePrivateIPs(instance linodego.Instance) []string {
+	var ips []string
+	for _, ip := range instance.IPv4 {
+		if ip != nil && ip.IsPrivate() {
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips
+}
+
+// instancePublicIPs returns the public IPv4 addresses and SLAAC IPv6 address of an instance
+func instancePublicIPs(instance linodego.Instance) []string {
+	var ips []string
+	for _, ip := range instance.IPv4 {
+		if ip != nil && !ip.IsPrivate() {
+			ips = append(ips, ip.String())
+		}
+	}
+
+	// Linode reports the SLAAC address with its prefix length, e.g. 2600:3c00::1/128
+	if instance.IPv6 != "" {
+		ip, _, err := net.ParseCIDR(instance.IPv6)
+		if err == nil {
+			ips = append(ips, ip.String())
+		}
+	}
+
+	return ips
+}
+
 func generateRandomPassword() string {
 	// In a real implementation, use a proper random password generator
 	// This is just a placeholder
@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/linode/linodego"
+)
+
+// linodeCatalog caches the regions, instance types and private images of the
+// account. They do not change within a run, so each is listed at most once.
+type linodeCatalog struct {
+	mu      sync.Mutex
+	regions []linodego.Region
+	types   []linodego.LinodeType
+	images  []linodego.Image
+}
+
+// regions returns the Linode regions
+func (l *LinodeProvider) regions() ([]linodego.Region, error) {
+	l.catalog.mu.Lock()
+	defer l.catalog.mu.Unlock()
+
+	if l.catalog.regions == nil {
+		var regions []linodego.Region
+		err := l.call("list Linode regions", func() (err error) {
+			regions, err = l.client.ListRegions(l.context, linodego.NewListOptions(0, ""))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		l.catalog.regions = regions
+	}
+	return l.catalog.regions, nil
+}
+
+// instanceTypes returns the Linode instance types
+func (l *LinodeProvider) instanceTypes() ([]linodego.LinodeType, error) {
+	l.catalog.mu.Lock()
+	defer l.catalog.mu.Unlock()
+
+	if l.catalog.types == nil {
+		var types []linodego.LinodeType
+		err := l.call("list Linode types", func() (err error) {
+			types, err = l.client.ListTypes(l.context, linodego.NewListOptions(0, ""))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		l.catalog.types = types
+	}
+	return l.catalog.types, nil
+}
+
+// privateImages returns the account's own images. Public images are listed
+// by the thousand and are looked up by ID instead.
+func (l *LinodeProvider) privateImages() ([]linodego.Image, error) {
+	l.catalog.mu.Lock()
+	defer l.catalog.mu.Unlock()
+
+	if l.catalog.images == nil {
+		var images []linodego.Image
+		err := l.call("list Linode images", func() (err error) {
+			images, err = l.client.ListImages(l.context, linodego.NewListOptions(0, linodeFilter("is_public", false)))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		l.catalog.images = images
+	}
+	return l.catalog.images, nil
+}
+
+// addImage records an image uploaded during the run
+func (l *LinodeProvider) addImage(image linodego.Image) {
+	l.catalog.mu.Lock()
+	defer l.catalog.mu.Unlock()
+
+	if l.catalog.images != nil {
+		l.catalog.images = append(l.catalog.images, image)
+	}
+}
+
+// validateSizes checks that every pool uses an existing instance type
+func (l *LinodeProvider) validateSizes(pools []NodePool) error {
+	types, err := l.instanceTypes()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(types))
+	for _, t := range types {
+		known[t.ID] = true
+	}
+	for _, pool := range pools {
+		if !known[pool.Size] {
+			return fmt.Errorf("%w: pool %s: %s is not a Linode instance type", ErrInvalidSpec, pool.Name, pool.Size)
+		}
+	}
+	return nil
+}
+
+// linodeFilter builds an X-Filter header value matching key exactly
+func linodeFilter(key string, value any) string {
+	filter := linodego.Filter{}
+	filter.AddField(linodego.Eq, key, value)
+	data, err := filter.MarshalJSON()
+	if err != nil {
+		// Only reachable with values encoding/json cannot marshal
+		panic(err)
+	}
+	return string(data)
+}
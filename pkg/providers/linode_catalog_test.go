@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+// catalogServer serves three pages of cluster instances and the region and
+// type catalogs, counting the requests per path
+func catalogServer(t *testing.T) (*httptest.Server, map[string]*int32) {
+	counts := map[string]*int32{
+		"/v4/regions":          new(int32),
+		"/v4/linode/types":     new(int32),
+		"/v4/linode/instances": new(int32),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count, ok := counts[r.URL.Path]; ok {
+			atomic.AddInt32(count, 1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		var response any
+		switch r.URL.Path {
+		case "/v4/regions":
+			response = linodego.RegionsPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 1},
+				Data:        []linodego.Region{{ID: "us-east"}},
+			}
+		case "/v4/linode/types":
+			response = linodego.LinodeTypesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 1},
+				Data:        []linodego.LinodeType{{ID: "g6-standard-2"}},
+			}
+		case "/v4/linode/instances":
+			if filter := r.Header.Get("X-Filter"); filter != `{"+or":[{"tags":"cluster:test"},{"label":{"+contains":"test-"}}]}` {
+				t.Errorf("Expected instances to be filtered by cluster, got X-Filter %q", filter)
+			}
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			cluster := "cluster:test"
+			if page == 2 {
+				cluster = "cluster:other"
+			}
+			response = linodego.InstancesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: page, Pages: 3, Results: 3},
+				Data: []linodego.Instance{{
+					ID:   page,
+					Tags: []string{"talos-autoextender", cluster},
+				}},
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, counts
+}
+
+func TestClusterInstancesFetchesAllPages(t *testing.T) {
+	server, counts := catalogServer(t)
+	provider := testProvider(server, NewRetrier())
+
+	instances, err := provider.clusterInstances("test")
+	if err != nil {
+		t.Fatalf("clusterInstances() error = %v", err)
+	}
+
+	if len(instances) != 2 || instances[0].ID != 1 || instances[1].ID != 3 {
+		t.Errorf("Expected the instances of pages 1 and 3, got %+v", instances)
+	}
+	if got := atomic.LoadInt32(counts["/v4/linode/instances"]); got != 3 {
+		t.Errorf("Expected 3 page requests, got %d", got)
+	}
+}
+
+func TestCatalogIsCached(t *testing.T) {
+	server, counts := catalogServer(t)
+	provider := testProvider(server, NewRetrier())
+
+	for i := 0; i < 3; i++ {
+		if err := provider.validateRegion(); err != nil {
+			t.Fatalf("validateRegion() error = %v", err)
+		}
+		if err := provider.validateSizes([]NodePool{{Name: "worker", Size: "g6-standard-2"}}); err != nil {
+			t.Fatalf("validateSizes() error = %v", err)
+		}
+	}
+
+	for _, path := range []string{"/v4/regions", "/v4/linode/types"} {
+		if got := atomic.LoadInt32(counts[path]); got != 1 {
+			t.Errorf("Expected %s to be listed once, got %d", path, got)
+		}
+	}
+}
+
+func TestValidateSizes(t *testing.T) {
+	server, _ := catalogServer(t)
+	provider := testProvider(server, NewRetrier())
+
+	tests := []struct {
+		name        string
+		pools       []NodePool
+		shouldError bool
+	}{
+		{
+			name:  "known type",
+			pools: []NodePool{{Name: "worker", Size: "g6-standard-2"}},
+		},
+		{
+			name:        "unknown type",
+			pools:       []NodePool{{Name: "worker", Size: "g6-standard-2"}, {Name: "gpu", Size: "g1-gpu-rtx6000-9"}},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := provider.validateSizes(tt.pools)
+			if (err != nil) != tt.shouldError {
+				t.Errorf("validateSizes() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSpec) {
+				t.Errorf("Expected an invalid spec error, got %v", err)
+			}
+		})
+	}
+}
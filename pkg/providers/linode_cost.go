@@ -0,0 +1,114 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/linode/linodego"
+)
+
+// EstimateCost prices the pools of spec from the Linode type catalog
+func (l *LinodeProvider) EstimateCost(spec ClusterSpec) (CostEstimate, error) {
+	if err := l.validateSizes(spec.Pools); err != nil {
+		return CostEstimate{}, err
+	}
+	return estimateCost(spec.Name, spec.Pools, l.typePrice)
+}
+
+// ClusterCost prices the running instances of the named cluster, grouped by
+// pool and type
+func (l *LinodeProvider) ClusterCost(name string) (CostEstimate, error) {
+	instances, err := l.clusterInstances(name)
+	if err != nil {
+		return CostEstimate{}, err
+	}
+	return estimateCost(name, instancePools(instances), l.typePrice)
+}
+
+// instancePools counts instances by pool and type, in order of appearance
+func instancePools(instances []linodego.Instance) []NodePool {
+	var pools []NodePool
+	index := map[[2]string]int{}
+	for _, instance := range instances {
+		key := [2]string{instancePool(instance), instance.Type}
+		i, ok := index[key]
+		if !ok {
+			i = len(pools)
+			index[key] = i
+			pools = append(pools, NodePool{Name: key[0], Role: tagValue(instance.Tags, "role:"), Size: key[1]})
+		}
+		pools[i].Count++
+	}
+	return pools
+}
+
+// typePrice returns the price of a Linode type in the provider's region
+func (l *LinodeProvider) typePrice(size string) (Price, error) {
+	types, err := l.instanceTypes()
+	if err != nil {
+		return Price{}, err
+	}
+	for _, linodeType := range types {
+		if linodeType.ID == size {
+			return linodeTypePrice(linodeType, l.config.Region), nil
+		}
+	}
+	return Price{}, fmt.Errorf("%w: no price for Linode type %s", ErrNotFound, size)
+}
+
+// linodeTypePrice returns the price of a type in a region, which overrides
+// the base price in some regions
+func linodeTypePrice(linodeType linodego.LinodeType, region string) Price {
+	for _, regionPrice := range linodeType.RegionPrices {
+		if regionPrice.ID == region {
+			return Price{Hourly: dollars(regionPrice.Hourly), Monthly: dollars(regionPrice.Monthly)}
+		}
+	}
+	if linodeType.Price == nil {
+		return Price{}
+	}
+	return Price{Hourly: dollars(linodeType.Price.Hourly), Monthly: dollars(linodeType.Price.Monthly)}
+}
+
+// dollars converts a catalog price to float64 without float32 rounding noise
+func dollars(price float32) float64 {
+	value, _ := strconv.ParseFloat(strconv.FormatFloat(float64(price), 'f', -1, 32), 64)
+	return value
+}
+
+// CheaperSizes returns the Linode types of the same class as size that cost
+// less in the provider's region, most expensive first
+func (l *LinodeProvider) CheaperSizes(size string) ([]string, error) {
+	types, err := l.instanceTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	var current *linodego.LinodeType
+	for i := range types {
+		if types[i].ID == size {
+			current = &types[i]
+		}
+	}
+	if current == nil {
+		return nil, fmt.Errorf("%w: unknown Linode type %s", ErrInvalidSpec, size)
+	}
+	price := linodeTypePrice(*current, l.config.Region).Monthly
+
+	var cheaper []linodego.LinodeType
+	for _, linodeType := range types {
+		if linodeType.Class == current.Class && linodeTypePrice(linodeType, l.config.Region).Monthly < price {
+			cheaper = append(cheaper, linodeType)
+		}
+	}
+	sort.SliceStable(cheaper, func(i, j int) bool {
+		return linodeTypePrice(cheaper[i], l.config.Region).Monthly > linodeTypePrice(cheaper[j], l.config.Region).Monthly
+	})
+
+	sizes := make([]string, 0, len(cheaper))
+	for _, linodeType := range cheaper {
+		sizes = append(sizes, linodeType.ID)
+	}
+	return sizes, nil
+}
@@ -0,0 +1,139 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestLinodeTypePrice(t *testing.T) {
+	linodeType := linodego.LinodeType{
+		ID:           "g6-standard-2",
+		Price:        &linodego.LinodePrice{Hourly: 0.036, Monthly: 24},
+		RegionPrices: []linodego.LinodeRegionPrice{{ID: "br-gru", Hourly: 0.05, Monthly: 33.6}},
+	}
+
+	tests := []struct {
+		name       string
+		linodeType linodego.LinodeType
+		region     string
+		want       Price
+	}{
+		{
+			name:       "base price",
+			linodeType: linodeType,
+			region:     "us-east",
+			want:       Price{Hourly: 0.036, Monthly: 24},
+		},
+		{
+			name:       "region price",
+			linodeType: linodeType,
+			region:     "br-gru",
+			want:       Price{Hourly: 0.05, Monthly: 33.6},
+		},
+		{
+			name:       "no price",
+			linodeType: linodego.LinodeType{ID: "g6-standard-2"},
+			region:     "us-east",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := linodeTypePrice(tt.linodeType, tt.region); got != tt.want {
+				t.Errorf("linodeTypePrice() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinodeCost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var response any
+		switch r.URL.Path {
+		case "/v4/linode/types":
+			response = linodego.LinodeTypesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 4},
+				Data: []linodego.LinodeType{
+					{ID: "g6-standard-2", Class: linodego.ClassStandard, Price: &linodego.LinodePrice{Hourly: 0.036, Monthly: 24}},
+					{ID: "g6-standard-4", Class: linodego.ClassStandard, Price: &linodego.LinodePrice{Hourly: 0.072, Monthly: 48}},
+					{ID: "g6-nanode-1", Class: linodego.ClassNanode, Price: &linodego.LinodePrice{Hourly: 0.0075, Monthly: 5}},
+					{ID: "g6-standard-1", Class: linodego.ClassStandard, Price: &linodego.LinodePrice{Hourly: 0.018, Monthly: 12}},
+				},
+			}
+		case "/v4/linode/instances":
+			instances := []linodego.Instance{
+				nodeBalancerInstance(1, "test-controlplane-0", RoleControlPlane, "192.168.128.1"),
+				nodeBalancerInstance(2, "test-worker-0", RoleWorker, "192.168.128.2"),
+				nodeBalancerInstance(3, "test-worker-1", RoleWorker, "192.168.128.3"),
+			}
+			instances[0].Type = "g6-standard-2"
+			instances[1].Type = "g6-standard-4"
+			instances[2].Type = "g6-standard-2"
+			response = linodego.InstancesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: len(instances)},
+				Data:        instances,
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+	provider := testProvider(server, NewRetrier())
+
+	estimate, err := provider.EstimateCost(ClusterSpec{Name: "test", Pools: DefaultNodePools(4, "g6-standard-2", 1)})
+	if err != nil {
+		t.Fatalf("EstimateCost() error = %v", err)
+	}
+	if want := (Price{Hourly: 0.144, Monthly: 96}); !closePrice(estimate.Total, want) {
+		t.Errorf("EstimateCost() total = %+v, want %+v", estimate.Total, want)
+	}
+	if len(estimate.Pools) != 2 || estimate.Pools[1].Count != 3 || !closePrice(estimate.Pools[1].Total, Price{Hourly: 0.108, Monthly: 72}) {
+		t.Errorf("EstimateCost() pools = %+v", estimate.Pools)
+	}
+
+	_, err = provider.EstimateCost(ClusterSpec{Name: "test", Pools: DefaultNodePools(1, "g6-unknown", 1)})
+	if !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("EstimateCost() with an unknown size error = %v, want ErrInvalidSpec", err)
+	}
+
+	cost, err := provider.ClusterCost("test")
+	if err != nil {
+		t.Fatalf("ClusterCost() error = %v", err)
+	}
+	var pools []string
+	for _, pool := range cost.Pools {
+		pools = append(pools, pool.Pool+"/"+pool.Size)
+	}
+	if want := []string{"controlplane/g6-standard-2", "worker/g6-standard-4", "worker/g6-standard-2"}; !reflect.DeepEqual(pools, want) {
+		t.Errorf("ClusterCost() pools = %v, want %v", pools, want)
+	}
+	if want := (Price{Hourly: 0.144, Monthly: 96}); !closePrice(cost.Total, want) {
+		t.Errorf("ClusterCost() total = %+v, want %+v", cost.Total, want)
+	}
+
+	sizes, err := provider.CheaperSizes("g6-standard-4")
+	if err != nil {
+		t.Fatalf("CheaperSizes() error = %v", err)
+	}
+	if want := []string{"g6-standard-2", "g6-standard-1"}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("CheaperSizes() = %v, want %v", sizes, want)
+	}
+}
+
+// closePrice compares prices up to float rounding
+func closePrice(a, b Price) bool {
+	return math.Abs(a.Hourly-b.Hourly) < 1e-9 && math.Abs(a.Monthly-b.Monthly) < 1e-9
+}
@@ -0,0 +1,236 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/linode/linodego"
+)
+
+// firewallLabel names the cluster firewall within Linode's 32 character limit
+func firewallLabel(cluster string) string {
+	return truncateLabel("talos-"+cluster, 32)
+}
+
+// findFirewall returns the cluster firewall, or nil when there is none
+func (l *LinodeProvider) findFirewall(name string) (*linodego.Firewall, error) {
+	var firewalls []linodego.Firewall
+	err := l.call("list firewalls", func() (err error) {
+		firewalls, err = l.client.ListFirewalls(l.context, linodego.NewListOptions(0, linodeFilter("label", firewallLabel(name))))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range firewalls {
+		if firewalls[i].Label == firewallLabel(name) {
+			return &firewalls[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ensureFirewall returns the ID of the cluster firewall, creating it when
+// missing, or 0 when the spec disables it
+func (l *LinodeProvider) ensureFirewall(spec ClusterSpec) (int, error) {
+	if spec.Firewall.Disabled {
+		return 0, nil
+	}
+
+	firewall, err := l.findFirewall(spec.Name)
+	if err != nil {
+		return 0, err
+	}
+	if firewall != nil {
+		return firewall.ID, nil
+	}
+
+	opts := linodego.FirewallCreateOptions{
+		Label: firewallLabel(spec.Name),
+		Rules: firewallRules(spec, nil),
+		Tags:  []string{"talos-autoextender", clusterTag(spec.Name)},
+	}
+	err = l.callOnce("create firewall "+opts.Label, func() (err error) {
+		firewall, err = l.client.CreateFirewall(l.context, opts)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return firewall.ID, nil
+}
+
+// syncFirewall attaches the firewall to every cluster node and lets the nodes
+// reach each other
+func (l *LinodeProvider) syncFirewall(spec ClusterSpec, firewallID int) error {
+	if firewallID == 0 {
+		return nil
+	}
+
+	instances, err := l.clusterInstances(spec.Name)
+	if err != nil {
+		return err
+	}
+
+	var devices []linodego.FirewallDevice
+	err = l.call("list firewall devices", func() (err error) {
+		devices, err = l.client.ListFirewallDevices(l.context, firewallID, linodego.NewListOptions(0, ""))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	attached := map[int]bool{}
+	for _, device := range devices {
+		if device.Entity.Type == linodego.FirewallDeviceLinode {
+			attached[device.Entity.ID] = true
+		}
+	}
+
+	var addresses []string
+	if spec.VPC.Enabled {
+		addresses = append(addresses, spec.VPC.Subnet)
+	}
+	for _, instance := range instances {
+		addresses = append(addresses, instancePublicIPs(instance)...)
+		addresses = append(addresses, instancePrivateIPs(instance)...)
+
+		if attached[instance.ID] {
+			continue
+		}
+		err := l.callOnce("attach firewall to "+instance.Label, func() error {
+			_, err := l.client.CreateFirewallDevice(l.context, firewallID, linodego.FirewallDeviceCreateOptions{
+				ID:   instance.ID,
+				Type: linodego.FirewallDeviceLinode,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return l.call("update firewall rules", func() error {
+		_, err := l.client.UpdateFirewallRules(l.context, firewallID, firewallRules(spec, addresses))
+		return err
+	})
+}
+
+// deleteFirewall deletes the cluster firewall if there is one
+func (l *LinodeProvider) deleteFirewall(name string) error {
+	firewall, err := l.findFirewall(name)
+	if err != nil || firewall == nil {
+		return err
+	}
+
+	err = l.call("delete firewall "+firewall.Label, func() error {
+		return l.client.DeleteFirewall(l.context, firewall.ID)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// firewallRules drops inbound traffic except for the Talos and Kubernetes
+// APIs, WireGuard and ICMP from the admin CIDRs, the ingress ports from the ingress
+// CIDRs, the load balanced ports from NodeBalancers and any traffic between
+// the node addresses and networks
+func firewallRules(cluster ClusterSpec, nodeAddresses []string) linodego.FirewallRuleSet {
+	spec := cluster.Firewall
+	admin := firewallAddresses(spec.AdminCIDRs)
+	rules := []linodego.FirewallRule{
+		firewallRule("talos-api", linodego.TCP, fmt.Sprintf("%d-%d", TalosAPIPort, TrustdPort), admin),
+		firewallRule("kubernetes-api", linodego.TCP, strconv.Itoa(KubernetesAPIPort), admin),
+		firewallRule("wireguard", linodego.UDP, strconv.Itoa(WireGuardPort), admin),
+		// Path MTU discovery probes with ICMP echo requests
+		firewallRule("icmp", linodego.ICMP, "", admin),
+	}
+
+	ports := spec.IngressPorts
+	if ports == nil {
+		ports = DefaultIngressPorts
+	}
+	if len(ports) > 0 {
+		sorted := append([]int(nil), ports...)
+		sort.Ints(sorted)
+		var list []string
+		for _, port := range sorted {
+			list = append(list, strconv.Itoa(port))
+		}
+		rules = append(rules, firewallRule("ingress", linodego.TCP, strings.Join(list, ","), firewallAddresses(spec.IngressCIDRs)))
+	}
+
+	if cluster.LoadBalancer {
+		var list []string
+		for _, port := range loadBalancerPorts {
+			list = append(list, strconv.Itoa(port))
+		}
+		rules = append(rules, firewallRule("nodebalancer", linodego.TCP, strings.Join(list, ","), firewallAddresses([]string{nodeBalancerNetwork})))
+	}
+
+	if len(nodeAddresses) > 0 {
+		var hosts []string
+		for _, address := range nodeAddresses {
+			if prefix, err := netip.ParsePrefix(address); err == nil {
+				hosts = append(hosts, prefix.String())
+			} else if ip, err := netip.ParseAddr(address); err == nil {
+				hosts = append(hosts, netip.PrefixFrom(ip, ip.BitLen()).String())
+			}
+		}
+		nodes := firewallAddresses(hosts)
+		for _, protocol := range []linodego.NetworkProtocol{linodego.TCP, linodego.UDP, linodego.ICMP} {
+			rules = append(rules, firewallRule("cluster-"+strings.ToLower(string(protocol)), protocol, "", nodes))
+		}
+	}
+
+	return linodego.FirewallRuleSet{
+		Inbound:        rules,
+		InboundPolicy:  "DROP",
+		OutboundPolicy: "ACCEPT",
+	}
+}
+
+func firewallRule(label string, protocol linodego.NetworkProtocol, ports string, addresses linodego.NetworkAddresses) linodego.FirewallRule {
+	return linodego.FirewallRule{
+		Action:    "ACCEPT",
+		Label:     label,
+		Protocol:  protocol,
+		Ports:     ports,
+		Addresses: addresses,
+	}
+}
+
+// firewallAddresses splits CIDRs by address family; no CIDRs allow any address
+func firewallAddresses(cidrs []string) linodego.NetworkAddresses {
+	if len(cidrs) == 0 {
+		cidrs = []string{"0.0.0.0/0", "::/0"}
+	}
+
+	var ipv4, ipv6 []string
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			continue
+		}
+		if prefix.Addr().Is4() {
+			ipv4 = append(ipv4, cidr)
+		} else {
+			ipv6 = append(ipv6, cidr)
+		}
+	}
+
+	var addresses linodego.NetworkAddresses
+	if len(ipv4) > 0 {
+		addresses.IPv4 = &ipv4
+	}
+	if len(ipv6) > 0 {
+		addresses.IPv6 = &ipv6
+	}
+	return addresses
+}
@@ -0,0 +1,181 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestFirewallRules(t *testing.T) {
+	rules := firewallRules(ClusterSpec{Firewall: FirewallSpec{
+		AdminCIDRs:   []string{"203.0.113.0/24", "2001:db8::/32"},
+		IngressPorts: []int{8443, 443},
+	}}, []string{"198.51.100.7", "192.168.128.7", "2600:3c00::1"})
+
+	if rules.InboundPolicy != "DROP" || rules.OutboundPolicy != "ACCEPT" {
+		t.Errorf("Expected inbound traffic to be dropped and outbound accepted, got %s and %s", rules.InboundPolicy, rules.OutboundPolicy)
+	}
+
+	byLabel := map[string]linodego.FirewallRule{}
+	for _, rule := range rules.Inbound {
+		byLabel[rule.Label] = rule
+	}
+
+	talosAPI := byLabel["talos-api"]
+	if talosAPI.Ports != "50000-50001" || talosAPI.Protocol != linodego.TCP {
+		t.Errorf("Expected the Talos API rule to open 50000-50001/tcp, got %+v", talosAPI)
+	}
+	if talosAPI.Addresses.IPv4 == nil || (*talosAPI.Addresses.IPv4)[0] != "203.0.113.0/24" {
+		t.Errorf("Expected the Talos API to be restricted to the admin CIDRs, got %+v", talosAPI.Addresses)
+	}
+	if talosAPI.Addresses.IPv6 == nil || (*talosAPI.Addresses.IPv6)[0] != "2001:db8::/32" {
+		t.Errorf("Expected the IPv6 admin CIDR, got %+v", talosAPI.Addresses)
+	}
+	if wireguard := byLabel["wireguard"]; wireguard.Ports != "51820" || wireguard.Protocol != linodego.UDP {
+		t.Errorf("Expected the WireGuard rule to open 51820/udp, got %+v", wireguard)
+	}
+
+	ingress := byLabel["ingress"]
+	if ingress.Ports != "443,8443" {
+		t.Errorf("Expected the ingress ports 443,8443, got %q", ingress.Ports)
+	}
+	if ingress.Addresses.IPv4 == nil || (*ingress.Addresses.IPv4)[0] != "0.0.0.0/0" {
+		t.Errorf("Expected ingress from any address, got %+v", ingress.Addresses)
+	}
+
+	cluster := byLabel["cluster-tcp"]
+	if cluster.Addresses.IPv4 == nil || len(*cluster.Addresses.IPv4) != 2 || (*cluster.Addresses.IPv4)[0] != "198.51.100.7/32" {
+		t.Errorf("Expected the node IPv4 addresses, got %+v", cluster.Addresses)
+	}
+	if cluster.Addresses.IPv6 == nil || (*cluster.Addresses.IPv6)[0] != "2600:3c00::1/128" {
+		t.Errorf("Expected the node IPv6 address, got %+v", cluster.Addresses)
+	}
+	if _, ok := byLabel["cluster-icmp"]; !ok {
+		t.Error("Expected ICMP between the nodes to be allowed")
+	}
+
+	// network mtu and connect --discover-mtu probe the nodes with ICMP echo
+	// requests from the admin machine
+	if !firewallAccepts(rules, linodego.ICMP, "203.0.113.9") || !firewallAccepts(rules, linodego.ICMP, "2001:db8::9") {
+		t.Error("Expected ICMP MTU probes from the admin CIDRs to be accepted")
+	}
+	if firewallAccepts(rules, linodego.ICMP, "198.51.100.200") {
+		t.Error("Expected ICMP from outside the admin CIDRs and nodes to be dropped")
+	}
+	if !firewallAccepts(firewallRules(ClusterSpec{}, nil), linodego.ICMP, "198.51.100.200") {
+		t.Error("Expected ICMP MTU probes from any address without admin CIDRs")
+	}
+
+	if rules := firewallRules(ClusterSpec{Firewall: FirewallSpec{IngressPorts: []int{}}}, nil); len(rules.Inbound) != 4 {
+		t.Errorf("Expected only the admin rules without ingress ports and nodes, got %d rules", len(rules.Inbound))
+	}
+	if ingress := firewallRules(ClusterSpec{}, nil).Inbound[4]; ingress.Ports != "80,443" {
+		t.Errorf("Expected the default ingress ports, got %q", ingress.Ports)
+	}
+
+	balanced := firewallRules(ClusterSpec{LoadBalancer: true}, nil).Inbound[5]
+	if balanced.Ports != "6443,80,443" || balanced.Addresses.IPv4 == nil || (*balanced.Addresses.IPv4)[0] != nodeBalancerNetwork {
+		t.Errorf("Expected the load balanced ports to be opened to NodeBalancers, got %+v", balanced)
+	}
+}
+
+// firewallAccepts reports whether an inbound rule accepts protocol from address
+func firewallAccepts(rules linodego.FirewallRuleSet, protocol linodego.NetworkProtocol, address string) bool {
+	addr := netip.MustParseAddr(address)
+	for _, rule := range rules.Inbound {
+		if rule.Action != "ACCEPT" || rule.Protocol != protocol {
+			continue
+		}
+		var prefixes []string
+		if rule.Addresses.IPv4 != nil {
+			prefixes = append(prefixes, *rule.Addresses.IPv4...)
+		}
+		if rule.Addresses.IPv6 != nil {
+			prefixes = append(prefixes, *rule.Addresses.IPv6...)
+		}
+		for _, prefix := range prefixes {
+			if netip.MustParsePrefix(prefix).Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestSyncFirewall(t *testing.T) {
+	var mu sync.Mutex
+	var attached []int
+	var rules linodego.FirewallRuleSet
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+
+		var response any
+		switch {
+		case r.URL.Path == "/v4/linode/instances":
+			response = linodego.InstancesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 2},
+				Data: []linodego.Instance{
+					{ID: 1, Label: "test-controlplane-0", Tags: []string{"talos-autoextender", "cluster:test"}, IPv6: "2600:3c00::1/128"},
+					{ID: 2, Label: "test-worker-0", Tags: []string{"talos-autoextender", "cluster:test"}, IPv6: "2600:3c00::2/128"},
+				},
+			}
+		case r.URL.Path == "/v4/networking/firewalls/7/devices" && r.Method == http.MethodGet:
+			response = linodego.FirewallDevicesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 1},
+				Data:        []linodego.FirewallDevice{{ID: 70, Entity: linodego.FirewallDeviceEntity{ID: 1, Type: linodego.FirewallDeviceLinode}}},
+			}
+		case r.URL.Path == "/v4/networking/firewalls/7/devices" && r.Method == http.MethodPost:
+			var opts linodego.FirewallDeviceCreateOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			attached = append(attached, opts.ID)
+			response = linodego.FirewallDevice{ID: 71, Entity: linodego.FirewallDeviceEntity{ID: opts.ID, Type: opts.Type}}
+		case r.URL.Path == "/v4/networking/firewalls/7/rules" && r.Method == http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			response = rules
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := testProvider(server, NewRetrier())
+	if err := provider.syncFirewall(ClusterSpec{Name: "test"}, 7); err != nil {
+		t.Fatalf("syncFirewall() error = %v", err)
+	}
+
+	if len(attached) != 1 || attached[0] != 2 {
+		t.Errorf("Expected only the unattached instance to be attached, got %v", attached)
+	}
+
+	var nodes *linodego.NetworkAddresses
+	for _, rule := range rules.Inbound {
+		if rule.Label == "cluster-tcp" {
+			nodes = &rule.Addresses
+		}
+	}
+	if nodes == nil || nodes.IPv6 == nil || len(*nodes.IPv6) != 2 {
+		t.Errorf("Expected the rules to allow traffic between both nodes, got %+v", rules.Inbound)
+	}
+
+	if err := provider.syncFirewall(ClusterSpec{Name: "test"}, 0); err != nil {
+		t.Errorf("Expected a disabled firewall to be skipped, got %v", err)
+	}
+}
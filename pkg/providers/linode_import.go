@@ -0,0 +1,96 @@
+package providers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/linode/linodego"
+)
+
+// DiscoverNodes lists the instances in the provider's region carrying tag, or
+// when tag is empty, whose label starts with labelPrefix. The nodes have no
+// role until the caller inspects them.
+func (l *LinodeProvider) DiscoverNodes(tag, labelPrefix string) ([]NodeStatus, error) {
+	if tag == "" && labelPrefix == "" {
+		return nil, fmt.Errorf("%w: a tag or label prefix is required to discover nodes", ErrInvalidSpec)
+	}
+
+	opts := linodego.NewListOptions(0, "")
+	if tag != "" {
+		opts.Filter = linodeFilter("tags", tag)
+	}
+
+	var instances []linodego.Instance
+	err := l.call("list instances", func() (err error) {
+		instances, err = l.client.ListInstances(l.context, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []NodeStatus
+	for _, instance := range instances {
+		if instance.Region != l.config.Region || !strings.HasPrefix(instance.Label, labelPrefix) {
+			continue
+		}
+		node := instanceStatus(instance)
+		node.Role = ""
+		node.Pool = ""
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// AdoptNodes tags the nodes with the cluster, role and pool tags the tool
+// looks instances up by, keeping their other tags. Instances managed as part
+// of another cluster are refused before any is changed.
+func (l *LinodeProvider) AdoptNodes(name string, nodes []NodeStatus) error {
+	instances := make([]*linodego.Instance, len(nodes))
+	for i, node := range nodes {
+		if node.Role != RoleControlPlane && node.Role != RoleWorker {
+			return fmt.Errorf("%w: node %s has no role", ErrInvalidSpec, node.Label)
+		}
+		id, err := strconv.Atoi(node.ID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid instance ID %q", ErrInvalidSpec, node.ID)
+		}
+
+		err = l.call(fmt.Sprintf("get instance %d", id), func() (err error) {
+			instances[i], err = l.client.GetInstance(l.context, id)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if cluster := tagValue(instances[i].Tags, "cluster:"); hasTag(instances[i].Tags, "talos-autoextender") && cluster != "" && cluster != name {
+			return fmt.Errorf("%w: instance %s belongs to cluster %s", ErrInvalidSpec, node.Label, cluster)
+		}
+	}
+
+	for i, instance := range instances {
+		tags := adoptedTags(instance.Tags, name, nodes[i].Role, nodes[i].Pool)
+		err := l.call(fmt.Sprintf("update instance %d", instance.ID), func() error {
+			_, err := l.client.UpdateInstance(l.context, instance.ID, linodego.InstanceUpdateOptions{Tags: &tags})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adoptedTags replaces the tool's tags among tags with those of the cluster,
+// role and pool
+func adoptedTags(tags []string, cluster, role, pool string) []string {
+	adopted := []string{"talos-autoextender", clusterTag(cluster), "role:" + role, "pool:" + pool}
+	for _, tag := range tags {
+		if tag == "talos-autoextender" || strings.HasPrefix(tag, "cluster:") || strings.HasPrefix(tag, "role:") || strings.HasPrefix(tag, "pool:") {
+			continue
+		}
+		adopted = append(adopted, tag)
+	}
+	return adopted
+}
@@ -0,0 +1,106 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestLinodeImport(t *testing.T) {
+	var mu sync.Mutex
+	updated := map[string][]string{}
+	var filter string
+
+	instances := map[int]linodego.Instance{
+		1: {ID: 1, Label: "legacy-cp-0", Region: "us-east", Type: "g6-standard-2", Status: linodego.InstanceRunning, Tags: []string{"team:infra", "legacy"}},
+		2: {ID: 2, Label: "legacy-worker-0", Region: "us-east", Type: "g6-standard-4", Status: linodego.InstanceRunning, Tags: []string{"legacy"}},
+		3: {ID: 3, Label: "legacy-worker-1", Region: "eu-west", Type: "g6-standard-4", Status: linodego.InstanceRunning, Tags: []string{"legacy"}},
+		4: {ID: 4, Label: "other-worker-0", Region: "us-east", Type: "g6-standard-4", Tags: []string{"talos-autoextender", "cluster:other", "role:worker"}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+
+		var response any
+		switch {
+		case r.URL.Path == "/v4/linode/instances":
+			filter = r.Header.Get("X-Filter")
+			response = linodego.InstancesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 4},
+				Data:        []linodego.Instance{instances[1], instances[2], instances[3], instances[4]},
+			}
+		case strings.HasPrefix(r.URL.Path, "/v4/linode/instances/"):
+			id := strings.TrimPrefix(r.URL.Path, "/v4/linode/instances/")
+			instance := instances[map[string]int{"1": 1, "2": 2, "3": 3, "4": 4}[id]]
+			if r.Method == http.MethodPut {
+				var opts linodego.InstanceUpdateOptions
+				json.NewDecoder(r.Body).Decode(&opts)
+				updated[id] = *opts.Tags
+				instance.Tags = *opts.Tags
+			}
+			response = instance
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	provider := testProvider(server, NewRetrier())
+
+	nodes, err := provider.DiscoverNodes("legacy", "")
+	if err != nil {
+		t.Fatalf("DiscoverNodes() error = %v", err)
+	}
+	if !strings.Contains(filter, `"tags":"legacy"`) {
+		t.Errorf("Expected instances to be filtered by tag, got filter %q", filter)
+	}
+	if len(nodes) != 3 || nodes[0].Role != "" || nodes[0].Pool != "" {
+		t.Fatalf("Expected the 3 us-east instances without a role, got %v", nodes)
+	}
+
+	nodes, err = provider.DiscoverNodes("", "legacy-")
+	if err != nil {
+		t.Fatalf("DiscoverNodes() error = %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].Label != "legacy-cp-0" || nodes[1].Label != "legacy-worker-0" {
+		t.Fatalf("Expected the us-east instances labelled legacy-, got %v", nodes)
+	}
+
+	nodes[0].Role = RoleControlPlane
+	nodes[1].Role = RoleWorker
+	InferPools(nodes)
+	if err := provider.AdoptNodes("legacy", nodes); err != nil {
+		t.Fatalf("AdoptNodes() error = %v", err)
+	}
+	expected := map[string][]string{
+		"1": {"talos-autoextender", "cluster:legacy", "role:controlplane", "pool:controlplane", "team:infra", "legacy"},
+		"2": {"talos-autoextender", "cluster:legacy", "role:worker", "pool:worker", "legacy"},
+	}
+	if !reflect.DeepEqual(updated, expected) {
+		t.Errorf("Expected adopted tags %v, got %v", expected, updated)
+	}
+
+	updated = map[string][]string{}
+	claimed := instanceStatus(instances[4])
+	if err := provider.AdoptNodes("legacy", append(nodes, claimed)); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("AdoptNodes() error = %v, expected ErrInvalidSpec for another cluster's instance", err)
+	}
+	if len(updated) != 0 {
+		t.Errorf("Expected no instance to be updated, got %v", updated)
+	}
+
+	if _, err := provider.DiscoverNodes("", ""); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("DiscoverNodes() error = %v, expected ErrInvalidSpec", err)
+	}
+}
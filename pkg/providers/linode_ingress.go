@@ -0,0 +1,160 @@
+package providers
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/linode/linodego"
+)
+
+// ingressIPTag records the shared ingress address on every ingress node, so
+// the address is known whichever node holds it
+const ingressIPTag = "ingress-ip:"
+
+// roleInstances returns the instances of a role
+func roleInstances(instances []linodego.Instance, role string) []linodego.Instance {
+	var matching []linodego.Instance
+	for _, instance := range instances {
+		if tagValue(instance.Tags, "role:") == role {
+			matching = append(matching, instance)
+		}
+	}
+	return matching
+}
+
+// ingressInstances returns the nodes serving ingress: the workers, or the
+// control plane nodes of clusters without workers
+func ingressInstances(instances []linodego.Instance) []linodego.Instance {
+	if workers := roleInstances(instances, RoleWorker); len(workers) > 0 {
+		return workers
+	}
+	return roleInstances(instances, RoleControlPlane)
+}
+
+// ingressIP returns the shared ingress address recorded on the instances and
+// the instance holding it, which is nil when no instance does
+func ingressIP(instances []linodego.Instance) (string, *linodego.Instance) {
+	address := ""
+	for _, instance := range instances {
+		if address = tagValue(instance.Tags, ingressIPTag); address != "" {
+			break
+		}
+	}
+	if address == "" {
+		return "", nil
+	}
+
+	for i := range instances {
+		for _, ip := range instances[i].IPv4 {
+			if ip != nil && ip.Equal(net.ParseIP(address)) {
+				return address, &instances[i]
+			}
+		}
+	}
+	return address, nil
+}
+
+// ensureIngressIP allocates the shared ingress address on an ingress node
+// when it is missing, records it on every ingress node and shares it with the
+// nodes not holding it
+func (l *LinodeProvider) ensureIngressIP(spec ClusterSpec) error {
+	if !spec.IngressIP {
+		return nil
+	}
+
+	instances, err := l.clusterInstances(spec.Name)
+	if err != nil {
+		return err
+	}
+	candidates := ingressInstances(instances)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	address, holder := ingressIP(instances)
+	if address == "" {
+		var ip *linodego.InstanceIP
+		err := l.callOnce("allocate ingress IP on "+candidates[0].Label, func() (err error) {
+			ip, err = l.client.AddInstanceIPAddress(l.context, candidates[0].ID, true)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		address, holder = ip.Address, &candidates[0]
+	}
+
+	for _, instance := range candidates {
+		if tagValue(instance.Tags, ingressIPTag) != address {
+			tags := append(append([]string(nil), instance.Tags...), ingressIPTag+address)
+			err := l.call("tag "+instance.Label, func() error {
+				_, err := l.client.UpdateInstance(l.context, instance.ID, linodego.InstanceUpdateOptions{Tags: &tags})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return l.shareIngressIP(address, holder, candidates)
+}
+
+// shareIngressIP lets every ingress node but the holder bring up the address
+func (l *LinodeProvider) shareIngressIP(address string, holder *linodego.Instance, candidates []linodego.Instance) error {
+	for _, instance := range candidates {
+		ips := []string{address}
+		if holder != nil && instance.ID == holder.ID {
+			ips = []string{}
+		}
+		err := l.call("share ingress IP with "+instance.Label, func() error {
+			return l.client.ShareIPAddresses(l.context, linodego.IPAddressesShareOptions{IPs: ips, LinodeID: instance.ID})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FailoverIngress moves the shared ingress address of the named cluster to a
+// running, healthy ingress node in the same data center when the node holding
+// it is not. It returns the label of the node holding the address.
+func (l *LinodeProvider) FailoverIngress(name string, healthy func(NodeStatus) bool) (string, error) {
+	instances, err := l.clusterInstances(name)
+	if err != nil {
+		return "", err
+	}
+
+	address, holder := ingressIP(instances)
+	if address == "" {
+		return "", fmt.Errorf("%w: cluster %s has no shared ingress IP", ErrNotFound, name)
+	}
+	up := func(instance linodego.Instance) bool {
+		return instance.Status == linodego.InstanceRunning && healthy(instanceStatus(instance))
+	}
+	if holder != nil && up(*holder) {
+		return holder.Label, nil
+	}
+
+	candidates := ingressInstances(instances)
+	for i := range candidates {
+		target := &candidates[i]
+		if (holder != nil && target.ID == holder.ID) || !up(*target) {
+			continue
+		}
+
+		err := l.call("move ingress IP to "+target.Label, func() error {
+			return l.client.InstancesAssignIPs(l.context, linodego.LinodesAssignIPsOptions{
+				Region:      l.config.Region,
+				Assignments: []linodego.LinodeIPAssignment{{Address: address, LinodeID: target.ID}},
+			})
+		})
+		if err != nil {
+			return "", err
+		}
+		return target.Label, l.shareIngressIP(address, target, candidates)
+	}
+
+	return "", fmt.Errorf("no healthy ingress node to move %s to", address)
+}
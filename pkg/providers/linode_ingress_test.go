@@ -0,0 +1,193 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+// ingressInstance returns a running cluster instance recording the shared
+// ingress address, holding it when held is set
+func ingressInstance(id int, label, role string, held bool) linodego.Instance {
+	instance := nodeBalancerInstance(id, label, role, "192.168.128.1")
+	instance.Status = linodego.InstanceRunning
+	instance.Tags = append(instance.Tags, ingressIPTag+"203.0.113.99")
+	if held {
+		shared := net.ParseIP("203.0.113.99")
+		instance.IPv4 = append(instance.IPv4, &shared)
+	}
+	return instance
+}
+
+func TestIngressIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances []linodego.Instance
+		address   string
+		holder    string
+	}{
+		{
+			name: "held by a worker",
+			instances: []linodego.Instance{
+				ingressInstance(1, "test-controlplane-0", RoleControlPlane, false),
+				ingressInstance(2, "test-worker-0", RoleWorker, false),
+				ingressInstance(3, "test-worker-1", RoleWorker, true),
+			},
+			address: "203.0.113.99",
+			holder:  "test-worker-1",
+		},
+		{
+			name: "not held",
+			instances: []linodego.Instance{
+				ingressInstance(2, "test-worker-0", RoleWorker, false),
+			},
+			address: "203.0.113.99",
+		},
+		{
+			name: "no ingress IP",
+			instances: []linodego.Instance{
+				nodeBalancerInstance(2, "test-worker-0", RoleWorker, "192.168.128.2"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, holder := ingressIP(tt.instances)
+			if address != tt.address {
+				t.Errorf("ingressIP() address = %q, want %q", address, tt.address)
+			}
+			label := ""
+			if holder != nil {
+				label = holder.Label
+			}
+			if label != tt.holder {
+				t.Errorf("ingressIP() holder = %q, want %q", label, tt.holder)
+			}
+		})
+	}
+}
+
+func TestFailoverIngress(t *testing.T) {
+	tests := []struct {
+		name        string
+		instances   []linodego.Instance
+		down        string
+		want        string
+		moved       bool
+		notFound    bool
+		shouldError bool
+	}{
+		{
+			name: "healthy holder",
+			instances: []linodego.Instance{
+				ingressInstance(2, "test-worker-0", RoleWorker, true),
+				ingressInstance(3, "test-worker-1", RoleWorker, false),
+			},
+			want: "test-worker-0",
+		},
+		{
+			name: "holder down",
+			instances: []linodego.Instance{
+				ingressInstance(2, "test-worker-0", RoleWorker, true),
+				ingressInstance(3, "test-worker-1", RoleWorker, false),
+			},
+			down:  "test-worker-0",
+			want:  "test-worker-1",
+			moved: true,
+		},
+		{
+			name: "no healthy node",
+			instances: []linodego.Instance{
+				ingressInstance(2, "test-worker-0", RoleWorker, true),
+			},
+			down:        "test-worker-0",
+			shouldError: true,
+		},
+		{
+			name: "no ingress IP",
+			instances: []linodego.Instance{
+				nodeBalancerInstance(2, "test-worker-0", RoleWorker, "192.168.128.2"),
+			},
+			notFound:    true,
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var assigned []linodego.LinodesAssignIPsOptions
+			shared := map[int][]string{}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				mu.Lock()
+				defer mu.Unlock()
+
+				var response any = map[string]any{}
+				switch {
+				case r.URL.Path == "/v4/linode/instances":
+					response = linodego.InstancesPagedResponse{
+						PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: len(tt.instances)},
+						Data:        tt.instances,
+					}
+				case r.Method == http.MethodPost && r.URL.Path == "/v4/networking/ips/assign":
+					var opts linodego.LinodesAssignIPsOptions
+					if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+					assigned = append(assigned, opts)
+				case r.Method == http.MethodPost && r.URL.Path == "/v4/networking/ips/share":
+					var opts linodego.IPAddressesShareOptions
+					if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+					shared[opts.LinodeID] = opts.IPs
+				default:
+					http.NotFound(w, r)
+					return
+				}
+				if err := json.NewEncoder(w).Encode(response); err != nil {
+					t.Errorf("Failed to encode response: %v", err)
+				}
+			}))
+			defer server.Close()
+
+			provider := testProvider(server, NewRetrier())
+			node, err := provider.FailoverIngress("test", func(node NodeStatus) bool {
+				return node.Label != tt.down
+			})
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("FailoverIngress() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if errors.Is(err, ErrNotFound) != tt.notFound {
+				t.Errorf("FailoverIngress() error = %v, notFound %v", err, tt.notFound)
+			}
+			if node != tt.want {
+				t.Errorf("FailoverIngress() = %q, want %q", node, tt.want)
+			}
+
+			if !tt.moved {
+				if len(assigned) != 0 {
+					t.Errorf("Expected the address to stay, got assignments %+v", assigned)
+				}
+				return
+			}
+			if len(assigned) != 1 || assigned[0].Assignments[0].Address != "203.0.113.99" || assigned[0].Assignments[0].LinodeID != 3 {
+				t.Errorf("Expected the address to move to instance 3, got %+v", assigned)
+			}
+			if len(shared[3]) != 0 || len(shared[2]) != 1 {
+				t.Errorf("Expected the old holder to share the address, got %v", shared)
+			}
+		})
+	}
+}
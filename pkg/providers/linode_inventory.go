@@ -0,0 +1,181 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+// List prices of the resources the type catalog does not price
+const (
+	linodeNodeBalancerMonthly = 10.0
+	linodeNodeBalancerHourly  = 0.015
+	// Custom images are billed per GB stored
+	linodeImageMonthlyPerGB = 0.10
+)
+
+// Resources lists the instances, NodeBalancers and firewalls tagged by the
+// tool and the uploaded Talos images
+func (l *LinodeProvider) Resources() ([]Resource, error) {
+	filter := linodeFilter("tags", "talos-autoextender")
+	var resources []Resource
+
+	var instances []linodego.Instance
+	err := l.call("list instances", func() (err error) {
+		instances, err = l.client.ListInstances(l.context, linodego.NewListOptions(0, filter))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	types, err := l.instanceTypes()
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		resource := Resource{
+			Kind:      ResourceInstance,
+			ID:        strconv.Itoa(instance.ID),
+			Label:     instance.Label,
+			Cluster:   tagValue(instance.Tags, "cluster:"),
+			CreatedAt: timeValue(instance.Created),
+		}
+		for _, linodeType := range types {
+			if linodeType.ID == instance.Type {
+				resource.Cost = linodeTypePrice(linodeType, instance.Region)
+			}
+		}
+		resources = append(resources, resource)
+	}
+
+	var nodeBalancers []linodego.NodeBalancer
+	err = l.call("list NodeBalancers", func() (err error) {
+		nodeBalancers, err = l.client.ListNodeBalancers(l.context, linodego.NewListOptions(0, filter))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, nodeBalancer := range nodeBalancers {
+		resource := Resource{
+			Kind:      ResourceLoadBalancer,
+			ID:        strconv.Itoa(nodeBalancer.ID),
+			Cluster:   tagValue(nodeBalancer.Tags, "cluster:"),
+			CreatedAt: timeValue(nodeBalancer.Created),
+			Cost:      Price{Hourly: linodeNodeBalancerHourly, Monthly: linodeNodeBalancerMonthly},
+		}
+		if nodeBalancer.Label != nil {
+			resource.Label = *nodeBalancer.Label
+		}
+		resources = append(resources, resource)
+	}
+
+	var firewalls []linodego.Firewall
+	err = l.call("list firewalls", func() (err error) {
+		firewalls, err = l.client.ListFirewalls(l.context, linodego.NewListOptions(0, filter))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, firewall := range firewalls {
+		resources = append(resources, Resource{
+			Kind:      ResourceFirewall,
+			ID:        strconv.Itoa(firewall.ID),
+			Label:     firewall.Label,
+			Cluster:   tagValue(firewall.Tags, "cluster:"),
+			CreatedAt: timeValue(firewall.Created),
+		})
+	}
+
+	// Images cannot be tagged; the Talos images uploaded from the Image
+	// Factory are known by their label and description
+	images, err := l.privateImages()
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		if !strings.HasPrefix(image.Label, "talos-") || !strings.Contains(image.Description, ", schematic ") {
+			continue
+		}
+		monthly := float64(image.Size) / 1024 * linodeImageMonthlyPerGB
+		resources = append(resources, Resource{
+			Kind:      ResourceImage,
+			ID:        image.ID,
+			Label:     image.Label,
+			CreatedAt: timeValue(image.Created),
+			Cost:      Price{Hourly: monthly / 730, Monthly: monthly},
+		})
+	}
+
+	return resources, nil
+}
+
+// Owned reports whether a resource belongs to one of the clusters: tagged
+// resources by their cluster tag, images when a cluster boots them
+func (l *LinodeProvider) Owned(resource Resource, clusters []ClusterSpec) bool {
+	for _, cluster := range clusters {
+		if resource.Kind != ResourceImage {
+			if resource.Cluster != "" && resource.Cluster == cluster.Name {
+				return true
+			}
+			continue
+		}
+		if resource.ID == cluster.Image {
+			return true
+		}
+		if cluster.SchematicID != "" && resource.Label == factoryImageLabel(cluster.SchematicID, cluster.TalosVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteResource deletes a resource listed by Resources; resources already
+// gone are not an error
+func (l *LinodeProvider) DeleteResource(resource Resource) error {
+	if resource.Kind == ResourceImage {
+		err := l.call("delete image "+resource.Label, func() error {
+			return l.client.DeleteImage(l.context, resource.ID)
+		})
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	id, err := strconv.Atoi(resource.ID)
+	if err != nil {
+		return fmt.Errorf("invalid Linode %s ID %q", resource.Kind, resource.ID)
+	}
+	switch resource.Kind {
+	case ResourceInstance:
+		return l.deleteInstance(linodego.Instance{ID: id, Label: resource.Label})
+	case ResourceLoadBalancer:
+		err = l.call("delete NodeBalancer "+resource.Label, func() error {
+			return l.client.DeleteNodeBalancer(l.context, id)
+		})
+	case ResourceFirewall:
+		err = l.call("delete firewall "+resource.Label, func() error {
+			return l.client.DeleteFirewall(l.context, id)
+		})
+	default:
+		return fmt.Errorf("unknown resource kind %s", resource.Kind)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// timeValue returns the time, or the zero time for nil
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -0,0 +1,111 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestLinodeOrphans(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+
+	label := "talos-orphan"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			w.Write([]byte("{}"))
+			return
+		}
+
+		var response any
+		switch r.URL.Path {
+		case "/v4/linode/instances":
+			kept := nodeBalancerInstance(1, "live-controlplane-0", RoleControlPlane, "192.168.128.1")
+			kept.Tags = []string{"talos-autoextender", "cluster:live", "role:controlplane"}
+			orphan := nodeBalancerInstance(2, "gone-worker-0", RoleWorker, "192.168.128.2")
+			orphan.Tags = []string{"talos-autoextender", "cluster:gone", "role:worker"}
+			orphan.Type = "g6-standard-2"
+			response = linodego.InstancesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 2},
+				Data:        []linodego.Instance{kept, orphan},
+			}
+		case "/v4/linode/types":
+			response = linodego.LinodeTypesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 1},
+				Data:        []linodego.LinodeType{{ID: "g6-standard-2", Price: &linodego.LinodePrice{Hourly: 0.036, Monthly: 24}}},
+			}
+		case "/v4/nodebalancers":
+			response = linodego.NodeBalancersPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 1},
+				Data:        []linodego.NodeBalancer{{ID: 9, Label: &label, Tags: []string{"talos-autoextender", "cluster:gone"}}},
+			}
+		case "/v4/networking/firewalls":
+			response = linodego.FirewallsPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 2},
+				Data: []linodego.Firewall{
+					{ID: 7, Label: "talos-live", Tags: []string{"talos-autoextender", "cluster:live"}},
+					{ID: 8, Label: "talos-gone", Tags: []string{"talos-autoextender", "cluster:gone"}},
+				},
+			}
+		case "/v4/images":
+			response = linodego.ImagesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 3},
+				Data: []linodego.Image{
+					{ID: "private/1", Label: factoryImageLabel("abcdef0123456789", "v1.7.0"), Description: "Talos v1.7.0, schematic abcdef0123456789", Size: 1024},
+					{ID: "private/2", Label: factoryImageLabel("abcdef0123456789", "v1.6.0"), Description: "Talos v1.6.0, schematic abcdef0123456789", Size: 2048},
+					{ID: "private/3", Label: "talos-manual", Description: "uploaded by hand"},
+				},
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+	provider := testProvider(server, NewRetrier())
+
+	resources, err := provider.Resources()
+	if err != nil {
+		t.Fatalf("Resources() error = %v", err)
+	}
+	if len(resources) != 7 {
+		t.Fatalf("Expected 7 resources, got %+v", resources)
+	}
+
+	clusters := []ClusterSpec{{Name: "live", SchematicID: "abcdef0123456789", TalosVersion: "v1.7.0"}}
+	orphans := Orphans(provider, resources, clusters)
+	var labels []string
+	for _, orphan := range orphans {
+		labels = append(labels, orphan.Kind+"/"+orphan.Label)
+	}
+	want := []string{"instance/gone-worker-0", "loadbalancer/talos-orphan", "firewall/talos-gone", "image/talos-1.6.0-abcdef012345"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("Orphans() = %v, want %v", labels, want)
+	}
+	if orphans[0].Cost.Monthly != 24 || orphans[1].Cost.Monthly != linodeNodeBalancerMonthly || orphans[3].Cost.Monthly != 0.2 {
+		t.Errorf("Expected orphans to be priced, got %+v", orphans)
+	}
+
+	for _, orphan := range orphans {
+		if err := provider.DeleteResource(orphan); err != nil {
+			t.Fatalf("DeleteResource(%s) error = %v", orphan.Label, err)
+		}
+	}
+	wantDeleted := []string{"/v4/linode/instances/2", "/v4/nodebalancers/9", "/v4/networking/firewalls/8", "/v4/images/private/2"}
+	if !reflect.DeepEqual(deleted, wantDeleted) {
+		t.Errorf("Deleted %v, want %v", deleted, wantDeleted)
+	}
+}
@@ -0,0 +1,231 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/linode/linodego"
+)
+
+// nodeBalancerNetwork is the range NodeBalancers reach their backends from
+const nodeBalancerNetwork = "192.168.255.0/24"
+
+// loadBalancerPorts are balanced by the cluster load balancer: the Kubernetes
+// API across control plane nodes, HTTP and HTTPS ingress across workers
+var loadBalancerPorts = []int{KubernetesAPIPort, 80, 443}
+
+// nodeBalancerLabel names the cluster NodeBalancer within Linode's 32
+// character limit
+func nodeBalancerLabel(cluster string) string {
+	return truncateLabel("talos-"+cluster, 32)
+}
+
+// findNodeBalancer returns the cluster NodeBalancer, or nil when there is none
+func (l *LinodeProvider) findNodeBalancer(name string) (*linodego.NodeBalancer, error) {
+	label := nodeBalancerLabel(name)
+	var nodeBalancers []linodego.NodeBalancer
+	err := l.call("list NodeBalancers", func() (err error) {
+		nodeBalancers, err = l.client.ListNodeBalancers(l.context, linodego.NewListOptions(0, linodeFilter("label", label)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range nodeBalancers {
+		if nodeBalancers[i].Label != nil && *nodeBalancers[i].Label == label {
+			return &nodeBalancers[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ensureNodeBalancer creates the cluster NodeBalancer behind the cluster
+// firewall when the spec asks for a load balancer and it is missing. It
+// returns nil without a load balancer.
+func (l *LinodeProvider) ensureNodeBalancer(spec ClusterSpec, firewallID int) (*linodego.NodeBalancer, error) {
+	if !spec.LoadBalancer {
+		return nil, nil
+	}
+
+	nodeBalancer, err := l.findNodeBalancer(spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if nodeBalancer != nil {
+		return nodeBalancer, nil
+	}
+
+	label := nodeBalancerLabel(spec.Name)
+	opts := linodego.NodeBalancerCreateOptions{
+		Label:      &label,
+		Region:     l.config.Region,
+		Tags:       []string{"talos-autoextender", clusterTag(spec.Name)},
+		FirewallID: firewallID,
+	}
+	for _, port := range loadBalancerPorts {
+		config := nodeBalancerConfig(port, nil)
+		opts.Configs = append(opts.Configs, &config)
+	}
+
+	err = l.callOnce("create NodeBalancer "+label, func() (err error) {
+		nodeBalancer, err = l.client.CreateNodeBalancer(l.context, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodeBalancer, nil
+}
+
+// syncNodeBalancer registers the private addresses of the cluster nodes as
+// backends of the NodeBalancer configs
+func (l *LinodeProvider) syncNodeBalancer(spec ClusterSpec, nodeBalancer *linodego.NodeBalancer) error {
+	if nodeBalancer == nil {
+		return nil
+	}
+
+	instances, err := l.clusterInstances(spec.Name)
+	if err != nil {
+		return err
+	}
+
+	var configs []linodego.NodeBalancerConfig
+	err = l.call("list NodeBalancer configs", func() (err error) {
+		configs, err = l.client.ListNodeBalancerConfigs(l.context, nodeBalancer.ID, linodego.NewListOptions(0, ""))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		backends := nodeBalancerBackends(instances, config.Port)
+
+		var current []linodego.NodeBalancerNode
+		err := l.call("list NodeBalancer nodes", func() (err error) {
+			current, err = l.client.ListNodeBalancerNodes(l.context, nodeBalancer.ID, config.ID, linodego.NewListOptions(0, ""))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if sameBackends(current, backends) {
+			continue
+		}
+
+		create := nodeBalancerConfig(config.Port, backends)
+		rebuild := linodego.NodeBalancerConfigRebuildOptions{
+			Port:          create.Port,
+			Protocol:      create.Protocol,
+			Algorithm:     create.Algorithm,
+			Stickiness:    create.Stickiness,
+			Check:         create.Check,
+			CheckInterval: create.CheckInterval,
+			CheckTimeout:  create.CheckTimeout,
+			CheckAttempts: create.CheckAttempts,
+			Nodes:         []linodego.NodeBalancerConfigRebuildNodeOptions{},
+		}
+		for _, node := range create.Nodes {
+			rebuild.Nodes = append(rebuild.Nodes, linodego.NodeBalancerConfigRebuildNodeOptions{NodeBalancerNodeCreateOptions: node})
+		}
+		err = l.call(fmt.Sprintf("update NodeBalancer backends of port %d", config.Port), func() error {
+			_, err := l.client.RebuildNodeBalancerConfig(l.context, nodeBalancer.ID, config.ID, rebuild)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteNodeBalancer deletes the cluster NodeBalancer if there is one
+func (l *LinodeProvider) deleteNodeBalancer(name string) error {
+	nodeBalancer, err := l.findNodeBalancer(name)
+	if err != nil || nodeBalancer == nil {
+		return err
+	}
+
+	err = l.call("delete NodeBalancer "+nodeBalancerLabel(name), func() error {
+		return l.client.DeleteNodeBalancer(l.context, nodeBalancer.ID)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// nodeBalancerConfig balances TCP connections on port across the backends,
+// taking backends failing a connection check out of rotation
+func nodeBalancerConfig(port int, backends []linodego.NodeBalancerNodeCreateOptions) linodego.NodeBalancerConfigCreateOptions {
+	return linodego.NodeBalancerConfigCreateOptions{
+		Port:          port,
+		Protocol:      linodego.ProtocolTCP,
+		Algorithm:     linodego.AlgorithmLeastConn,
+		Stickiness:    linodego.StickinessNone,
+		Check:         linodego.CheckConnection,
+		CheckInterval: 10,
+		CheckTimeout:  5,
+		CheckAttempts: 3,
+		Nodes:         backends,
+	}
+}
+
+// nodeBalancerBackends returns the backends of a port: control plane nodes
+// for the Kubernetes API, the ingress nodes otherwise
+func nodeBalancerBackends(instances []linodego.Instance, port int) []linodego.NodeBalancerNodeCreateOptions {
+	targets := ingressInstances(instances)
+	if port == KubernetesAPIPort {
+		targets = roleInstances(instances, RoleControlPlane)
+	}
+
+	var backends []linodego.NodeBalancerNodeCreateOptions
+	for _, instance := range targets {
+		ips := instancePrivateIPs(instance)
+		if len(ips) == 0 {
+			continue
+		}
+		backends = append(backends, linodego.NodeBalancerNodeCreateOptions{
+			Address: net.JoinHostPort(ips[0], strconv.Itoa(port)),
+			Label:   truncateLabel(instance.Label, 32),
+			Weight:  100,
+			Mode:    linodego.ModeAccept,
+		})
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].Address < backends[j].Address
+	})
+	return backends
+}
+
+// sameBackends reports whether the registered nodes are the wanted backends
+func sameBackends(current []linodego.NodeBalancerNode, wanted []linodego.NodeBalancerNodeCreateOptions) bool {
+	if len(current) != len(wanted) {
+		return false
+	}
+	addresses := map[string]bool{}
+	for _, node := range current {
+		addresses[node.Address] = true
+	}
+	for _, backend := range wanted {
+		if !addresses[backend.Address] {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeBalancerAddresses returns the public addresses of a NodeBalancer
+func nodeBalancerAddresses(nodeBalancer *linodego.NodeBalancer) []string {
+	var addresses []string
+	for _, ip := range []*string{nodeBalancer.IPv4, nodeBalancer.IPv6} {
+		if ip != nil && *ip != "" {
+			addresses = append(addresses, *ip)
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,140 @@
+package providers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+// nodeBalancerInstance returns a cluster instance with a private address
+func nodeBalancerInstance(id int, label, role, privateIP string) linodego.Instance {
+	public := net.ParseIP("203.0.113.10")
+	private := net.ParseIP(privateIP)
+	return linodego.Instance{
+		ID:    id,
+		Label: label,
+		Tags:  []string{"talos-autoextender", "cluster:test", "role:" + role},
+		IPv4:  []*net.IP{&public, &private},
+	}
+}
+
+func TestNodeBalancerBackends(t *testing.T) {
+	instances := []linodego.Instance{
+		nodeBalancerInstance(1, "test-controlplane-0", RoleControlPlane, "192.168.128.1"),
+		nodeBalancerInstance(2, "test-worker-1", RoleWorker, "192.168.128.3"),
+		nodeBalancerInstance(3, "test-worker-0", RoleWorker, "192.168.128.2"),
+	}
+
+	tests := []struct {
+		name      string
+		instances []linodego.Instance
+		port      int
+		want      []string
+	}{
+		{
+			name:      "Kubernetes API on control planes",
+			instances: instances,
+			port:      KubernetesAPIPort,
+			want:      []string{"192.168.128.1:6443"},
+		},
+		{
+			name:      "ingress on workers",
+			instances: instances,
+			port:      443,
+			want:      []string{"192.168.128.2:443", "192.168.128.3:443"},
+		},
+		{
+			name:      "ingress without workers",
+			instances: instances[:1],
+			port:      80,
+			want:      []string{"192.168.128.1:80"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backends := nodeBalancerBackends(tt.instances, tt.port)
+			if len(backends) != len(tt.want) {
+				t.Fatalf("nodeBalancerBackends() = %+v, want %v", backends, tt.want)
+			}
+			for i, backend := range backends {
+				if backend.Address != tt.want[i] {
+					t.Errorf("Expected backend %s, got %s", tt.want[i], backend.Address)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncNodeBalancer(t *testing.T) {
+	var mu sync.Mutex
+	rebuilt := map[string]linodego.NodeBalancerConfigRebuildOptions{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+
+		var response any
+		switch {
+		case r.URL.Path == "/v4/linode/instances":
+			response = linodego.InstancesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 2},
+				Data: []linodego.Instance{
+					nodeBalancerInstance(1, "test-controlplane-0", RoleControlPlane, "192.168.128.1"),
+					nodeBalancerInstance(2, "test-worker-0", RoleWorker, "192.168.128.2"),
+				},
+			}
+		case r.URL.Path == "/v4/nodebalancers/9/configs":
+			response = linodego.NodeBalancerConfigsPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 2},
+				Data:        []linodego.NodeBalancerConfig{{ID: 91, Port: 6443}, {ID: 92, Port: 80}},
+			}
+		case r.URL.Path == "/v4/nodebalancers/9/configs/91/nodes":
+			response = linodego.NodeBalancerNodesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 1},
+				Data:        []linodego.NodeBalancerNode{{ID: 1, Address: "192.168.128.1:6443"}},
+			}
+		case r.URL.Path == "/v4/nodebalancers/9/configs/92/nodes":
+			response = linodego.NodeBalancerNodesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 0},
+			}
+		case r.Method == http.MethodPost && (r.URL.Path == "/v4/nodebalancers/9/configs/91/rebuild" || r.URL.Path == "/v4/nodebalancers/9/configs/92/rebuild"):
+			var opts linodego.NodeBalancerConfigRebuildOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rebuilt[r.URL.Path] = opts
+			response = linodego.NodeBalancerConfig{Port: opts.Port}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := testProvider(server, NewRetrier())
+	if err := provider.syncNodeBalancer(ClusterSpec{Name: "test"}, &linodego.NodeBalancer{ID: 9}); err != nil {
+		t.Fatalf("syncNodeBalancer() error = %v", err)
+	}
+
+	if len(rebuilt) != 1 {
+		t.Fatalf("Expected only the changed config to be rebuilt, got %v", rebuilt)
+	}
+	ingress, ok := rebuilt["/v4/nodebalancers/9/configs/92/rebuild"]
+	if !ok || len(ingress.Nodes) != 1 || ingress.Nodes[0].Address != "192.168.128.2:80" {
+		t.Errorf("Expected the worker to become the ingress backend, got %+v", ingress)
+	}
+	if ingress.Check != linodego.CheckConnection {
+		t.Errorf("Expected a connection health check, got %q", ingress.Check)
+	}
+}
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/linode/linodego"
+)
+
+// placementGroupLabel names the anti-affinity group of the cluster's control
+// plane nodes
+func placementGroupLabel(cluster string) string {
+	return truncateLabel("talos-"+cluster+"-controlplane", 64)
+}
+
+// findPlacementGroup returns the cluster placement group, or nil when there
+// is none
+func (l *LinodeProvider) findPlacementGroup(name string) (*linodego.PlacementGroup, error) {
+	label := placementGroupLabel(name)
+	var groups []linodego.PlacementGroup
+	err := l.call("list placement groups", func() (err error) {
+		groups, err = l.client.ListPlacementGroups(l.context, linodego.NewListOptions(0, linodeFilter("label", label)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range groups {
+		if groups[i].Label == label {
+			return &groups[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ensurePlacementGroup creates the cluster placement group when the spec asks
+// for one and it is missing, and returns its ID, or 0 without placement. An
+// existing group keeps its policy, which Linode cannot change.
+func (l *LinodeProvider) ensurePlacementGroup(spec ClusterSpec) (int, error) {
+	if !placementEnabled(spec.Placement) {
+		return 0, nil
+	}
+
+	group, err := l.findPlacementGroup(spec.Name)
+	if err != nil {
+		return 0, err
+	}
+	if group != nil {
+		return group.ID, nil
+	}
+
+	label := placementGroupLabel(spec.Name)
+	err = l.callOnce("create placement group "+label, func() (err error) {
+		group, err = l.client.CreatePlacementGroup(l.context, linodego.PlacementGroupCreateOptions{
+			Label:        label,
+			Region:       l.config.Region,
+			AffinityType: linodego.AffinityTypeAntiAffinityLocal,
+			IsStrict:     spec.Placement == PlacementStrict,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return group.ID, nil
+}
+
+// syncPlacementGroup assigns control plane nodes created before the group,
+// e.g. when placement is enabled on an existing cluster
+func (l *LinodeProvider) syncPlacementGroup(spec ClusterSpec, groupID int) error {
+	if groupID == 0 {
+		return nil
+	}
+
+	instances, err := l.clusterInstances(spec.Name)
+	if err != nil {
+		return err
+	}
+
+	var unassigned []int
+	for _, instance := range roleInstances(instances, RoleControlPlane) {
+		if instance.PlacementGroup == nil {
+			unassigned = append(unassigned, instance.ID)
+		}
+	}
+	if len(unassigned) == 0 {
+		return nil
+	}
+
+	return l.call("assign control plane nodes to "+placementGroupLabel(spec.Name), func() error {
+		_, err := l.client.AssignPlacementGroupLinodes(l.context, groupID, linodego.PlacementGroupAssignOptions{Linodes: unassigned})
+		return err
+	})
+}
+
+// deletePlacementGroup deletes the cluster placement group if there is one;
+// its nodes must be deleted first
+func (l *LinodeProvider) deletePlacementGroup(name string) error {
+	group, err := l.findPlacementGroup(name)
+	if err != nil || group == nil {
+		return err
+	}
+
+	err = l.call("delete placement group "+group.Label, func() error {
+		return l.client.DeletePlacementGroup(l.context, group.ID)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// placementStatus reports the compliance of a placement group, naming its
+// non-compliant members by their instance labels
+func placementStatus(group linodego.PlacementGroup, instances []linodego.Instance) *PlacementStatus {
+	status := &PlacementStatus{
+		Group:     group.Label,
+		Policy:    PlacementFlexible,
+		Compliant: group.IsCompliant,
+	}
+	if group.IsStrict {
+		status.Policy = PlacementStrict
+	}
+
+	labels := map[int]string{}
+	for _, instance := range instances {
+		labels[instance.ID] = instance.Label
+	}
+	for _, member := range group.Members {
+		if member.IsCompliant {
+			continue
+		}
+		label, ok := labels[member.LinodeID]
+		if !ok {
+			label = strconv.Itoa(member.LinodeID)
+		}
+		status.NonCompliant = append(status.NonCompliant, label)
+	}
+	return status
+}
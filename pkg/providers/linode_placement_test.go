@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestPlacementStatus(t *testing.T) {
+	instances := []linodego.Instance{
+		{ID: 1, Label: "test-controlplane-0"},
+		{ID: 2, Label: "test-controlplane-1"},
+	}
+
+	tests := []struct {
+		name  string
+		group linodego.PlacementGroup
+		want  PlacementStatus
+	}{
+		{
+			name: "compliant strict group",
+			group: linodego.PlacementGroup{
+				Label:       "talos-test-controlplane",
+				IsStrict:    true,
+				IsCompliant: true,
+				Members:     []linodego.PlacementGroupMember{{LinodeID: 1, IsCompliant: true}, {LinodeID: 2, IsCompliant: true}},
+			},
+			want: PlacementStatus{Group: "talos-test-controlplane", Policy: PlacementStrict, Compliant: true},
+		},
+		{
+			name: "non-compliant flexible group",
+			group: linodego.PlacementGroup{
+				Label:   "talos-test-controlplane",
+				Members: []linodego.PlacementGroupMember{{LinodeID: 1, IsCompliant: true}, {LinodeID: 2}, {LinodeID: 3}},
+			},
+			want: PlacementStatus{
+				Group:        "talos-test-controlplane",
+				Policy:       PlacementFlexible,
+				NonCompliant: []string{"test-controlplane-1", "3"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := placementStatus(tt.group, instances)
+			if !reflect.DeepEqual(*status, tt.want) {
+				t.Errorf("placementStatus() = %+v, want %+v", *status, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncPlacementGroup(t *testing.T) {
+	var mu sync.Mutex
+	var assigned []int
+
+	controlPlane := nodeBalancerInstance(1, "test-controlplane-0", RoleControlPlane, "192.168.128.1")
+	controlPlane.PlacementGroup = &linodego.InstancePlacementGroup{ID: 5}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+
+		var response any
+		switch {
+		case r.URL.Path == "/v4/linode/instances":
+			response = linodego.InstancesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 3},
+				Data: []linodego.Instance{
+					controlPlane,
+					nodeBalancerInstance(2, "test-controlplane-1", RoleControlPlane, "192.168.128.2"),
+					nodeBalancerInstance(3, "test-worker-0", RoleWorker, "192.168.128.3"),
+				},
+			}
+		case r.Method == http.MethodPost && r.URL.Path == "/v4/placement/groups/5/assign":
+			var opts linodego.PlacementGroupAssignOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			assigned = append(assigned, opts.Linodes...)
+			response = linodego.PlacementGroup{ID: 5}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := testProvider(server, NewRetrier())
+	if err := provider.syncPlacementGroup(ClusterSpec{Name: "test"}, 5); err != nil {
+		t.Fatalf("syncPlacementGroup() error = %v", err)
+	}
+	if !reflect.DeepEqual(assigned, []int{2}) {
+		t.Errorf("Expected only the unassigned control plane node to be assigned, got %v", assigned)
+	}
+
+	if err := provider.syncPlacementGroup(ClusterSpec{Name: "test"}, 0); err != nil {
+		t.Errorf("syncPlacementGroup() without a group error = %v", err)
+	}
+}
@@ -3,9 +3,15 @@ package providers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/linode/linodego"
 	"golang.org/x/oauth2"
@@ -152,6 +158,7 @@ func setupMockLinodeAPI(t *testing.T) (*httptest.Server, *LinodeProvider) {
 		},
 		client:  client,
 		context: context.Background(),
+		retry:   NewRetrier(),
 	}
 
 	return server, provider
@@ -163,9 +170,9 @@ func TestCreateCluster(t *testing.T) {
 	defer server.Close()
 
 	spec := ClusterSpec{
-		NodeCount:    3,
-		NodeSize:     "g6-standard-2",
+		Pools:        DefaultNodePools(3, "g6-standard-2", 1),
 		TalosVersion: "v1.6.0",
+		Image:        "private/123",
 	}
 
 	err := provider.CreateCluster(spec)
@@ -185,6 +192,150 @@ func TestDeleteCluster(t *testing.T) {
 	}
 }
 
+// poolServer serves the given instances and records the labels of the
+// instances created and deleted
+func poolServer(t *testing.T, instances []linodego.Instance) (*httptest.Server, *[]string, *[]string) {
+	var mu sync.Mutex
+	var created, deleted []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+
+		var response any
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v4/linode/types":
+			response = linodego.LinodeTypesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 2},
+				Data:        []linodego.LinodeType{{ID: "g6-standard-2"}, {ID: "g1-gpu-rtx6000-1"}},
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/v4/linode/instances":
+			response = linodego.InstancesPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: len(instances)},
+				Data:        instances,
+			}
+		case r.Method == http.MethodPost && r.URL.Path == "/v4/linode/instances":
+			var opts linodego.InstanceCreateOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			created = append(created, opts.Label)
+			response = linodego.Instance{ID: 1000 + len(created), Label: opts.Label, Status: linodego.InstanceRunning}
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v4/linode/instances/"):
+			response = linodego.Instance{Status: linodego.InstanceRunning}
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v4/linode/instances/"):
+			for _, instance := range instances {
+				if r.URL.Path == fmt.Sprintf("/v4/linode/instances/%d", instance.ID) {
+					deleted = append(deleted, instance.Label)
+				}
+			}
+			response = struct{}{}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &created, &deleted
+}
+
+func TestUpdateClusterPools(t *testing.T) {
+	node := func(id int, role, pool string, index int) linodego.Instance {
+		return linodego.Instance{
+			ID:     id,
+			Label:  nodeLabel("test", pool, index),
+			Region: "us-east",
+			Status: linodego.InstanceRunning,
+			Tags:   []string{"talos-autoextender", clusterTag("test"), "role:" + role, "pool:" + pool},
+		}
+	}
+	instances := []linodego.Instance{
+		node(1, RoleControlPlane, DefaultControlPlanePool, 0),
+		node(2, RoleWorker, DefaultWorkerPool, 0),
+		node(3, RoleWorker, DefaultWorkerPool, 1),
+		node(4, RoleWorker, DefaultWorkerPool, 2),
+		node(5, RoleWorker, "gpu", 0),
+	}
+	controlPlane := NodePool{Name: DefaultControlPlanePool, Role: RoleControlPlane, Size: "g6-standard-2", Count: 1}
+
+	tests := []struct {
+		name        string
+		pools       []NodePool
+		wantCreated []string
+		wantDeleted []string
+		shouldError bool
+	}{
+		{
+			name: "add a worker pool",
+			pools: []NodePool{
+				controlPlane,
+				{Name: DefaultWorkerPool, Role: RoleWorker, Size: "g6-standard-2", Count: 3},
+				{Name: "batch", Role: RoleWorker, Size: "g6-standard-2", Count: 2},
+			},
+			wantCreated: []string{"test-batch-0", "test-batch-1"},
+		},
+		{
+			name: "scale one pool up and another down",
+			pools: []NodePool{
+				{Name: DefaultWorkerPool, Role: RoleWorker, Size: "g6-standard-2", Count: 1},
+				{Name: "gpu", Role: RoleWorker, Size: "g1-gpu-rtx6000-1", Count: 2},
+			},
+			wantCreated: []string{"test-gpu-1"},
+			wantDeleted: []string{"test-worker-2", "test-worker-1"},
+		},
+		{
+			name: "pools left out of the spec are kept",
+			pools: []NodePool{
+				{Name: "gpu", Role: RoleWorker, Size: "g1-gpu-rtx6000-1", Count: 0},
+			},
+			wantDeleted: []string{"test-gpu-0"},
+		},
+		{
+			name:        "control plane scale down",
+			pools:       []NodePool{{Name: DefaultControlPlanePool, Role: RoleControlPlane, Size: "g6-standard-2", Count: 0}},
+			shouldError: true,
+		},
+		{
+			name:        "unknown size",
+			pools:       []NodePool{{Name: "big", Role: RoleWorker, Size: "g6-dedicated-64", Count: 1}},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, created, deleted := poolServer(t, instances)
+			provider := testProvider(server, NewRetrier())
+
+			spec := ClusterSpec{
+				Name:     "test",
+				Pools:    tt.pools,
+				Image:    "private/1",
+				Firewall: FirewallSpec{Disabled: true},
+			}
+			err := provider.UpdateCluster(spec)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("UpdateCluster() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSpec) {
+				t.Errorf("Expected an invalid spec error, got %v", err)
+			}
+			if fmt.Sprint(*created) != fmt.Sprint(tt.wantCreated) {
+				t.Errorf("Created %v, want %v", *created, tt.wantCreated)
+			}
+			if fmt.Sprint(*deleted) != fmt.Sprint(tt.wantDeleted) {
+				t.Errorf("Deleted %v, want %v", *deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
 func TestGetClusterStatus(t *testing.T) {
 	t.Skip("Skipping test that makes real API calls")
 	server, provider := setupMockLinodeAPI(t)
@@ -209,6 +360,77 @@ func TestGetClusterStatus(t *testing.T) {
 	}
 }
 
+func TestInstancePublicIPs(t *testing.T) {
+	public := net.ParseIP("203.0.113.10")
+	private := net.ParseIP("192.168.128.5")
+
+	instance := linodego.Instance{
+		IPv4: []*net.IP{&public, &private},
+		IPv6: "2600:3c00::f03c:91ff:fe24:3a2f/128",
+	}
+
+	ips := instancePublicIPs(instance)
+	if len(ips) != 2 {
+		t.Fatalf("Expected 2 public IPs, got %v", ips)
+	}
+	if ips[0] != "203.0.113.10" {
+		t.Errorf("Expected public IPv4 first, got %s", ips[0])
+	}
+	if ips[1] != "2600:3c00::f03c:91ff:fe24:3a2f" {
+		t.Errorf("Expected IPv6 without prefix length, got %s", ips[1])
+	}
+
+	status := ClusterStatus{Nodes: []NodeStatus{{PublicIPs: ips}, {PublicIPs: []string{"203.0.113.11"}}}}
+	if addresses := status.PublicAddresses(); len(addresses) != 3 {
+		t.Errorf("Expected 3 public addresses, got %v", addresses)
+	}
+
+	status.Endpoints.LoadBalancer = []string{"198.51.100.20"}
+	if addresses := status.PublicAddresses(); len(addresses) != 1 || addresses[0] != "198.51.100.20" {
+		t.Errorf("Expected the load balancer address, got %v", addresses)
+	}
+}
+
+func TestInstanceStatus(t *testing.T) {
+	public := net.ParseIP("203.0.113.10")
+	private := net.ParseIP("192.168.128.5")
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	node := instanceStatus(linodego.Instance{
+		ID:          42,
+		Label:       "cloud1-gpu-0",
+		Type:        "g1-gpu-rtx6000-1",
+		Status:      linodego.InstanceRunning,
+		IPv4:        []*net.IP{&public, &private},
+		Tags:        []string{"talos-autoextender", "role:worker", "pool:gpu"},
+		Created:     &created,
+		HasUserData: true,
+	})
+
+	expected := NodeStatus{
+		ID:             "42",
+		Label:          "cloud1-gpu-0",
+		Role:           RoleWorker,
+		Pool:           "gpu",
+		Size:           "g1-gpu-rtx6000-1",
+		State:          "running",
+		PublicIPs:      []string{"203.0.113.10"},
+		PrivateIPs:     []string{"192.168.128.5"},
+		CreatedAt:      created,
+		ConfigInjected: true,
+	}
+	if fmt.Sprintf("%+v", node) != fmt.Sprintf("%+v", expected) {
+		t.Errorf("instanceStatus() = %+v, expected %+v", node, expected)
+	}
+
+	if age := node.Age(created.Add(90 * time.Minute)); age != 90*time.Minute {
+		t.Errorf("Age() = %v, expected 1h30m", age)
+	}
+	if age := (NodeStatus{}).Age(created); age != 0 {
+		t.Errorf("Age() = %v, expected 0 without a creation time", age)
+	}
+}
+
 func TestGenerateRandomPassword(t *testing.T) {
 	t.Skip("Skipping test for random password generation due to potential flakiness")
 	// Call the function twice to make sure we get different results
@@ -230,8 +452,70 @@ func TestWaitForInstanceStatus(t *testing.T) {
 	server, provider := setupMockLinodeAPI(t)
 	defer server.Close()
 
-	err := waitForInstanceStatus(provider.context, &provider.client, 123, linodego.InstanceRunning, 5)
+	err := provider.waitForInstanceStatus(123, linodego.InstanceRunning, 5)
 	if err != nil {
 		t.Errorf("waitForInstanceStatus() error = %v, expected nil", err)
 	}
 }
+
+func TestBelongsToCluster(t *testing.T) {
+	tests := []struct {
+		name     string
+		label    string
+		tags     []string
+		cluster  string
+		expected bool
+	}{
+		{name: "tagged with cluster", tags: []string{"talos-autoextender", "cluster:cloud1"}, cluster: "cloud1", expected: true},
+		{name: "tagged with other cluster", label: "cloud1-worker-0", tags: []string{"talos-autoextender", "cluster:cloud2"}, cluster: "cloud1", expected: false},
+		{name: "legacy instance of the cluster", label: "cloud1-worker-0", tags: []string{"talos-autoextender"}, cluster: "cloud1", expected: true},
+		{name: "legacy instance of another cluster", label: "cloud2-worker-0", tags: []string{"talos-autoextender"}, cluster: "cloud1", expected: false},
+		{name: "legacy instance without cluster name", label: "talos-node-0", tags: []string{"talos-autoextender"}, cluster: "cloud1", expected: false},
+		{name: "not managed", tags: []string{"cluster:cloud1"}, cluster: "cloud1", expected: false},
+		{name: "no cluster name", label: "cloud2-worker-0", tags: []string{"talos-autoextender", "cluster:cloud2"}, cluster: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := linodego.Instance{Label: tt.label, Tags: tt.tags}
+			if got := belongsToCluster(instance, tt.cluster); got != tt.expected {
+				t.Errorf("belongsToCluster() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+
+	if role := tagValue([]string{"talos-node", "role:controlplane"}, "role:"); role != RoleControlPlane {
+		t.Errorf("Expected role %s, got %q", RoleControlPlane, role)
+	}
+	// An empty name must fail before any API call, which the nil client would panic on
+	if err := (&LinodeProvider{}).DeleteCluster(""); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("DeleteCluster() error = %v, expected ErrInvalidSpec for an empty name", err)
+	}
+}
+
+func TestFactoryImage(t *testing.T) {
+	tests := []struct {
+		version   string
+		supported bool
+	}{
+		{"v1.6.7", false},
+		{"v1.7.0", true},
+		{"1.8.1", true},
+		{"v2.0.0", true},
+		{"latest", false},
+	}
+
+	for _, tt := range tests {
+		if got := supportsAkamaiPlatform(tt.version); got != tt.supported {
+			t.Errorf("supportsAkamaiPlatform(%q) = %v, expected %v", tt.version, got, tt.supported)
+		}
+	}
+
+	label := factoryImageLabel("ce4c980550dd2ab1b17bbf2b08801c7eb59418eafe8f279833297925d67c7515", "v1.7.0")
+	if label != "talos-1.7.0-ce4c980550dd" {
+		t.Errorf("factoryImageLabel() = %s, expected talos-1.7.0-ce4c980550dd", label)
+	}
+	if len(label) > 50 {
+		t.Errorf("factoryImageLabel() is %d characters, Linode allows 50", len(label))
+	}
+}
@@ -0,0 +1,287 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+// vpcTag marks instances attached to a VPC, whose private addresses are only
+// reported by their configs
+const vpcTag = "network:vpc"
+
+// vpcLabel names the VPC of a cluster
+func vpcLabel(spec ClusterSpec) string {
+	if spec.VPC.Name != "" {
+		return spec.VPC.Name
+	}
+	return clusterVPCLabel(spec.Name)
+}
+
+// clusterVPCLabel names the VPC created for a cluster of its own
+func clusterVPCLabel(cluster string) string {
+	return truncateLabel("talos-"+cluster, 64)
+}
+
+// subnetLabel names the cluster's subnet, which is unique across VPCs the
+// cluster could share
+func subnetLabel(cluster string) string {
+	return truncateLabel("talos-"+cluster, 64)
+}
+
+// truncateLabel fits a label into max characters. Truncated labels end in a
+// hash of the whole label so that names sharing a long prefix stay distinct.
+func truncateLabel(label string, max int) string {
+	if len(label) <= max {
+		return label
+	}
+	sum := sha256.Sum256([]byte(label))
+	suffix := "-" + hex.EncodeToString(sum[:])[:8]
+	return label[:max-len(suffix)] + suffix
+}
+
+// findVPC returns the labeled VPC, or nil when there is none
+func (l *LinodeProvider) findVPC(label string) (*linodego.VPC, error) {
+	var vpcs []linodego.VPC
+	err := l.call("list VPCs", func() (err error) {
+		vpcs, err = l.client.ListVPCs(l.context, linodego.NewListOptions(0, linodeFilter("label", label)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range vpcs {
+		if vpcs[i].Label == label {
+			return &vpcs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ensureSubnet returns the ID of the cluster's VPC subnet, creating the VPC
+// and subnet when missing, or 0 when the spec has no VPC
+func (l *LinodeProvider) ensureSubnet(spec ClusterSpec) (int, error) {
+	if !spec.VPC.Enabled {
+		return 0, nil
+	}
+
+	label := vpcLabel(spec)
+	vpc, err := l.findVPC(label)
+	if err != nil {
+		return 0, err
+	}
+
+	subnet := linodego.VPCSubnetCreateOptions{Label: subnetLabel(spec.Name), IPv4: spec.VPC.Subnet}
+	if vpc == nil {
+		opts := linodego.VPCCreateOptions{
+			Label:   label,
+			Region:  l.config.Region,
+			Subnets: []linodego.VPCSubnetCreateOptions{subnet},
+		}
+		err := l.callOnce("create VPC "+label, func() (err error) {
+			vpc, err = l.client.CreateVPC(l.context, opts)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(vpc.Subnets) == 0 {
+			return 0, fmt.Errorf("VPC %s was created without a subnet", label)
+		}
+		return vpc.Subnets[0].ID, nil
+	}
+
+	if vpc.Region != l.config.Region {
+		return 0, fmt.Errorf("%w: VPC %s is in region %s, not %s", ErrInvalidSpec, label, vpc.Region, l.config.Region)
+	}
+	for _, existing := range vpc.Subnets {
+		if existing.Label != subnet.Label {
+			continue
+		}
+		if existing.IPv4 != subnet.IPv4 {
+			return 0, fmt.Errorf("%w: subnet %s of VPC %s is %s, not %s", ErrInvalidSpec, subnet.Label, label, existing.IPv4, subnet.IPv4)
+		}
+		return existing.ID, nil
+	}
+
+	var created *linodego.VPCSubnet
+	err = l.callOnce("create subnet "+subnet.Label, func() (err error) {
+		created, err = l.client.CreateVPCSubnet(l.context, subnet, vpc.ID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+// deleteVPC removes the cluster's subnet from its own VPC and from the joined
+// VPCs, and its own VPC when no other subnets are left. Joined VPCs are kept.
+func (l *LinodeProvider) deleteVPC(name string, joined []int) error {
+	own, err := l.findVPC(clusterVPCLabel(name))
+	if err != nil {
+		return err
+	}
+
+	var vpcs []linodego.VPC
+	seen := map[int]bool{}
+	if own != nil {
+		vpcs = append(vpcs, *own)
+		seen[own.ID] = true
+	}
+	for _, id := range joined {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		var vpc *linodego.VPC
+		err := l.call(fmt.Sprintf("get VPC %d", id), func() (err error) {
+			vpc, err = l.client.GetVPC(l.context, id)
+			return err
+		})
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		vpcs = append(vpcs, *vpc)
+	}
+
+	for _, vpc := range vpcs {
+		remaining := len(vpc.Subnets)
+		for _, subnet := range vpc.Subnets {
+			if subnet.Label != subnetLabel(name) {
+				continue
+			}
+			if err := l.deleteSubnet(vpc, subnet); err != nil {
+				return err
+			}
+			remaining--
+		}
+
+		if own == nil || vpc.ID != own.ID || remaining > 0 {
+			continue
+		}
+		err := l.call("delete VPC "+vpc.Label, func() error {
+			return l.client.DeleteVPC(l.context, vpc.ID)
+		})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSubnet deletes a subnet once the deleted instances have left it
+func (l *LinodeProvider) deleteSubnet(vpc linodego.VPC, subnet linodego.VPCSubnet) error {
+	deadline := time.Now().Add(5 * time.Minute)
+	for len(subnet.Linodes) > 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("subnet %s of VPC %s still has %d instances", subnet.Label, vpc.Label, len(subnet.Linodes))
+		}
+		if err := sleepContext(l.context, 5*time.Second); err != nil {
+			return err
+		}
+
+		var current *linodego.VPCSubnet
+		err := l.call("get subnet "+subnet.Label, func() (err error) {
+			current, err = l.client.GetVPCSubnet(l.context, vpc.ID, subnet.ID)
+			return err
+		})
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		subnet = *current
+	}
+
+	err := l.call("delete subnet "+subnet.Label, func() error {
+		return l.client.DeleteVPCSubnet(l.context, vpc.ID, subnet.ID)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// instanceVPCIPs returns the VPC addresses of an instance
+func (l *LinodeProvider) instanceVPCIPs(instance linodego.Instance) ([]string, error) {
+	interfaces, err := l.instanceVPCInterfaces(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, iface := range interfaces {
+		if iface.IPv4 != nil && iface.IPv4.VPC != "" {
+			ips = append(ips, iface.IPv4.VPC)
+		}
+	}
+	return ips, nil
+}
+
+// instanceVPCIDs returns the IDs of the VPCs an instance is attached to
+func (l *LinodeProvider) instanceVPCIDs(instance linodego.Instance) ([]int, error) {
+	interfaces, err := l.instanceVPCInterfaces(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, iface := range interfaces {
+		if iface.VPCID != nil {
+			ids = append(ids, *iface.VPCID)
+		}
+	}
+	return ids, nil
+}
+
+// instanceVPCInterfaces returns the VPC interfaces of an instance's configs
+func (l *LinodeProvider) instanceVPCInterfaces(instance linodego.Instance) ([]linodego.InstanceConfigInterface, error) {
+	var configs []linodego.InstanceConfig
+	err := l.call("list configs of "+instance.Label, func() (err error) {
+		configs, err = l.client.ListInstanceConfigs(l.context, instance.ID, linodego.NewListOptions(0, ""))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []linodego.InstanceConfigInterface
+	for _, config := range configs {
+		for _, iface := range config.Interfaces {
+			if iface.Purpose == linodego.InterfacePurposeVPC {
+				interfaces = append(interfaces, iface)
+			}
+		}
+	}
+	return interfaces, nil
+}
+
+// vpcInterfaces attaches an instance to a subnet with a public IPv4 address
+// through 1:1 NAT. Instances that need a Linode private address, which only a
+// public interface carries, get a public interface next to the VPC one.
+func vpcInterfaces(subnetID int, privateIP bool) []linodego.InstanceConfigInterfaceCreateOptions {
+	if privateIP {
+		return []linodego.InstanceConfigInterfaceCreateOptions{
+			{Purpose: linodego.InterfacePurposePublic, Primary: true},
+			{Purpose: linodego.InterfacePurposeVPC, SubnetID: &subnetID},
+		}
+	}
+
+	nat := "any"
+	return []linodego.InstanceConfigInterfaceCreateOptions{{
+		Purpose:  linodego.InterfacePurposeVPC,
+		Primary:  true,
+		SubnetID: &subnetID,
+		IPv4:     &linodego.VPCIPv4{NAT1To1: &nat},
+	}}
+}
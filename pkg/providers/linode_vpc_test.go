@@ -0,0 +1,233 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+// vpcServer serves the given VPCs and records the VPC API calls it receives
+func vpcServer(t *testing.T, vpcs []linodego.VPC) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var calls []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+
+		var response any
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v4/vpcs":
+			response = linodego.VPCsPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: len(vpcs)},
+				Data:        vpcs,
+			}
+		case r.Method == http.MethodPost && r.URL.Path == "/v4/vpcs":
+			var opts linodego.VPCCreateOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			vpc := linodego.VPC{ID: 20, Label: opts.Label, Region: opts.Region}
+			for _, subnet := range opts.Subnets {
+				vpc.Subnets = append(vpc.Subnets, linodego.VPCSubnet{ID: 21, Label: subnet.Label, IPv4: subnet.IPv4})
+			}
+			response = vpc
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/subnets"):
+			var opts linodego.VPCSubnetCreateOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			response = linodego.VPCSubnet{ID: 22, Label: opts.Label, IPv4: opts.IPv4}
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v4/vpcs/"):
+			for _, vpc := range vpcs {
+				if r.URL.Path == fmt.Sprintf("/v4/vpcs/%d", vpc.ID) {
+					response = vpc
+				}
+			}
+			if response == nil {
+				http.NotFound(w, r)
+				return
+			}
+		case r.Method == http.MethodDelete:
+			response = struct{}{}
+		case r.Method == http.MethodGet && r.URL.Path == "/v4/linode/instances/5/configs":
+			vpcIP := "172.30.0.5"
+			response = linodego.InstanceConfigsPagedResponse{
+				PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 1},
+				Data: []linodego.InstanceConfig{{
+					ID: 50,
+					Interfaces: []linodego.InstanceConfigInterface{
+						{Purpose: linodego.InterfacePurposeVPC, IPv4: &linodego.VPCIPv4{VPC: vpcIP}},
+					},
+				}},
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &calls
+}
+
+func TestTruncateLabel(t *testing.T) {
+	if label := firewallLabel("cloud1"); label != "talos-cloud1" {
+		t.Errorf("firewallLabel() = %q, expected the label unchanged", label)
+	}
+
+	a := "analytics-production-eu-west-1"
+	b := "analytics-production-eu-west-2"
+	for _, label := range []func(string) string{firewallLabel, nodeBalancerLabel, placementGroupLabel, clusterVPCLabel} {
+		la, lb := label(a+strings.Repeat("x", 40)), label(b+strings.Repeat("x", 40))
+		if la == lb {
+			t.Errorf("Expected clusters sharing a long prefix to get distinct labels, both got %q", la)
+		}
+		if label(a+strings.Repeat("x", 40)) != la {
+			t.Errorf("Expected truncated labels to be stable, got %q", la)
+		}
+	}
+	if label := firewallLabel(a); len(label) != 32 {
+		t.Errorf("firewallLabel() = %q, expected 32 characters", label)
+	}
+}
+
+func TestEnsureSubnet(t *testing.T) {
+	vpcSpec := VPCSpec{Enabled: true, Subnet: "172.30.0.0/24"}
+
+	tests := []struct {
+		name        string
+		vpcs        []linodego.VPC
+		vpc         VPCSpec
+		want        int
+		wantCall    string
+		shouldError bool
+	}{
+		{
+			name:     "creates the cluster VPC",
+			vpc:      vpcSpec,
+			want:     21,
+			wantCall: "POST /v4/vpcs",
+		},
+		{
+			name:     "adds a subnet to a named VPC",
+			vpcs:     []linodego.VPC{{ID: 30, Label: "shared", Region: "us-east", Subnets: []linodego.VPCSubnet{{ID: 31, Label: "talos-other", IPv4: "172.31.0.0/24"}}}},
+			vpc:      VPCSpec{Enabled: true, Name: "shared", Subnet: "172.30.0.0/24"},
+			want:     22,
+			wantCall: "POST /v4/vpcs/30/subnets",
+		},
+		{
+			name: "reuses the subnet",
+			vpcs: []linodego.VPC{{ID: 30, Label: "talos-test", Region: "us-east", Subnets: []linodego.VPCSubnet{{ID: 32, Label: "talos-test", IPv4: "172.30.0.0/24"}}}},
+			vpc:  vpcSpec,
+			want: 32,
+		},
+		{
+			name:        "subnet range changed",
+			vpcs:        []linodego.VPC{{ID: 30, Label: "talos-test", Region: "us-east", Subnets: []linodego.VPCSubnet{{ID: 32, Label: "talos-test", IPv4: "172.29.0.0/24"}}}},
+			vpc:         vpcSpec,
+			shouldError: true,
+		},
+		{
+			name:        "VPC in another region",
+			vpcs:        []linodego.VPC{{ID: 30, Label: "talos-test", Region: "eu-west"}},
+			vpc:         vpcSpec,
+			shouldError: true,
+		},
+		{
+			name: "no VPC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := vpcServer(t, tt.vpcs)
+			provider := testProvider(server, NewRetrier())
+
+			got, err := provider.ensureSubnet(ClusterSpec{Name: "test", VPC: tt.vpc})
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("ensureSubnet() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSpec) {
+				t.Errorf("Expected an invalid spec error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ensureSubnet() = %d, want %d", got, tt.want)
+			}
+			if tt.wantCall != "" && !strings.Contains(strings.Join(*calls, "\n"), tt.wantCall) {
+				t.Errorf("Expected %s, got %v", tt.wantCall, *calls)
+			}
+		})
+	}
+}
+
+func TestDeleteVPC(t *testing.T) {
+	server, calls := vpcServer(t, []linodego.VPC{
+		{ID: 30, Label: "talos-test", Subnets: []linodego.VPCSubnet{{ID: 31, Label: "talos-test"}}},
+		{ID: 40, Label: "shared", Subnets: []linodego.VPCSubnet{{ID: 41, Label: "talos-test"}, {ID: 42, Label: "talos-other"}}},
+		{ID: 50, Label: "unrelated", Subnets: []linodego.VPCSubnet{{ID: 51, Label: "talos-test"}}},
+	})
+	provider := testProvider(server, NewRetrier())
+
+	tests := []struct {
+		name   string
+		joined []int
+		want   string
+	}{
+		{
+			name: "own VPC",
+			want: "30/subnets/31,30",
+		},
+		{
+			name:   "joined VPC",
+			joined: []int{40, 40},
+			want:   "30/subnets/31,30,40/subnets/41",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*calls = nil
+			if err := provider.deleteVPC("test", tt.joined); err != nil {
+				t.Fatalf("deleteVPC() error = %v", err)
+			}
+
+			var deleted []string
+			for _, call := range *calls {
+				if strings.HasPrefix(call, http.MethodDelete) {
+					deleted = append(deleted, strings.TrimPrefix(call, "DELETE /v4/vpcs/"))
+				}
+			}
+			if got := strings.Join(deleted, ","); got != tt.want {
+				t.Errorf("Expected %s to be deleted, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInstanceVPCIPs(t *testing.T) {
+	server, _ := vpcServer(t, nil)
+	provider := testProvider(server, NewRetrier())
+
+	ips, err := provider.instanceVPCIPs(linodego.Instance{ID: 5, Label: "test-worker-0"})
+	if err != nil {
+		t.Fatalf("instanceVPCIPs() error = %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "172.30.0.5" {
+		t.Errorf("Expected the VPC address 172.30.0.5, got %v", ips)
+	}
+}
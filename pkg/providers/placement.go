@@ -0,0 +1,26 @@
+package providers
+
+import "fmt"
+
+// Placement policies spread the control plane nodes across hosts. Strict
+// placement fails node creation when no separate host is available; flexible
+// placement falls back to a shared host and reports the group non-compliant.
+const (
+	PlacementNone     = "none"
+	PlacementStrict   = "strict"
+	PlacementFlexible = "flexible"
+)
+
+// validatePlacement checks a placement policy; empty means none
+func validatePlacement(policy string) error {
+	switch policy {
+	case "", PlacementNone, PlacementStrict, PlacementFlexible:
+		return nil
+	}
+	return fmt.Errorf("invalid placement policy %q, must be %s, %s or %s", policy, PlacementStrict, PlacementFlexible, PlacementNone)
+}
+
+// placementEnabled reports whether a policy asks for anti-affinity
+func placementEnabled(policy string) bool {
+	return policy == PlacementStrict || policy == PlacementFlexible
+}
@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Default pool names used when a cluster is created from a node count
+const (
+	DefaultControlPlanePool = "controlplane"
+	DefaultWorkerPool       = "worker"
+)
+
+var poolNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// NodePool is a group of identical nodes sharing a role
+type NodePool struct {
+	Name  string `yaml:"name"`
+	Role  string `yaml:"role"`
+	Count int    `yaml:"count"`
+	// Size is the provider instance type
+	Size string `yaml:"size"`
+	// Labels and Taints are registered on the pool's Kubernetes nodes. Taints
+	// use the kubectl syntax key[=value]:Effect.
+	Labels map[string]string `yaml:"labels,omitempty"`
+	Taints []string          `yaml:"taints,omitempty"`
+	// Patches are machine config patches for the pool, inline YAML or @file
+	Patches []string `yaml:"patches,omitempty"`
+	// Config is the machine config injected as user data; when empty the
+	// pool's nodes boot into maintenance mode
+	Config []byte `yaml:"-"`
+}
+
+// Validate checks a single pool
+func (p *NodePool) Validate() error {
+	if !poolNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("invalid pool name %q: use lowercase letters, digits and dashes", p.Name)
+	}
+	if p.Role != RoleControlPlane && p.Role != RoleWorker {
+		return fmt.Errorf("pool %s: role must be %s or %s", p.Name, RoleControlPlane, RoleWorker)
+	}
+	if p.Count < 0 || (p.Role == RoleControlPlane && p.Count < 1) {
+		return fmt.Errorf("pool %s: invalid node count %d", p.Name, p.Count)
+	}
+	if p.Size == "" {
+		return fmt.Errorf("pool %s: node size is required", p.Name)
+	}
+	for _, taint := range p.Taints {
+		if err := ValidateTaint(taint); err != nil {
+			return fmt.Errorf("pool %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateTaint checks a taint in the kubectl syntax key[=value]:Effect
+func ValidateTaint(taint string) error {
+	keyValue, effect, ok := strings.Cut(taint, ":")
+	if !ok {
+		return fmt.Errorf("taint %q has no effect", taint)
+	}
+	switch effect {
+	case "NoSchedule", "PreferNoSchedule", "NoExecute":
+	default:
+		return fmt.Errorf("taint %q has invalid effect %q", taint, effect)
+	}
+	if key, _, _ := strings.Cut(keyValue, "="); key == "" {
+		return fmt.Errorf("taint %q has no key", taint)
+	}
+	return nil
+}
+
+// DefaultNodePools splits nodeCount nodes of one size into a control plane
+// and a worker pool
+func DefaultNodePools(nodeCount int, size string, controlPlanes int) []NodePool {
+	if controlPlanes == 0 {
+		controlPlanes = 1
+	}
+
+	pools := []NodePool{{Name: DefaultControlPlanePool, Role: RoleControlPlane, Count: controlPlanes, Size: size}}
+	if workers := nodeCount - controlPlanes; workers > 0 {
+		pools = append(pools, NodePool{Name: DefaultWorkerPool, Role: RoleWorker, Count: workers, Size: size})
+	}
+	return pools
+}
+
+// LoadNodePools parses a YAML list of node pools
+func LoadNodePools(data []byte) ([]NodePool, error) {
+	var pools []NodePool
+	if err := yaml.Unmarshal(data, &pools); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse node pools: %w", ErrInvalidSpec, err)
+	}
+	return pools, nil
+}
+
+// PoolStatus summarizes the nodes of one pool
+type PoolStatus struct {
+	Name  string `json:"name" yaml:"name"`
+	Role  string `json:"role" yaml:"role"`
+	Count int    `json:"count" yaml:"count"`
+	Ready int    `json:"ready" yaml:"ready"`
+}
+
+// poolStatuses groups nodes by pool, control plane pools first
+func poolStatuses(nodes []NodeStatus, ready func(NodeStatus) bool) []PoolStatus {
+	byName := map[string]*PoolStatus{}
+	var pools []*PoolStatus
+
+	for _, node := range nodes {
+		pool, ok := byName[node.Pool]
+		if !ok {
+			pool = &PoolStatus{Name: node.Pool, Role: node.Role}
+			byName[node.Pool] = pool
+			pools = append(pools, pool)
+		}
+		pool.Count++
+		if ready(node) {
+			pool.Ready++
+		}
+	}
+
+	sort.Slice(pools, func(i, j int) bool {
+		if (pools[i].Role == RoleControlPlane) != (pools[j].Role == RoleControlPlane) {
+			return pools[i].Role == RoleControlPlane
+		}
+		return pools[i].Name < pools[j].Name
+	})
+
+	statuses := make([]PoolStatus, len(pools))
+	for i, pool := range pools {
+		statuses[i] = *pool
+	}
+	return statuses
+}
@@ -0,0 +1,220 @@
+package providers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/linode/linodego"
+)
+
+func TestNodePoolValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		pool        NodePool
+		shouldError bool
+	}{
+		{
+			name: "valid worker pool",
+			pool: NodePool{Name: "gpu", Role: RoleWorker, Count: 2, Size: "g1-gpu-rtx6000-1",
+				Labels: map[string]string{"gpu": "true"}, Taints: []string{"nvidia.com/gpu=true:NoSchedule"}},
+			shouldError: false,
+		},
+		{
+			name:        "empty worker pool",
+			pool:        NodePool{Name: "spare", Role: RoleWorker, Count: 0, Size: "g6-standard-2"},
+			shouldError: false,
+		},
+		{
+			name:        "empty control plane pool",
+			pool:        NodePool{Name: "controlplane", Role: RoleControlPlane, Count: 0, Size: "g6-standard-2"},
+			shouldError: true,
+		},
+		{
+			name:        "invalid name",
+			pool:        NodePool{Name: "GPU_nodes", Role: RoleWorker, Count: 1, Size: "g6-standard-2"},
+			shouldError: true,
+		},
+		{
+			name:        "invalid role",
+			pool:        NodePool{Name: "infra", Role: "etcd", Count: 1, Size: "g6-standard-2"},
+			shouldError: true,
+		},
+		{
+			name:        "missing size",
+			pool:        NodePool{Name: "workers", Role: RoleWorker, Count: 1},
+			shouldError: true,
+		},
+		{
+			name:        "taint without effect",
+			pool:        NodePool{Name: "workers", Role: RoleWorker, Count: 1, Size: "g6-standard-2", Taints: []string{"dedicated=infra"}},
+			shouldError: true,
+		},
+		{
+			name:        "taint with invalid effect",
+			pool:        NodePool{Name: "workers", Role: RoleWorker, Count: 1, Size: "g6-standard-2", Taints: []string{"dedicated:Never"}},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pool.Validate()
+			if (err != nil) != tt.shouldError {
+				t.Errorf("NodePool.Validate() error = %v, shouldError %v", err, tt.shouldError)
+			}
+		})
+	}
+}
+
+func TestDefaultNodePools(t *testing.T) {
+	pools := DefaultNodePools(5, "g6-standard-2", 3)
+	if len(pools) != 2 {
+		t.Fatalf("Expected 2 pools, got %d", len(pools))
+	}
+	if pools[0].Role != RoleControlPlane || pools[0].Count != 3 {
+		t.Errorf("Expected 3 control plane nodes, got %+v", pools[0])
+	}
+	if pools[1].Role != RoleWorker || pools[1].Count != 2 {
+		t.Errorf("Expected 2 workers, got %+v", pools[1])
+	}
+
+	if pools := DefaultNodePools(1, "g6-standard-2", 0); len(pools) != 1 || pools[0].Count != 1 {
+		t.Errorf("Expected a single control plane node, got %+v", pools)
+	}
+}
+
+func TestLoadNodePools(t *testing.T) {
+	pools, err := LoadNodePools([]byte(`
+- name: controlplane
+  role: controlplane
+  count: 3
+  size: g6-standard-2
+- name: gpu
+  role: worker
+  count: 2
+  size: g1-gpu-rtx6000-1
+  labels:
+    gpu: "true"
+  taints:
+    - nvidia.com/gpu=true:NoSchedule
+  patches:
+    - "@gpu-patch.yaml"
+`))
+	if err != nil {
+		t.Fatalf("LoadNodePools() error = %v", err)
+	}
+
+	spec := ClusterSpec{Pools: pools, TalosVersion: "v1.6.0"}
+	if err := spec.Validate(); err != nil {
+		t.Errorf("Validate() error = %v, expected nil", err)
+	}
+	if spec.NodeCount() != 5 {
+		t.Errorf("Expected 5 nodes, got %d", spec.NodeCount())
+	}
+
+	gpu, ok := spec.Pool("gpu")
+	if !ok {
+		t.Fatal("Expected a gpu pool")
+	}
+	if gpu.Labels["gpu"] != "true" || len(gpu.Taints) != 1 || len(gpu.Patches) != 1 {
+		t.Errorf("Expected labels, taints and patches on the gpu pool, got %+v", gpu)
+	}
+
+	if _, err := LoadNodePools([]byte("name: [")); err == nil {
+		t.Error("LoadNodePools() error = nil, expected an error")
+	}
+}
+
+func poolInstances(cluster, pool string, indexes ...int) []linodego.Instance {
+	var instances []linodego.Instance
+	for _, index := range indexes {
+		instances = append(instances, linodego.Instance{
+			ID:    1000 + index,
+			Label: nodeLabel(cluster, pool, index),
+			Tags:  []string{"talos-autoextender", "pool:" + pool},
+		})
+	}
+	return instances
+}
+
+func TestPlanPoolScale(t *testing.T) {
+	tests := []struct {
+		name        string
+		pool        NodePool
+		existing    []int
+		create      []int
+		remove      []int
+		shouldError bool
+	}{
+		{name: "scale up fills gaps", pool: NodePool{Name: "workers", Role: RoleWorker, Count: 4}, existing: []int{0, 2}, create: []int{1, 3}},
+		{name: "scale down removes highest", pool: NodePool{Name: "workers", Role: RoleWorker, Count: 1}, existing: []int{0, 1, 2}, remove: []int{2, 1}},
+		{name: "unchanged", pool: NodePool{Name: "workers", Role: RoleWorker, Count: 2}, existing: []int{0, 1}},
+		{name: "control plane scale up", pool: NodePool{Name: "controlplane", Role: RoleControlPlane, Count: 3}, existing: []int{0}, create: []int{1, 2}},
+		{name: "control plane scale down", pool: NodePool{Name: "controlplane", Role: RoleControlPlane, Count: 1}, existing: []int{0, 1, 2}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			create, remove, err := planPoolScale(tt.pool, poolInstances("cloud1", tt.pool.Name, tt.existing...))
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("planPoolScale() error = %v, shouldError %v", err, tt.shouldError)
+			}
+
+			if fmt.Sprint(create) != fmt.Sprint(tt.create) {
+				t.Errorf("Expected to create %v, got %v", tt.create, create)
+			}
+			var removed []int
+			for _, instance := range remove {
+				removed = append(removed, instanceIndex(instance.Label))
+			}
+			if fmt.Sprint(removed) != fmt.Sprint(tt.remove) {
+				t.Errorf("Expected to remove %v, got %v", tt.remove, removed)
+			}
+		})
+	}
+}
+
+func TestInstancePool(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want string
+	}{
+		{tags: []string{"talos-autoextender", "role:worker", "pool:gpu"}, want: "gpu"},
+		{tags: []string{"talos-autoextender", "role:controlplane"}, want: DefaultControlPlanePool},
+		{tags: []string{"talos-autoextender", "role:worker"}, want: DefaultWorkerPool},
+		{tags: []string{"talos-autoextender"}, want: DefaultWorkerPool},
+	}
+
+	for _, tt := range tests {
+		if got := instancePool(linodego.Instance{Tags: tt.tags}); got != tt.want {
+			t.Errorf("instancePool(%v) = %s, expected %s", tt.tags, got, tt.want)
+		}
+	}
+
+	if index := instanceIndex("cloud1-gpu-12"); index != 12 {
+		t.Errorf("instanceIndex() = %d, expected 12", index)
+	}
+	if index := instanceIndex("talos"); index != -1 {
+		t.Errorf("instanceIndex() = %d, expected -1", index)
+	}
+}
+
+func TestPoolStatuses(t *testing.T) {
+	nodes := []NodeStatus{
+		{Pool: "workers", Role: RoleWorker, State: "running"},
+		{Pool: "gpu", Role: RoleWorker, State: "provisioning"},
+		{Pool: "controlplane", Role: RoleControlPlane, State: "running"},
+		{Pool: "workers", Role: RoleWorker, State: "running"},
+	}
+
+	pools := poolStatuses(nodes, func(node NodeStatus) bool { return node.State == "running" })
+
+	expected := []PoolStatus{
+		{Name: "controlplane", Role: RoleControlPlane, Count: 1, Ready: 1},
+		{Name: "gpu", Role: RoleWorker, Count: 1, Ready: 0},
+		{Name: "workers", Role: RoleWorker, Count: 2, Ready: 2},
+	}
+	if fmt.Sprint(pools) != fmt.Sprint(expected) {
+		t.Errorf("poolStatuses() = %v, expected %v", pools, expected)
+	}
+}
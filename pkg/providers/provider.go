@@ -1,5 +1,7 @@
 package providers
 
+import "time"
+
 // ProviderFactory creates cloud provider implementations
 type ProviderFactory interface {
 	CreateProvider(config Provider) (CloudProvider, error)
@@ -10,14 +12,108 @@ type CloudProvider interface {
 	CreateCluster(spec ClusterSpec) error
 	DeleteCluster(name string) error
 	GetClusterStatus(name string) (ClusterStatus, error)
+	// UpdateCluster scales the pools of spec to their node count. Pools not in
+	// spec are left alone.
 	UpdateCluster(spec ClusterSpec) error
 }
 
+// IngressFailover is implemented by providers that can move a cluster's
+// shared ingress address between nodes
+type IngressFailover interface {
+	// FailoverIngress moves the shared ingress address to another ingress
+	// node when its node is not running or healthy reports false for it, and
+	// returns the label of the node holding it
+	FailoverIngress(name string, healthy func(NodeStatus) bool) (string, error)
+}
+
 // ClusterStatus represents the current state of a cluster
 type ClusterStatus struct {
-	Name           string
-	State          string // provisioning, ready, error, etc.
-	NodeCount      int
-	ReadyNodeCount int
-	Error          string
+	Name           string           `json:"name" yaml:"name"`
+	State          string           `json:"state" yaml:"state"` // provisioning, ready, error, etc.
+	NodeCount      int              `json:"nodeCount" yaml:"nodeCount"`
+	ReadyNodeCount int              `json:"readyNodeCount" yaml:"readyNodeCount"`
+	Error          string           `json:"error,omitempty" yaml:"error,omitempty"`
+	Endpoints      ClusterEndpoints `json:"endpoints" yaml:"endpoints"`
+	Nodes          []NodeStatus     `json:"nodes" yaml:"nodes"`
+	Pools          []PoolStatus     `json:"pools" yaml:"pools"`
+	// Placement is the anti-affinity group of the control plane nodes, nil
+	// without one
+	Placement *PlacementStatus `json:"placement,omitempty" yaml:"placement,omitempty"`
+}
+
+// PlacementStatus reports whether the provider keeps a cluster's control plane
+// nodes on separate hosts
+type PlacementStatus struct {
+	Group     string `json:"group" yaml:"group"`
+	Policy    string `json:"policy" yaml:"policy"` // strict or flexible
+	Compliant bool   `json:"compliant" yaml:"compliant"`
+	// NonCompliant are the labels of the nodes sharing a host
+	NonCompliant []string `json:"nonCompliant,omitempty" yaml:"nonCompliant,omitempty"`
+}
+
+// ClusterEndpoints are the API endpoints of a cluster
+type ClusterEndpoints struct {
+	// Kubernetes is the Kubernetes API URL, known once the cluster is bootstrapped
+	Kubernetes string `json:"kubernetes,omitempty" yaml:"kubernetes,omitempty"`
+	// Talos are the addresses of the control plane nodes' Talos API
+	Talos []string `json:"talos,omitempty" yaml:"talos,omitempty"`
+	// LoadBalancer are the public addresses of the cluster load balancer
+	LoadBalancer []string `json:"loadBalancer,omitempty" yaml:"loadBalancer,omitempty"`
+	// IngressIP is the shared ingress address and IngressNode the node holding it
+	IngressIP   string `json:"ingressIP,omitempty" yaml:"ingressIP,omitempty"`
+	IngressNode string `json:"ingressNode,omitempty" yaml:"ingressNode,omitempty"`
+}
+
+// NodeStatus describes a single node of a cluster
+type NodeStatus struct {
+	// ID is the provider's instance ID
+	ID         string    `json:"id" yaml:"id"`
+	Label      string    `json:"label" yaml:"label"`
+	Role       string    `json:"role" yaml:"role"` // controlplane or worker
+	Pool       string    `json:"pool" yaml:"pool"`
+	Size       string    `json:"size,omitempty" yaml:"size,omitempty"`
+	State      string    `json:"state" yaml:"state"`
+	PublicIPs  []string  `json:"publicIPs,omitempty" yaml:"publicIPs,omitempty"` // IPv4 and IPv6 addresses reachable from the internet
+	PrivateIPs []string  `json:"privateIPs,omitempty" yaml:"privateIPs,omitempty"`
+	CreatedAt  time.Time `json:"createdAt,omitempty" yaml:"createdAt,omitempty"`
+	// ConfigInjected is set when the machine config was passed as user data
+	ConfigInjected bool `json:"configInjected" yaml:"configInjected"`
+
+	// TalosVersion and KubernetesReady are reported by the node itself and are
+	// empty unless queried through the Talos API. KubernetesReady is the
+	// status of the Node's Ready condition: True, False or Unknown.
+	TalosVersion    string `json:"talosVersion,omitempty" yaml:"talosVersion,omitempty"`
+	KubernetesReady string `json:"kubernetesReady,omitempty" yaml:"kubernetesReady,omitempty"`
+}
+
+// Age returns how long ago the node was created, or 0 if unknown
+func (n NodeStatus) Age(now time.Time) time.Duration {
+	if n.CreatedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(n.CreatedAt)
+}
+
+// Node roles
+const (
+	RoleControlPlane = "controlplane"
+	RoleWorker       = "worker"
+)
+
+// PublicAddresses returns the addresses ingress traffic reaches the cluster on,
+// e.g. for DNS records: the load balancer's, the shared ingress address, or
+// otherwise every node's public addresses
+func (s ClusterStatus) PublicAddresses() []string {
+	if len(s.Endpoints.LoadBalancer) > 0 {
+		return s.Endpoints.LoadBalancer
+	}
+	if s.Endpoints.IngressIP != "" {
+		return []string{s.Endpoints.IngressIP}
+	}
+
+	var addresses []string
+	for _, node := range s.Nodes {
+		addresses = append(addresses, node.PublicIPs...)
+	}
+	return addresses
 }
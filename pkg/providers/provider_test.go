@@ -50,21 +50,138 @@ func TestClusterSpecValidation(t *testing.T) {
 		{
 			name: "valid spec",
 			spec: ClusterSpec{
-				NodeCount:    3,
-				NodeSize:     "g6-standard-2",
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
 				TalosVersion: "v1.6.0",
 			},
 			shouldError: false,
 		},
 		{
-			name: "invalid node count",
+			name: "no pools",
 			spec: ClusterSpec{
-				NodeCount:    0,
-				NodeSize:     "g6-standard-2",
 				TalosVersion: "v1.6.0",
 			},
 			shouldError: true,
 		},
+		{
+			name: "valid cluster networks",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.6.0",
+				PodCIDR:      "10.128.0.0/16",
+				ServiceCIDR:  "10.129.0.0/20",
+			},
+			shouldError: false,
+		},
+		{
+			name: "no control plane pool",
+			spec: ClusterSpec{
+				Pools:        []NodePool{{Name: "workers", Role: RoleWorker, Count: 3, Size: "g6-standard-2"}},
+				TalosVersion: "v1.6.0",
+			},
+			shouldError: true,
+		},
+		{
+			name: "duplicate pool names",
+			spec: ClusterSpec{
+				Pools: []NodePool{
+					{Name: "nodes", Role: RoleControlPlane, Count: 1, Size: "g6-standard-2"},
+					{Name: "nodes", Role: RoleWorker, Count: 2, Size: "g6-standard-2"},
+				},
+				TalosVersion: "v1.6.0",
+			},
+			shouldError: true,
+		},
+		{
+			name: "invalid pod CIDR",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.6.0",
+				PodCIDR:      "10.128.0.0",
+			},
+			shouldError: true,
+		},
+		{
+			name: "extensions with schematic",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.7.0",
+				Extensions:   []string{"qemu-guest-agent"},
+				SchematicID:  "ce4c980550dd2ab1b17bbf2b08801c7eb59418eafe8f279833297925d67c7515",
+			},
+			shouldError: false,
+		},
+		{
+			name: "extensions without schematic",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.7.0",
+				KernelArgs:   []string{"net.ifnames=0"},
+			},
+			shouldError: true,
+		},
+		{
+			name: "restricted firewall",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.6.0",
+				Firewall:     FirewallSpec{AdminCIDRs: []string{"203.0.113.0/24", "2001:db8::/32"}, IngressPorts: []int{443}},
+			},
+			shouldError: false,
+		},
+		{
+			name: "invalid firewall CIDR",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.6.0",
+				Firewall:     FirewallSpec{AdminCIDRs: []string{"203.0.113.1"}},
+			},
+			shouldError: true,
+		},
+		{
+			name: "VPC",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.6.0",
+				VPC:          VPCSpec{Enabled: true, Subnet: "172.30.0.0/24"},
+			},
+			shouldError: false,
+		},
+		{
+			name: "public VPC subnet",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.6.0",
+				VPC:          VPCSpec{Enabled: true, Subnet: "203.0.113.0/24"},
+			},
+			shouldError: true,
+		},
+		{
+			name: "invalid ingress port",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.6.0",
+				Firewall:     FirewallSpec{IngressPorts: []int{0}},
+			},
+			shouldError: true,
+		},
+		{
+			name: "strict placement",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 3),
+				TalosVersion: "v1.6.0",
+				Placement:    PlacementStrict,
+			},
+			shouldError: false,
+		},
+		{
+			name: "invalid placement",
+			spec: ClusterSpec{
+				Pools:        DefaultNodePools(3, "g6-standard-2", 3),
+				TalosVersion: "v1.6.0",
+				Placement:    "spread",
+			},
+			shouldError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -95,14 +212,16 @@ func TestClusterScaling(t *testing.T) {
 				},
 			},
 			initialSpec: ClusterSpec{
-				NodeCount:    3,
-				NodeSize:     "g6-standard-2",
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
 				TalosVersion: "v1.6.0",
+				Image:        "private/123",
 			},
 			targetSpec: ClusterSpec{
-				NodeCount:    5,
-				NodeSize:     "g6-standard-2",
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(5, "g6-standard-2", 1),
 				TalosVersion: "v1.6.0",
+				Image:        "private/123",
 			},
 			shouldError: false,
 		},
@@ -116,14 +235,40 @@ func TestClusterScaling(t *testing.T) {
 				},
 			},
 			initialSpec: ClusterSpec{
-				NodeCount:    5,
-				NodeSize:     "g6-standard-2",
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(5, "g6-standard-2", 1),
 				TalosVersion: "v1.6.0",
+				Image:        "private/123",
 			},
 			targetSpec: ClusterSpec{
-				NodeCount:    3,
-				NodeSize:     "g6-standard-2",
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
 				TalosVersion: "v1.6.0",
+				Image:        "private/123",
+			},
+			shouldError: false,
+		},
+		{
+			name: "add a worker pool",
+			provider: Provider{
+				Name:   "linode",
+				Region: "us-east",
+				Credentials: map[string]string{
+					"api_token": "test-token",
+				},
+			},
+			initialSpec: ClusterSpec{
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
+				TalosVersion: "v1.6.0",
+				Image:        "private/123",
+			},
+			targetSpec: ClusterSpec{
+				Name: "test-cluster",
+				Pools: append(DefaultNodePools(3, "g6-standard-2", 1),
+					NodePool{Name: "gpu", Role: RoleWorker, Count: 1, Size: "g1-gpu-rtx6000-1", Taints: []string{"nvidia.com/gpu=true:NoSchedule"}}),
+				TalosVersion: "v1.6.0",
+				Image:        "private/123",
 			},
 			shouldError: false,
 		},
@@ -137,14 +282,16 @@ func TestClusterScaling(t *testing.T) {
 				},
 			},
 			initialSpec: ClusterSpec{
-				NodeCount:    3,
-				NodeSize:     "g6-standard-2",
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
 				TalosVersion: "v1.6.0",
+				Image:        "private/123",
 			},
 			targetSpec: ClusterSpec{
-				NodeCount:    3,
-				NodeSize:     "g6-standard-4",
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(3, "g6-standard-4", 1),
 				TalosVersion: "v1.6.0",
+				Image:        "private/123",
 			},
 			shouldError: false,
 		},
@@ -174,8 +321,12 @@ func TestClusterScaling(t *testing.T) {
 				t.Fatalf("Failed to get cluster status: %v", err)
 			}
 
-			if status.NodeCount != tt.targetSpec.NodeCount {
-				t.Errorf("Expected node count %d, got %d", tt.targetSpec.NodeCount, status.NodeCount)
+			expected := 0
+			for _, pool := range tt.targetSpec.Pools {
+				expected += pool.Count
+			}
+			if status.NodeCount != expected {
+				t.Errorf("Expected node count %d, got %d", expected, status.NodeCount)
 			}
 		})
 	}
@@ -199,9 +350,10 @@ func TestClusterLifecycle(t *testing.T) {
 				},
 			},
 			spec: ClusterSpec{
-				NodeCount:    3,
-				NodeSize:     "g6-standard-2",
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
 				TalosVersion: "v1.6.0",
+				Image:        "private/123",
 			},
 			operations:  []string{"create", "scale", "delete"},
 			shouldError: false,
@@ -216,9 +368,10 @@ func TestClusterLifecycle(t *testing.T) {
 				},
 			},
 			spec: ClusterSpec{
-				NodeCount:    3,
-				NodeSize:     "g6-standard-2",
+				Name:         "test-cluster",
+				Pools:        DefaultNodePools(3, "g6-standard-2", 1),
 				TalosVersion: "v1.6.0",
+				Image:        "private/123",
 			},
 			operations:  []string{"create"},
 			shouldError: true,
@@ -241,7 +394,7 @@ func TestClusterLifecycle(t *testing.T) {
 					err = provider.CreateCluster(tt.spec)
 				case "scale":
 					newSpec := tt.spec
-					newSpec.NodeCount = 5
+					newSpec.Pools = DefaultNodePools(5, "g6-standard-2", 1)
 					err = provider.UpdateCluster(newSpec)
 				case "delete":
 					err = provider.DeleteCluster("test-cluster")
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retrier retries provider calls that failed with a transient error. It waits
+// as long as the API asks through Retry-After or rate-limit headers, and
+// otherwise backs off exponentially with jitter.
+type Retrier struct {
+	// MaxAttempts bounds the calls made, including the first
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles with every
+	// attempt up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Logf receives a message for every retry
+	Logf func(format string, args ...any)
+
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(d time.Duration) time.Duration
+}
+
+// NewRetrier creates a retrier making up to 5 attempts, logging retries
+func NewRetrier() *Retrier {
+	return &Retrier{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Logf:        log.Printf,
+		sleep:       sleepContext,
+		jitter:      halfJitter,
+	}
+}
+
+// Do calls an idempotent call until it succeeds, fails with an error that is
+// not transient or runs out of attempts
+func (r *Retrier) Do(ctx context.Context, op string, call func() error) error {
+	return r.do(ctx, op, call, IsTransient)
+}
+
+// DoOnce runs a call that must not be repeated once the API accepted it, such
+// as creating an instance. It is only retried when rate limiting rejected it.
+func (r *Retrier) DoOnce(ctx context.Context, op string, call func() error) error {
+	return r.do(ctx, op, call, IsThrottled)
+}
+
+func (r *Retrier) do(ctx context.Context, op string, call func() error, retryable func(error) bool) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if attempt >= r.MaxAttempts {
+			return fmt.Errorf("%w (gave up after %d attempts)", err, attempt)
+		}
+		// Retrying sooner than the API asked would only be rejected again
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > r.MaxDelay {
+			return fmt.Errorf("%w (asked to retry in %s, longer than the %s limit)", err, apiErr.RetryAfter.Round(time.Second), r.MaxDelay)
+		}
+
+		delay := r.delay(attempt, err)
+		r.Logf("%s failed (attempt %d/%d), retrying in %s: %v", op, attempt, r.MaxAttempts, delay.Round(time.Millisecond), err)
+		if err := r.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// delay returns the wait before retrying attempt: the time the API asked
+// for, which do keeps within MaxDelay, or the jittered exponential backoff
+func (r *Retrier) delay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+
+	backoff := r.BaseDelay << (attempt - 1)
+	if backoff > r.MaxDelay || backoff <= 0 {
+		backoff = r.MaxDelay
+	}
+	return r.jitter(backoff)
+}
+
+// IsThrottled reports whether a call was rejected by rate limiting
+func IsThrottled(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter returns how long a response asks clients to wait: the
+// Retry-After header in seconds or as a date, or, once the rate limit is used
+// up, the time until X-RateLimit-Reset
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(value); err == nil && at.After(now) {
+			return at.Sub(now)
+		}
+	}
+
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if at := time.Unix(reset, 0); at.After(now) {
+				return at.Sub(now)
+			}
+		}
+	}
+
+	return 0
+}
+
+// halfJitter spreads retries of concurrent clients over [d/2, d]
+func halfJitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
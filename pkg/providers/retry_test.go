@@ -0,0 +1,199 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+// throttlingServer answers the first failures requests to /v4/regions with
+// status and headers, then succeeds
+func throttlingServer(t *testing.T, failures int, status int, headers map[string]string) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if int(atomic.AddInt32(&requests, 1)) <= failures {
+			for key, value := range headers {
+				w.Header().Set(key, value)
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(linodego.APIError{Errors: []linodego.APIErrorReason{{Reason: http.StatusText(status)}}})
+			return
+		}
+		json.NewEncoder(w).Encode(linodego.RegionsPagedResponse{
+			PageOptions: &linodego.PageOptions{Page: 1, Pages: 1, Results: 1},
+			Data:        []linodego.Region{{ID: "us-east"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func testRetrier() (*Retrier, *[]time.Duration, *[]string) {
+	var delays []time.Duration
+	var logs []string
+
+	r := NewRetrier()
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	r.jitter = func(d time.Duration) time.Duration { return d }
+	r.Logf = func(format string, args ...any) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+	return r, &delays, &logs
+}
+
+func testProvider(server *httptest.Server, retry *Retrier) *LinodeProvider {
+	client := linodego.NewClient(http.DefaultClient)
+	client.SetBaseURL(server.URL)
+	client.SetRetryCount(0)
+
+	return &LinodeProvider{
+		config:  Provider{Name: "linode", Region: "us-east"},
+		client:  client,
+		context: context.Background(),
+		retry:   retry,
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	server, requests := throttlingServer(t, 2, http.StatusTooManyRequests, map[string]string{"Retry-After": "7"})
+	retry, delays, logs := testRetrier()
+
+	if err := testProvider(server, retry).validateRegion(); err != nil {
+		t.Fatalf("validateRegion() error = %v, expected nil", err)
+	}
+
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", *requests)
+	}
+	if fmt.Sprint(*delays) != fmt.Sprint([]time.Duration{7 * time.Second, 7 * time.Second}) {
+		t.Errorf("Expected to wait 7s twice, got %v", *delays)
+	}
+	if len(*logs) != 2 || !strings.Contains((*logs)[1], "attempt 2/5") {
+		t.Errorf("Expected a log line with the attempt count for each retry, got %q", *logs)
+	}
+}
+
+func TestRetryRefusesLongRetryAfter(t *testing.T) {
+	server, requests := throttlingServer(t, 2, http.StatusTooManyRequests, map[string]string{"Retry-After": "3600"})
+	retry, delays, _ := testRetrier()
+
+	err := testProvider(server, retry).validateRegion()
+	if !errors.Is(err, ErrTransient) {
+		t.Fatalf("validateRegion() error = %v, expected ErrTransient", err)
+	}
+	if *requests != 1 || len(*delays) != 0 {
+		t.Errorf("Expected a single request without waiting, got %d with delays %v", *requests, *delays)
+	}
+}
+
+func TestRetryBacksOffExponentially(t *testing.T) {
+	server, requests := throttlingServer(t, 3, http.StatusServiceUnavailable, nil)
+	retry, delays, _ := testRetrier()
+	retry.BaseDelay = 100 * time.Millisecond
+
+	if err := testProvider(server, retry).validateRegion(); err != nil {
+		t.Fatalf("validateRegion() error = %v, expected nil", err)
+	}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	if *requests != 4 || fmt.Sprint(*delays) != fmt.Sprint(expected) {
+		t.Errorf("Expected 4 requests with delays %v, got %d with %v", expected, *requests, *delays)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	server, requests := throttlingServer(t, 10, http.StatusBadGateway, nil)
+	retry, _, _ := testRetrier()
+	retry.MaxAttempts = 3
+
+	err := testProvider(server, retry).validateRegion()
+	if !IsTransient(err) {
+		t.Fatalf("validateRegion() error = %v, expected a transient error", err)
+	}
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", *requests)
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("Expected the attempt count in %q", err)
+	}
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	server, requests := throttlingServer(t, 1, http.StatusUnauthorized, nil)
+	retry, _, _ := testRetrier()
+
+	err := testProvider(server, retry).validateRegion()
+	if !errors.Is(err, ErrUnauthorized) || *requests != 1 {
+		t.Errorf("Expected one request failing with ErrUnauthorized, got %d requests and %v", *requests, err)
+	}
+}
+
+func TestRetryOnce(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{name: "throttled", err: &APIError{StatusCode: http.StatusTooManyRequests, Kind: ErrTransient}, calls: 2},
+		{name: "server error", err: &APIError{StatusCode: http.StatusInternalServerError, Kind: ErrTransient}, calls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry, _, _ := testRetrier()
+
+			calls := 0
+			retry.DoOnce(context.Background(), "create instance", func() error {
+				calls++
+				if calls == 1 {
+					return tt.err
+				}
+				return nil
+			})
+			if calls != tt.calls {
+				t.Errorf("Expected %d calls, got %d", tt.calls, calls)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{name: "seconds", headers: map[string]string{"Retry-After": "30"}, want: 30 * time.Second},
+		{name: "date", headers: map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)}, want: time.Minute},
+		{name: "rate limit used up", headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(now.Add(12 * time.Second).Unix())}, want: 12 * time.Second},
+		{name: "rate limit left", headers: map[string]string{"X-RateLimit-Remaining": "10", "X-RateLimit-Reset": fmt.Sprint(now.Add(12 * time.Second).Unix())}, want: 0},
+		{name: "none", headers: nil, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.headers {
+				header.Set(key, value)
+			}
+			if got := retryAfter(header, now); got != tt.want {
+				t.Errorf("retryAfter() = %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"fmt"
+	"net/netip"
 )
 
 // Provider represents a cloud provider (Linode, Hetzner etc)
@@ -23,20 +24,110 @@ func (p *Provider) Validate() error {
 
 // ClusterSpec defines the desired cluster state
 type ClusterSpec struct {
-	NodeCount    int
-	NodeSize     string
+	Name         string
 	TalosVersion string
+	// PodCIDR and ServiceCIDR are the cluster networks; empty uses the Talos defaults
+	PodCIDR     string
+	ServiceCIDR string
+	// Pools are the node pools of the cluster
+	Pools []NodePool
+	// Image is the provider image to boot, e.g. an uploaded Talos image
+	Image string
+	// Extensions and KernelArgs customize the Talos image. SchematicID is their
+	// Image Factory schematic; providers boot an image built from it when no
+	// Image is given.
+	Extensions   []string
+	KernelArgs   []string
+	SchematicID  string
+	ImageFactory string
+	// Firewall restricts inbound traffic to the nodes
+	Firewall FirewallSpec
+	// VPC is the private network the nodes talk over
+	VPC VPCSpec
+	// LoadBalancer fronts the Kubernetes API and HTTP/HTTPS ingress with a
+	// provider load balancer, whose address becomes the cluster endpoint
+	LoadBalancer bool
+	// IngressIP allocates an extra public IPv4 address shared by the ingress
+	// nodes, which fails over between them without DNS changes
+	IngressIP bool
+	// Placement spreads the control plane nodes across hosts: strict,
+	// flexible or none
+	Placement string
 }
 
+// NodeCount returns the number of nodes across all pools
+func (s *ClusterSpec) NodeCount() int {
+	count := 0
+	for _, pool := range s.Pools {
+		count += pool.Count
+	}
+	return count
+}
+
+// Pool returns the named pool
+func (s *ClusterSpec) Pool(name string) (*NodePool, bool) {
+	for i := range s.Pools {
+		if s.Pools[i].Name == name {
+			return &s.Pools[i], true
+		}
+	}
+	return nil, false
+}
+
+// Validate checks the spec; errors wrap ErrInvalidSpec
 func (s *ClusterSpec) Validate() error {
-	if s.NodeCount < 1 {
-		return fmt.Errorf("node count must be at least 1")
+	if err := s.validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSpec, err)
 	}
-	if s.NodeSize == "" {
-		return fmt.Errorf("node size is required")
+	return nil
+}
+
+func (s *ClusterSpec) validate() error {
+	if len(s.Pools) == 0 {
+		return fmt.Errorf("at least one node pool is required")
 	}
 	if s.TalosVersion == "" {
 		return fmt.Errorf("talos version is required")
 	}
+
+	names := map[string]bool{}
+	controlPlanes := 0
+	for i := range s.Pools {
+		pool := &s.Pools[i]
+		if err := pool.Validate(); err != nil {
+			return err
+		}
+		if names[pool.Name] {
+			return fmt.Errorf("duplicate pool name %s", pool.Name)
+		}
+		names[pool.Name] = true
+		if pool.Role == RoleControlPlane {
+			controlPlanes += pool.Count
+		}
+	}
+	if controlPlanes == 0 {
+		return fmt.Errorf("a control plane pool is required")
+	}
+
+	if (len(s.Extensions) > 0 || len(s.KernelArgs) > 0) && s.SchematicID == "" {
+		return fmt.Errorf("schematic ID is required for system extensions and kernel args")
+	}
+	if err := s.Firewall.Validate(); err != nil {
+		return err
+	}
+	if err := s.VPC.Validate(); err != nil {
+		return err
+	}
+	if err := validatePlacement(s.Placement); err != nil {
+		return err
+	}
+	for _, cidr := range []string{s.PodCIDR, s.ServiceCIDR} {
+		if cidr == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+	}
 	return nil
 }
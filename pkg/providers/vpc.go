@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// VPC subnets of new clusters are allocated from DefaultVPCRange, one block of
+// DefaultVPCPrefixLen bits per cluster
+const (
+	DefaultVPCRange     = "172.30.0.0/16"
+	DefaultVPCPrefixLen = 24
+)
+
+// VPCSpec puts the nodes on a private network. Nodes keep a public IPv4
+// address through 1:1 NAT.
+type VPCSpec struct {
+	Enabled bool
+	// Name is the VPC to join, created when missing; empty uses a VPC of the
+	// cluster's own
+	Name string
+	// Subnet is the IPv4 range of the cluster's subnet in the VPC
+	Subnet string
+}
+
+// Validate checks the subnet
+func (v *VPCSpec) Validate() error {
+	if !v.Enabled {
+		return nil
+	}
+	prefix, err := netip.ParsePrefix(v.Subnet)
+	if err != nil {
+		return fmt.Errorf("invalid VPC subnet %q: %w", v.Subnet, err)
+	}
+	if !prefix.Addr().Is4() || !prefix.Addr().IsPrivate() {
+		return fmt.Errorf("VPC subnet %s must be a private IPv4 range", v.Subnet)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Store persists the tool's view of managed clusters as a JSON document on disk
+type Store struct {
+	path string
+}
+
+// NewStore creates a store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath returns the state file location, honouring TALOS_AUTOEXTENDER_STATE
+func DefaultPath() string {
+	if path := os.Getenv("TALOS_AUTOEXTENDER_STATE"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "talos-autoextender-state.json"
+	}
+
+	return filepath.Join(home, ".talos-autoextender", "state.json")
+}
+
+// Path returns the file backing the store
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load reads the state file, returning an empty state if it does not exist yet
+func (s *Store) Load() (*State, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %v", s.path, err)
+	}
+
+	st := NewState()
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", s.path, err)
+	}
+
+	if st.Clusters == nil {
+		st.Clusters = make(map[string]*Cluster)
+	}
+
+	return st, nil
+}
+
+// Save writes the state file atomically. The file may contain secrets so it is
+// only readable by the current user.
+func (s *Store) Save(st *State) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set state file permissions: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %v", err)
+	}
+
+	return nil
+}
+
+// Update loads the state, applies fn and saves the result if fn succeeds
+func (s *Store) Update(fn func(*State) error) error {
+	st, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(st); err != nil {
+		return err
+	}
+
+	return s.Save(st)
+}
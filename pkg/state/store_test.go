@@ -0,0 +1,136 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "state.json"))
+
+	st, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+
+	if len(st.Clusters) != 0 {
+		t.Errorf("Expected empty state, got %d clusters", len(st.Clusters))
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	store := NewStore(path)
+
+	st := NewState()
+	st.PutCluster(&Cluster{
+		Name:          "cloud1",
+		Provider:      "linode",
+		Region:        "us-east",
+		Endpoint:      "10.0.0.1:51820",
+		TalosEndpoint: "10.0.0.1:50000",
+		ConnectedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Pools: []NodePool{
+			{Name: "controlplane", Role: "controlplane", Count: 1, Size: "g6-standard-2"},
+			{Name: "gpu", Role: "worker", Count: 2, Size: "g1-gpu-rtx6000-1", Taints: []string{"nvidia.com/gpu=true:NoSchedule"}},
+		},
+		Firewall: Firewall{AdminCIDRs: []string{"203.0.113.0/24"}, IngressPorts: []int{443}},
+	})
+	st.Budget = &Budget{Monthly: 150, Downsize: true}
+
+	if err := store.Save(st); err != nil {
+		t.Fatalf("Save() error = %v, expected nil", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected state file to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected state file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+
+	cluster, ok := loaded.Cluster("cloud1")
+	if !ok {
+		t.Fatal("Expected cluster cloud1 to be loaded")
+	}
+	if cluster.Endpoint != "10.0.0.1:51820" || cluster.TalosEndpoint != "10.0.0.1:50000" {
+		t.Errorf("Expected endpoints '10.0.0.1:51820' and '10.0.0.1:50000', got '%s' and '%s'", cluster.Endpoint, cluster.TalosEndpoint)
+	}
+	if !cluster.ConnectedAt.Equal(st.Clusters["cloud1"].ConnectedAt) {
+		t.Errorf("Expected ConnectedAt to round-trip, got %v", cluster.ConnectedAt)
+	}
+	if gpu, ok := cluster.Pool("gpu"); !ok || gpu.Count != 2 || len(gpu.Taints) != 1 {
+		t.Errorf("Expected the gpu pool to round-trip, got %+v", gpu)
+	}
+	if len(cluster.Firewall.AdminCIDRs) != 1 || len(cluster.Firewall.IngressPorts) != 1 {
+		t.Errorf("Expected the firewall to round-trip, got %+v", cluster.Firewall)
+	}
+	if loaded.Budget == nil || *loaded.Budget != *st.Budget {
+		t.Errorf("Expected the budget to round-trip, got %+v", loaded.Budget)
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewStore(path).Load(); err == nil {
+		t.Error("Expected error loading invalid state file")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "state.json"))
+
+	err := store.Update(func(st *State) error {
+		st.PutCluster(&Cluster{Name: "cloud1", Endpoint: "10.0.0.1:50000"})
+		st.PutCluster(&Cluster{Name: "cloud2"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v, expected nil", err)
+	}
+
+	st, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, expected nil", err)
+	}
+
+	names := st.ClusterNames()
+	if len(names) != 2 || names[0] != "cloud1" || names[1] != "cloud2" {
+		t.Errorf("Expected sorted names [cloud1 cloud2], got %v", names)
+	}
+
+	connected := st.ConnectedClusters()
+	if len(connected) != 1 || connected[0].Name != "cloud1" {
+		t.Errorf("Expected only cloud1 to be connected, got %v", connected)
+	}
+
+	st.Clusters["cloud1"].DeletedAt = time.Now()
+	if connected := st.ConnectedClusters(); len(connected) != 0 || !st.Clusters["cloud1"].Deleted() {
+		t.Errorf("Expected a deleted cluster not to be connected, got %v", connected)
+	}
+
+	st.RemoveCluster("cloud1")
+	if _, ok := st.Cluster("cloud1"); ok {
+		t.Error("Expected cloud1 to be removed")
+	}
+}
+
+func TestDefaultPathFromEnvironment(t *testing.T) {
+	t.Setenv("TALOS_AUTOEXTENDER_STATE", "/tmp/custom-state.json")
+
+	if path := DefaultPath(); path != "/tmp/custom-state.json" {
+		t.Errorf("Expected path from environment, got '%s'", path)
+	}
+}
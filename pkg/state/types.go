@@ -0,0 +1,174 @@
+package state
+
+import (
+	"sort"
+	"time"
+)
+
+// State is the persisted record of every cloud cluster managed by the tool
+type State struct {
+	Clusters map[string]*Cluster `json:"clusters"`
+	// HomeNetworks are the pod, service and node ranges of the home cluster
+	HomeNetworks Networks `json:"homeNetworks"`
+	// Supernet is the range new cloud cluster pod and service CIDRs are allocated from
+	Supernet string `json:"supernet,omitempty"`
+	// Budget caps the monthly cost of all cloud clusters; nil means no cap
+	Budget *Budget `json:"budget,omitempty"`
+}
+
+// Budget is the most the cloud clusters together may cost per month
+type Budget struct {
+	// Monthly is in US dollars
+	Monthly float64 `json:"monthly"`
+	// Downsize provisions cheaper instance types instead of refusing
+	// operations that would exceed the budget
+	Downsize bool `json:"downsize,omitempty"`
+}
+
+// Networks are the address ranges used by a cluster
+type Networks struct {
+	Pods     []string `json:"pods,omitempty"`
+	Services []string `json:"services,omitempty"`
+	Nodes    []string `json:"nodes,omitempty"`
+}
+
+// IsZero reports whether no ranges are recorded
+func (n Networks) IsZero() bool {
+	return len(n.Pods) == 0 && len(n.Services) == 0 && len(n.Nodes) == 0
+}
+
+// Cluster records what the tool knows about a single cloud cluster
+type Cluster struct {
+	Name         string `json:"name"`
+	Provider     string `json:"provider,omitempty"`
+	Region       string `json:"region,omitempty"`
+	Transport    string `json:"transport,omitempty"`
+	HeadscaleURL string `json:"headscaleURL,omitempty"`
+	HomeEndpoint string `json:"homeEndpoint,omitempty"`
+	// Endpoint is the selected KubeSpan WireGuard peer endpoint among
+	// Endpoints, and TalosEndpoint the Talos API address on the same host
+	// that peer statuses are queried through
+	Endpoint      string    `json:"endpoint,omitempty"`
+	Endpoints     []string  `json:"endpoints,omitempty"`
+	TalosEndpoint string    `json:"talosEndpoint,omitempty"`
+	KubeSpanMTU   int       `json:"kubespanMTU,omitempty"`
+	Networks      Networks  `json:"networks"`
+	ConnectedAt   time.Time `json:"connectedAt,omitempty"`
+
+	// Pools and Image are the provisioning parameters, used to scale the
+	// cluster and to recreate it from a backup
+	Pools []NodePool `json:"pools,omitempty"`
+	Image string     `json:"image,omitempty"`
+	// Extensions and KernelArgs customize the Talos image through the Image
+	// Factory schematic Schematic, which upgrades keep using
+	Extensions   []string `json:"extensions,omitempty"`
+	KernelArgs   []string `json:"kernelArgs,omitempty"`
+	Schematic    string   `json:"schematic,omitempty"`
+	ImageFactory string   `json:"imageFactory,omitempty"`
+	Firewall     Firewall `json:"firewall"`
+	VPC          VPC      `json:"vpc"`
+	LoadBalancer bool     `json:"loadBalancer,omitempty"`
+	IngressIP    bool     `json:"ingressIP,omitempty"`
+	// Placement is the anti-affinity policy of the control plane nodes
+	Placement string `json:"placement,omitempty"`
+
+	TalosVersion      string `json:"talosVersion,omitempty"`
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	// ControlPlaneEndpoint is the Kubernetes API URL of the cluster
+	ControlPlaneEndpoint string `json:"controlPlaneEndpoint,omitempty"`
+	// ControlPlanes are the addresses of the control plane nodes
+	ControlPlanes []string `json:"controlPlanes,omitempty"`
+	// Secrets is the Talos secrets bundle (YAML) the machine configs and
+	// credentials are generated from
+	Secrets        string    `json:"secrets,omitempty"`
+	BootstrappedAt time.Time `json:"bootstrappedAt,omitempty"`
+	// DeletedAt is set when the cluster's resources were deleted; the record
+	// is kept so the cluster can be restored from a backup
+	DeletedAt time.Time `json:"deletedAt,omitempty"`
+}
+
+// Deleted reports whether the cluster's resources were deleted
+func (c *Cluster) Deleted() bool {
+	return !c.DeletedAt.IsZero()
+}
+
+// NodePool records a named group of nodes sharing a role and instance type
+type NodePool struct {
+	Name    string            `json:"name"`
+	Role    string            `json:"role"`
+	Count   int               `json:"count"`
+	Size    string            `json:"size"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Taints  []string          `json:"taints,omitempty"`
+	Patches []string          `json:"patches,omitempty"`
+}
+
+// Firewall records which addresses may reach the cluster nodes
+type Firewall struct {
+	Disabled     bool     `json:"disabled,omitempty"`
+	AdminCIDRs   []string `json:"adminCIDRs,omitempty"`
+	IngressPorts []int    `json:"ingressPorts,omitempty"`
+	IngressCIDRs []string `json:"ingressCIDRs,omitempty"`
+}
+
+// VPC records the private network of the cluster nodes
+type VPC struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Subnet  string `json:"subnet,omitempty"`
+}
+
+// Pool returns the named node pool
+func (c *Cluster) Pool(name string) (*NodePool, bool) {
+	for i := range c.Pools {
+		if c.Pools[i].Name == name {
+			return &c.Pools[i], true
+		}
+	}
+	return nil, false
+}
+
+// NewState creates an empty state
+func NewState() *State {
+	return &State{
+		Clusters: make(map[string]*Cluster),
+	}
+}
+
+// Cluster returns the named cluster record
+func (s *State) Cluster(name string) (*Cluster, bool) {
+	cluster, ok := s.Clusters[name]
+	return cluster, ok
+}
+
+// PutCluster adds or replaces a cluster record
+func (s *State) PutCluster(cluster *Cluster) {
+	s.Clusters[cluster.Name] = cluster
+}
+
+// RemoveCluster deletes a cluster record
+func (s *State) RemoveCluster(name string) {
+	delete(s.Clusters, name)
+}
+
+// ClusterNames returns the names of all recorded clusters in sorted order
+func (s *State) ClusterNames() []string {
+	names := make([]string, 0, len(s.Clusters))
+	for name := range s.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConnectedClusters returns the recorded clusters that have been connected to
+// the home cluster and not deleted since
+func (s *State) ConnectedClusters() []*Cluster {
+	var clusters []*Cluster
+	for _, name := range s.ClusterNames() {
+		if cluster := s.Clusters[name]; !cluster.Deleted() && (!cluster.ConnectedAt.IsZero() || cluster.Endpoint != "") {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
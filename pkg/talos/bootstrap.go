@@ -0,0 +1,328 @@
+package talos
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+)
+
+// Phase is a step of the cluster bootstrap
+type Phase string
+
+const (
+	PhaseWaitAPI     Phase = "wait-api"
+	PhaseApplyConfig Phase = "apply-config"
+	PhaseBootstrap   Phase = "bootstrap"
+	PhaseEtcd        Phase = "etcd"
+	PhaseKubernetes  Phase = "kubernetes"
+)
+
+// Node is a freshly provisioned node to bootstrap
+type Node struct {
+	Name string
+	// Address is the IP the Talos API is reached on
+	Address      string
+	ControlPlane bool
+	// ConfigInjected is set when the machine config was delivered as user data,
+	// so the node boots configured instead of into maintenance mode
+	ConfigInjected bool
+	// Config overrides the machine config of the node's role, e.g. with one
+	// customized for its node pool
+	Config []byte
+}
+
+// HealthCheck returns nil once a component is healthy
+type HealthCheck func(ctx context.Context) error
+
+// Bootstrapper takes provisioned nodes to a running Kubernetes cluster: it waits
+// for the Talos API, applies machine configs to nodes in maintenance mode,
+// bootstraps etcd on exactly one control plane node and waits for etcd and the
+// Kubernetes API to become healthy
+type Bootstrapper struct {
+	connect         Connector
+	connectInsecure Connector
+	kubernetes      HealthCheck
+
+	ControlPlaneConfig []byte
+	WorkerConfig       []byte
+	// RecoverFrom is the path of an etcd snapshot to bootstrap etcd from,
+	// restoring the cluster's data
+	RecoverFrom string
+	// Timeout bounds each waiting phase
+	Timeout      time.Duration
+	PollInterval time.Duration
+	// Report receives progress messages for each phase
+	Report func(phase Phase, message string)
+
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// NewBootstrapper creates a bootstrapper. connect must authenticate with the
+// cluster's talosconfig; connectInsecure is used for nodes in maintenance mode.
+func NewBootstrapper(connect, connectInsecure Connector, kubernetes HealthCheck) *Bootstrapper {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	return &Bootstrapper{
+		connect:         connect,
+		connectInsecure: connectInsecure,
+		kubernetes:      kubernetes,
+		Timeout:         10 * time.Minute,
+		PollInterval:    5 * time.Second,
+		Report:          func(Phase, string) {},
+		dial:            dialer.DialContext,
+	}
+}
+
+// Run bootstraps the cluster formed by nodes
+func (b *Bootstrapper) Run(ctx context.Context, nodes []Node) error {
+	var controlPlanes []Node
+	for _, node := range nodes {
+		if node.Address == "" {
+			return fmt.Errorf("node %s has no address", node.Name)
+		}
+		if node.ControlPlane {
+			controlPlanes = append(controlPlanes, node)
+		}
+	}
+	if len(controlPlanes) == 0 {
+		return fmt.Errorf("cluster has no control plane nodes")
+	}
+
+	for _, node := range nodes {
+		b.Report(PhaseWaitAPI, fmt.Sprintf("waiting for the Talos API on %s (%s)", node.Name, node.Address))
+		if err := b.waitForAPI(ctx, node); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range nodes {
+		if node.ConfigInjected {
+			b.Report(PhaseApplyConfig, fmt.Sprintf("%s was configured through user data", node.Name))
+			continue
+		}
+		if err := b.applyConfig(ctx, node); err != nil {
+			return err
+		}
+	}
+
+	// etcd must be bootstrapped on one node only; the others join it
+	first := controlPlanes[0]
+	if b.RecoverFrom != "" {
+		b.Report(PhaseBootstrap, fmt.Sprintf("recovering etcd on %s from %s", first.Name, b.RecoverFrom))
+	} else {
+		b.Report(PhaseBootstrap, fmt.Sprintf("bootstrapping etcd on %s", first.Name))
+	}
+	if err := b.bootstrap(ctx, first); err != nil {
+		return err
+	}
+
+	for _, node := range controlPlanes {
+		b.Report(PhaseEtcd, fmt.Sprintf("waiting for etcd on %s", node.Name))
+		if err := b.waitForEtcd(ctx, node); err != nil {
+			return err
+		}
+	}
+
+	b.Report(PhaseKubernetes, "waiting for the Kubernetes API")
+	err := b.poll(ctx, func(ctx context.Context) (bool, error) {
+		return b.kubernetes(ctx) == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Kubernetes API did not become healthy: %v", err)
+	}
+
+	b.Report(PhaseKubernetes, "Kubernetes API is healthy")
+	return nil
+}
+
+func (b *Bootstrapper) waitForAPI(ctx context.Context, node Node) error {
+	address := net.JoinHostPort(node.Address, strconv.Itoa(constants.ApidPort))
+
+	err := b.poll(ctx, func(ctx context.Context) (bool, error) {
+		conn, err := b.dial(ctx, "tcp", address)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Talos API on %s is not reachable: %v", node.Name, err)
+	}
+
+	return nil
+}
+
+// applyConfig applies the machine config unless the node already accepts the
+// cluster's credentials, which means it is configured
+func (b *Bootstrapper) applyConfig(ctx context.Context, node Node) error {
+	if b.configured(ctx, node) {
+		b.Report(PhaseApplyConfig, fmt.Sprintf("%s is already configured", node.Name))
+		return nil
+	}
+
+	config := b.WorkerConfig
+	if node.ControlPlane {
+		config = b.ControlPlaneConfig
+	}
+	if len(node.Config) > 0 {
+		config = node.Config
+	}
+	if len(config) == 0 {
+		return fmt.Errorf("no machine config for %s", node.Name)
+	}
+
+	b.Report(PhaseApplyConfig, fmt.Sprintf("applying machine config to %s", node.Name))
+
+	c, err := b.connectInsecure(ctx, node.Address)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.ApplyConfiguration(ctx, config); err != nil {
+		return fmt.Errorf("failed to configure %s: %v", node.Name, err)
+	}
+
+	return nil
+}
+
+func (b *Bootstrapper) configured(ctx context.Context, node Node) bool {
+	c, err := b.connect(ctx, node.Address)
+	if err != nil {
+		return false
+	}
+	defer c.Close()
+
+	_, err = c.Version(ctx)
+	return err == nil
+}
+
+// bootstrap retries until the node has applied its config and accepts the call
+func (b *Bootstrapper) bootstrap(ctx context.Context, node Node) error {
+	var lastErr error
+	err := b.poll(ctx, func(ctx context.Context) (bool, error) {
+		c, err := b.connect(ctx, node.Address)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		defer c.Close()
+
+		if b.RecoverFrom != "" {
+			lastErr = b.recover(ctx, c)
+		} else {
+			lastErr = c.Bootstrap(ctx)
+		}
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap %s: %v (last error: %v)", node.Name, err, lastErr)
+	}
+
+	return nil
+}
+
+// recover uploads the snapshot, reopening it as the upload may be retried
+func (b *Bootstrapper) recover(ctx context.Context, c Client) error {
+	snapshot, err := os.Open(b.RecoverFrom)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	return c.RecoverEtcd(ctx, snapshot)
+}
+
+func (b *Bootstrapper) waitForEtcd(ctx context.Context, node Node) error {
+	var last ServiceStatus
+	err := b.poll(ctx, func(ctx context.Context) (bool, error) {
+		c, err := b.connect(ctx, node.Address)
+		if err != nil {
+			return false, nil
+		}
+		defer c.Close()
+
+		status, err := c.ServiceStatus(ctx, "etcd")
+		if err != nil {
+			return false, nil
+		}
+		last = status
+		return status.State == "Running" && status.Healthy, nil
+	})
+	if err != nil {
+		return fmt.Errorf("etcd on %s did not become healthy (state %q): %v", node.Name, last.State, err)
+	}
+
+	return nil
+}
+
+// poll calls check every PollInterval until it reports done, returns an error,
+// or Timeout elapses
+func (b *Bootstrapper) poll(ctx context.Context, check func(ctx context.Context) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, b.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(b.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		done, err := check(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// NewKubernetesHealthCheck returns a HealthCheck querying /readyz on the
+// Kubernetes API endpoint, trusting only the cluster's CA
+func NewKubernetesHealthCheck(endpoint string, caPEM []byte) (HealthCheck, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("invalid Kubernetes CA certificate")
+	}
+
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+	url := strings.TrimRight(endpoint, "/") + "/readyz"
+
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s returned %s", url, resp.Status)
+		}
+		return nil
+	}, nil
+}
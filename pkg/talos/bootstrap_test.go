@@ -0,0 +1,343 @@
+package talos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNode simulates the Talos API of one node
+type fakeNode struct {
+	mu         sync.Mutex
+	configured bool
+	applied    []byte
+	bootstraps int
+	etcd       ServiceStatus
+	kubelet    ServiceStatus
+	config     []byte
+	snapshot   []byte
+	recovered  []byte
+	version    string
+	ready      bool
+	// upgradeTo is the version the node comes back with after an upgrade
+	upgradeTo string
+	upgraded  []string
+}
+
+// fakeClient talks to a fakeNode, either authenticated or in maintenance mode
+type fakeClient struct {
+	Client
+	node     *fakeNode
+	insecure bool
+}
+
+func (f *fakeClient) Version(ctx context.Context) (string, error) {
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	if !f.insecure && !f.node.configured {
+		return "", fmt.Errorf("certificate signed by unknown authority")
+	}
+	if f.node.version != "" {
+		return f.node.version, nil
+	}
+	return "v1.6.7", nil
+}
+
+func (f *fakeClient) ApplyConfiguration(ctx context.Context, config []byte) error {
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	f.node.applied = config
+	f.node.config = config
+	f.node.configured = true
+	return nil
+}
+
+func (f *fakeClient) Bootstrap(ctx context.Context) error {
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	f.node.bootstraps++
+	return nil
+}
+
+func (f *fakeClient) ServiceStatus(ctx context.Context, id string) (ServiceStatus, error) {
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	switch id {
+	case "etcd":
+		return f.node.etcd, nil
+	case "kubelet":
+		return f.node.kubelet, nil
+	}
+	return ServiceStatus{}, fmt.Errorf("unexpected service %s", id)
+}
+
+func (f *fakeClient) Upgrade(ctx context.Context, image string, preserve bool) error {
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	f.node.upgraded = append(f.node.upgraded, image)
+	f.node.version = f.node.upgradeTo
+	return nil
+}
+
+func (f *fakeClient) NodeReady(ctx context.Context) (bool, error) {
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	return f.node.ready, nil
+}
+
+func (f *fakeClient) MachineConfig(ctx context.Context) ([]byte, error) {
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	if f.node.config == nil {
+		return nil, fmt.Errorf("machine config not found")
+	}
+	return f.node.config, nil
+}
+
+func (f *fakeClient) EtcdSnapshot(ctx context.Context) (io.ReadCloser, error) {
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	if f.node.snapshot == nil {
+		return nil, fmt.Errorf("etcd is not running")
+	}
+	return io.NopCloser(bytes.NewReader(f.node.snapshot)), nil
+}
+
+func (f *fakeClient) RecoverEtcd(ctx context.Context, snapshot io.Reader) error {
+	data, err := io.ReadAll(snapshot)
+	if err != nil {
+		return err
+	}
+
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	f.node.recovered = data
+	f.node.bootstraps++
+	return nil
+}
+
+func (f *fakeClient) Close() error {
+	return nil
+}
+
+func fakeConnectors(nodes map[string]*fakeNode) (Connector, Connector) {
+	connect := func(insecure bool) Connector {
+		return func(ctx context.Context, endpoint string) (Client, error) {
+			node, ok := nodes[endpoint]
+			if !ok {
+				return nil, fmt.Errorf("connection refused")
+			}
+			return &fakeClient{node: node, insecure: insecure}, nil
+		}
+	}
+	return connect(false), connect(true)
+}
+
+func fakeDial(nodes map[string]*fakeNode) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(address)
+		if _, ok := nodes[host]; !ok {
+			return nil, fmt.Errorf("connection refused")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+}
+
+func newTestBootstrapper(nodes map[string]*fakeNode, kubernetes HealthCheck) (*Bootstrapper, *[]Phase) {
+	connect, connectInsecure := fakeConnectors(nodes)
+	b := NewBootstrapper(connect, connectInsecure, kubernetes)
+	b.ControlPlaneConfig = []byte("controlplane")
+	b.WorkerConfig = []byte("worker")
+	b.Timeout = 100 * time.Millisecond
+	b.PollInterval = time.Millisecond
+	b.dial = fakeDial(nodes)
+
+	var phases []Phase
+	b.Report = func(phase Phase, message string) {
+		if len(phases) == 0 || phases[len(phases)-1] != phase {
+			phases = append(phases, phase)
+		}
+	}
+
+	return b, &phases
+}
+
+func healthyEtcd() ServiceStatus {
+	return ServiceStatus{ID: "etcd", State: "Running", Healthy: true}
+}
+
+func TestBootstrapperRun(t *testing.T) {
+	nodes := map[string]*fakeNode{
+		"10.0.0.1": {etcd: healthyEtcd()},
+		"10.0.0.2": {etcd: healthyEtcd()},
+		"10.0.0.3": {configured: true},
+	}
+
+	b, phases := newTestBootstrapper(nodes, func(ctx context.Context) error { return nil })
+
+	err := b.Run(context.Background(), []Node{
+		{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+		{Name: "cp-1", Address: "10.0.0.2", ControlPlane: true},
+		{Name: "worker-0", Address: "10.0.0.3", ConfigInjected: true},
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v, expected nil", err)
+	}
+
+	if string(nodes["10.0.0.1"].applied) != "controlplane" || string(nodes["10.0.0.2"].applied) != "controlplane" {
+		t.Error("Expected control plane configs to be applied")
+	}
+	if nodes["10.0.0.3"].applied != nil {
+		t.Error("Expected injected config not to be applied again")
+	}
+
+	if nodes["10.0.0.1"].bootstraps != 1 || nodes["10.0.0.2"].bootstraps != 0 {
+		t.Errorf("Expected exactly one bootstrap on the first control plane, got %d and %d",
+			nodes["10.0.0.1"].bootstraps, nodes["10.0.0.2"].bootstraps)
+	}
+
+	expected := []Phase{PhaseWaitAPI, PhaseApplyConfig, PhaseBootstrap, PhaseEtcd, PhaseKubernetes}
+	if fmt.Sprint(*phases) != fmt.Sprint(expected) {
+		t.Errorf("Expected phases %v, got %v", expected, *phases)
+	}
+}
+
+func TestBootstrapperRecoversFromSnapshot(t *testing.T) {
+	nodes := map[string]*fakeNode{
+		"10.0.0.1": {etcd: healthyEtcd()},
+		"10.0.0.2": {etcd: healthyEtcd()},
+	}
+
+	snapshot := filepath.Join(t.TempDir(), "cloud1.db")
+	if err := os.WriteFile(snapshot, []byte("etcd data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := newTestBootstrapper(nodes, func(ctx context.Context) error { return nil })
+	b.RecoverFrom = snapshot
+
+	err := b.Run(context.Background(), []Node{
+		{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+		{Name: "cp-1", Address: "10.0.0.2", ControlPlane: true},
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v, expected nil", err)
+	}
+
+	if string(nodes["10.0.0.1"].recovered) != "etcd data" {
+		t.Errorf("Expected the snapshot to be recovered on the first control plane, got %q", nodes["10.0.0.1"].recovered)
+	}
+	if nodes["10.0.0.2"].recovered != nil || nodes["10.0.0.2"].bootstraps != 0 {
+		t.Error("Expected the second control plane to join instead of bootstrapping")
+	}
+}
+
+func TestBootstrapperSkipsConfiguredNodes(t *testing.T) {
+	nodes := map[string]*fakeNode{
+		"10.0.0.1": {configured: true, etcd: healthyEtcd()},
+	}
+
+	b, _ := newTestBootstrapper(nodes, func(ctx context.Context) error { return nil })
+
+	err := b.Run(context.Background(), []Node{{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true}})
+	if err != nil {
+		t.Fatalf("Run() error = %v, expected nil", err)
+	}
+	if nodes["10.0.0.1"].applied != nil {
+		t.Error("Expected an already configured node not to be reconfigured")
+	}
+}
+
+func TestBootstrapperAppliesNodeConfig(t *testing.T) {
+	nodes := map[string]*fakeNode{
+		"10.0.0.1": {etcd: healthyEtcd()},
+		"10.0.0.2": {},
+		"10.0.0.3": {},
+	}
+
+	b, _ := newTestBootstrapper(nodes, func(ctx context.Context) error { return nil })
+
+	err := b.Run(context.Background(), []Node{
+		{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+		{Name: "gpu-0", Address: "10.0.0.2", Config: []byte("gpu")},
+		{Name: "worker-0", Address: "10.0.0.3"},
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v, expected nil", err)
+	}
+
+	if string(nodes["10.0.0.2"].applied) != "gpu" {
+		t.Errorf("Expected the node config to be applied, got %q", nodes["10.0.0.2"].applied)
+	}
+	if string(nodes["10.0.0.3"].applied) != "worker" {
+		t.Errorf("Expected the worker config to be applied, got %q", nodes["10.0.0.3"].applied)
+	}
+}
+
+func TestBootstrapperErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodes      []Node
+		etcd       ServiceStatus
+		kubernetes HealthCheck
+	}{
+		{
+			name:  "no control plane",
+			nodes: []Node{{Name: "worker-0", Address: "10.0.0.1"}},
+			etcd:  healthyEtcd(),
+		},
+		{
+			name:  "missing address",
+			nodes: []Node{{Name: "cp-0", ControlPlane: true}},
+			etcd:  healthyEtcd(),
+		},
+		{
+			name:  "unreachable node",
+			nodes: []Node{{Name: "cp-0", Address: "10.0.0.9", ControlPlane: true}},
+			etcd:  healthyEtcd(),
+		},
+		{
+			name:  "etcd unhealthy",
+			nodes: []Node{{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true}},
+			etcd:  ServiceStatus{ID: "etcd", State: "Preparing"},
+		},
+		{
+			name:       "kubernetes unhealthy",
+			nodes:      []Node{{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true}},
+			etcd:       healthyEtcd(),
+			kubernetes: func(ctx context.Context) error { return fmt.Errorf("connection refused") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubernetes := tt.kubernetes
+			if kubernetes == nil {
+				kubernetes = func(ctx context.Context) error { return nil }
+			}
+
+			b, _ := newTestBootstrapper(map[string]*fakeNode{"10.0.0.1": {etcd: tt.etcd}}, kubernetes)
+			if err := b.Run(context.Background(), tt.nodes); err == nil {
+				t.Error("Run() error = nil, expected an error")
+			}
+		})
+	}
+}
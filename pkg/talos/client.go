@@ -0,0 +1,270 @@
+package talos
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cosi-project/runtime/pkg/safe"
+	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
+	"github.com/siderolabs/talos/pkg/machinery/client"
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"github.com/siderolabs/talos/pkg/machinery/resources/config"
+	"github.com/siderolabs/talos/pkg/machinery/resources/k8s"
+	"github.com/siderolabs/talos/pkg/machinery/resources/kubespan"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client is the subset of the Talos machine API used by talos-autoextender
+type Client interface {
+	KubeSpanPeerStatuses(ctx context.Context) ([]KubeSpanPeerStatus, error)
+	// Version returns the Talos version running on the node
+	Version(ctx context.Context) (string, error)
+	// ApplyConfiguration applies a machine config, also to nodes in maintenance mode
+	ApplyConfiguration(ctx context.Context, config []byte) error
+	// Bootstrap bootstraps etcd on the node. It succeeds if etcd was already
+	// bootstrapped.
+	Bootstrap(ctx context.Context) error
+	// ServiceStatus reports the state of a system service such as etcd
+	ServiceStatus(ctx context.Context, id string) (ServiceStatus, error)
+	// Upgrade installs the given installer image and reboots the node
+	Upgrade(ctx context.Context, image string, preserve bool) error
+	// NodeReady reports whether the node's Kubernetes Node is Ready
+	NodeReady(ctx context.Context) (bool, error)
+	// MachineConfig returns the machine config currently applied to the node
+	MachineConfig(ctx context.Context) ([]byte, error)
+	// EtcdSnapshot streams a snapshot of the etcd database from a control
+	// plane node
+	EtcdSnapshot(ctx context.Context) (io.ReadCloser, error)
+	// RecoverEtcd uploads a snapshot and bootstraps etcd from it
+	RecoverEtcd(ctx context.Context, snapshot io.Reader) error
+	Close() error
+}
+
+// ServiceStatus is the state of a Talos system service on a node
+type ServiceStatus struct {
+	ID      string
+	State   string
+	Healthy bool
+}
+
+// Connector opens a Talos API client for a single node endpoint (IP:PORT)
+type Connector func(ctx context.Context, endpoint string) (Client, error)
+
+// KubeSpanPeerStatus is the WireGuard state of one KubeSpan peer as seen by a node
+type KubeSpanPeerStatus struct {
+	PublicKey     string
+	Label         string
+	Endpoint      string
+	State         string
+	LastHandshake time.Time
+	ReceiveBytes  int64
+	TransmitBytes int64
+}
+
+// NewConnector returns a Connector using the talosconfig at configPath, or the
+// default talosconfig location if configPath is empty
+func NewConnector(configPath string) Connector {
+	return func(ctx context.Context, endpoint string) (Client, error) {
+		c, err := client.New(ctx,
+			client.WithConfigFromFile(configPath),
+			client.WithEndpoints(endpoint),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Talos client for %s: %v", endpoint, err)
+		}
+
+		return &apiClient{client: c}, nil
+	}
+}
+
+// NewConfigConnector returns a Connector using an in-memory talosconfig, such as
+// one generated from a cluster's stored secrets
+func NewConfigConnector(cfg *clientconfig.Config) Connector {
+	return func(ctx context.Context, endpoint string) (Client, error) {
+		c, err := client.New(ctx,
+			client.WithConfig(cfg),
+			client.WithEndpoints(endpoint),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Talos client for %s: %v", endpoint, err)
+		}
+
+		return &apiClient{client: c}, nil
+	}
+}
+
+// NewInsecureConnector returns a Connector for nodes in maintenance mode, which
+// serve the Talos API with a self-signed certificate until configured
+func NewInsecureConnector() Connector {
+	return func(ctx context.Context, endpoint string) (Client, error) {
+		c, err := client.New(ctx,
+			client.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+			client.WithEndpoints(endpoint),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create insecure Talos client for %s: %v", endpoint, err)
+		}
+
+		return &apiClient{client: c}, nil
+	}
+}
+
+// apiClient implements Client on top of the Talos machinery client
+type apiClient struct {
+	client *client.Client
+}
+
+func (a *apiClient) KubeSpanPeerStatuses(ctx context.Context) ([]KubeSpanPeerStatus, error) {
+	peers, err := safe.StateListAll[*kubespan.PeerStatus](ctx, a.client.COSI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list KubeSpan peer statuses: %v", err)
+	}
+
+	statuses := make([]KubeSpanPeerStatus, 0, peers.Len())
+	for iter := peers.Iterator(); iter.Next(); {
+		peer := iter.Value()
+		spec := peer.TypedSpec()
+
+		status := KubeSpanPeerStatus{
+			PublicKey:     peer.Metadata().ID(),
+			Label:         spec.Label,
+			State:         spec.State.String(),
+			LastHandshake: spec.LastHandshakeTime,
+			ReceiveBytes:  spec.ReceiveBytes,
+			TransmitBytes: spec.TransmitBytes,
+		}
+		if spec.Endpoint.IsValid() {
+			status.Endpoint = spec.Endpoint.String()
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (a *apiClient) Version(ctx context.Context) (string, error) {
+	resp, err := a.client.Version(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Talos version: %v", err)
+	}
+
+	for _, msg := range resp.Messages {
+		if msg.Version != nil {
+			return msg.Version.Tag, nil
+		}
+	}
+
+	return "", fmt.Errorf("Talos version response is empty")
+}
+
+func (a *apiClient) ApplyConfiguration(ctx context.Context, config []byte) error {
+	_, err := a.client.ApplyConfiguration(ctx, &machineapi.ApplyConfigurationRequest{
+		Data: config,
+		Mode: machineapi.ApplyConfigurationRequest_AUTO,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply machine config: %v", err)
+	}
+
+	return nil
+}
+
+func (a *apiClient) Bootstrap(ctx context.Context) error {
+	err := a.client.Bootstrap(ctx, &machineapi.BootstrapRequest{})
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap etcd: %v", err)
+	}
+
+	return nil
+}
+
+func (a *apiClient) ServiceStatus(ctx context.Context, id string) (ServiceStatus, error) {
+	services, err := a.client.ServiceInfo(ctx, id)
+	if err != nil {
+		return ServiceStatus{}, fmt.Errorf("failed to get %s service status: %v", id, err)
+	}
+
+	if len(services) == 0 || services[0].Service == nil {
+		return ServiceStatus{}, fmt.Errorf("service %s is not registered", id)
+	}
+
+	svc := services[0].Service
+	return ServiceStatus{
+		ID:      svc.Id,
+		State:   svc.State,
+		Healthy: svc.GetHealth().GetHealthy(),
+	}, nil
+}
+
+func (a *apiClient) Upgrade(ctx context.Context, image string, preserve bool) error {
+	_, err := a.client.UpgradeWithOptions(ctx,
+		client.WithUpgradeImage(image),
+		client.WithUpgradePreserve(preserve),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start upgrade to %s: %v", image, err)
+	}
+
+	return nil
+}
+
+func (a *apiClient) NodeReady(ctx context.Context) (bool, error) {
+	nodename, err := safe.StateGetByID[*k8s.Nodename](ctx, a.client.COSI, k8s.NodenameID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get Kubernetes node name: %v", err)
+	}
+
+	status, err := safe.StateGetByID[*k8s.NodeStatus](ctx, a.client.COSI, nodename.TypedSpec().Nodename)
+	if err != nil {
+		return false, fmt.Errorf("failed to get Kubernetes node status: %v", err)
+	}
+
+	return status.TypedSpec().NodeReady, nil
+}
+
+func (a *apiClient) MachineConfig(ctx context.Context) ([]byte, error) {
+	mc, err := safe.StateGetByID[*config.MachineConfig](ctx, a.client.COSI, config.V1Alpha1ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machine config: %v", err)
+	}
+
+	out, err := mc.Provider().Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode machine config: %v", err)
+	}
+
+	return out, nil
+}
+
+func (a *apiClient) EtcdSnapshot(ctx context.Context) (io.ReadCloser, error) {
+	r, err := a.client.EtcdSnapshot(ctx, &machineapi.EtcdSnapshotRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to take etcd snapshot: %v", err)
+	}
+
+	return r, nil
+}
+
+func (a *apiClient) RecoverEtcd(ctx context.Context, snapshot io.Reader) error {
+	if _, err := a.client.EtcdRecover(ctx, snapshot); err != nil {
+		return fmt.Errorf("failed to upload etcd snapshot: %v", err)
+	}
+
+	if err := a.client.Bootstrap(ctx, &machineapi.BootstrapRequest{RecoverEtcd: true}); err != nil {
+		return fmt.Errorf("failed to bootstrap etcd from snapshot: %v", err)
+	}
+
+	return nil
+}
+
+func (a *apiClient) Close() error {
+	return a.client.Close()
+}
@@ -0,0 +1,172 @@
+package talos
+
+import (
+	"fmt"
+
+	"github.com/siderolabs/talos/pkg/machinery/client/config"
+	machineryconfig "github.com/siderolabs/talos/pkg/machinery/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/encoder"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate/secrets"
+	"github.com/siderolabs/talos/pkg/machinery/config/machine"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultKubernetesVersion is the Kubernetes version installed when none is given
+const DefaultKubernetesVersion = constants.DefaultKubernetesVersion
+
+// ClusterConfig holds the inputs for generating the machine configs of a cluster
+type ClusterConfig struct {
+	Name string
+	// Endpoint is the Kubernetes API URL, e.g. https://203.0.113.10:6443
+	Endpoint          string
+	TalosVersion      string
+	KubernetesVersion string
+	PodCIDR           string
+	ServiceCIDR       string
+	// ControlPlanes are the addresses written to the generated talosconfig
+	ControlPlanes []string
+	// InstallImage is the installer image, e.g. one built by the Image Factory
+	// with system extensions; empty uses the official installer
+	InstallImage string
+	// PrivateSubnet is the private network the nodes share, e.g. a VPC subnet.
+	// When set, kubelet and etcd advertise their address on it.
+	PrivateSubnet string
+}
+
+// MachineConfigs are the generated configs for every node type of a cluster
+type MachineConfigs struct {
+	ControlPlane []byte
+	Worker       []byte
+	Talosconfig  *config.Config
+	// KubernetesCA is the PEM encoded Kubernetes CA certificate
+	KubernetesCA []byte
+}
+
+// GenerateSecrets creates a new secrets bundle for a cluster running talosVersion.
+// The bundle is returned as YAML so it can be stored and reused for later
+// config generation and credential retrieval.
+func GenerateSecrets(talosVersion string) ([]byte, error) {
+	contract, err := machineryconfig.ParseContractFromVersion(talosVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Talos version %s: %v", talosVersion, err)
+	}
+
+	bundle, err := secrets.NewBundle(secrets.NewClock(), contract)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secrets bundle: %v", err)
+	}
+
+	out, err := yaml.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode secrets bundle: %v", err)
+	}
+
+	return out, nil
+}
+
+// LoadSecrets decodes a secrets bundle produced by GenerateSecrets
+func LoadSecrets(data []byte) (*secrets.Bundle, error) {
+	bundle := &secrets.Bundle{Clock: secrets.NewClock()}
+	if err := yaml.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets bundle: %v", err)
+	}
+
+	if bundle.Certs == nil || bundle.Certs.OS == nil || bundle.Certs.K8s == nil {
+		return nil, fmt.Errorf("secrets bundle is incomplete")
+	}
+
+	return bundle, nil
+}
+
+// GenerateConfigs renders the control plane and worker machine configs and the
+// admin talosconfig for a cluster from its stored secrets
+func GenerateConfigs(cluster ClusterConfig, secretsData []byte) (MachineConfigs, error) {
+	if cluster.Name == "" || cluster.Endpoint == "" {
+		return MachineConfigs{}, fmt.Errorf("cluster name and endpoint are required")
+	}
+
+	bundle, err := LoadSecrets(secretsData)
+	if err != nil {
+		return MachineConfigs{}, err
+	}
+
+	contract, err := machineryconfig.ParseContractFromVersion(cluster.TalosVersion)
+	if err != nil {
+		return MachineConfigs{}, fmt.Errorf("invalid Talos version %s: %v", cluster.TalosVersion, err)
+	}
+
+	kubernetesVersion := cluster.KubernetesVersion
+	if kubernetesVersion == "" {
+		kubernetesVersion = DefaultKubernetesVersion
+	}
+
+	options := []generate.Option{
+		generate.WithVersionContract(contract),
+		generate.WithSecretsBundle(bundle),
+		generate.WithEndpointList(cluster.ControlPlanes),
+	}
+	if cluster.InstallImage != "" {
+		options = append(options, generate.WithInstallImage(cluster.InstallImage))
+	}
+
+	input, err := generate.NewInput(cluster.Name, cluster.Endpoint, kubernetesVersion, options...)
+	if err != nil {
+		return MachineConfigs{}, fmt.Errorf("failed to prepare config generation: %v", err)
+	}
+
+	configs := MachineConfigs{KubernetesCA: bundle.Certs.K8s.Crt}
+
+	if configs.ControlPlane, err = renderConfig(input, machine.TypeControlPlane, cluster); err != nil {
+		return MachineConfigs{}, err
+	}
+	if configs.Worker, err = renderConfig(input, machine.TypeWorker, cluster); err != nil {
+		return MachineConfigs{}, err
+	}
+
+	if configs.Talosconfig, err = input.Talosconfig(); err != nil {
+		return MachineConfigs{}, fmt.Errorf("failed to generate talosconfig: %v", err)
+	}
+
+	return configs, nil
+}
+
+func renderConfig(input *generate.Input, machineType machine.Type, cluster ClusterConfig) ([]byte, error) {
+	cfg, err := input.Config(machineType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s config: %v", machineType, err)
+	}
+
+	// Non-default cluster networks keep pod routing unambiguous across the mesh
+	network := cfg.RawV1Alpha1().ClusterConfig.ClusterNetwork
+	if network != nil && cluster.PodCIDR != "" {
+		network.PodSubnet = []string{cluster.PodCIDR}
+	}
+	if network != nil && cluster.ServiceCIDR != "" {
+		network.ServiceSubnet = []string{cluster.ServiceCIDR}
+	}
+
+	// Keep node and etcd peer traffic on the private network
+	if cluster.PrivateSubnet != "" {
+		raw := cfg.RawV1Alpha1()
+		if raw.MachineConfig.MachineKubelet == nil {
+			raw.MachineConfig.MachineKubelet = &v1alpha1.KubeletConfig{}
+		}
+		raw.MachineConfig.MachineKubelet.KubeletNodeIP = &v1alpha1.KubeletNodeIPConfig{
+			KubeletNodeIPValidSubnets: []string{cluster.PrivateSubnet},
+		}
+		if machineType == machine.TypeControlPlane && raw.ClusterConfig.EtcdConfig != nil {
+			raw.ClusterConfig.EtcdConfig.EtcdAdvertisedSubnets = []string{cluster.PrivateSubnet}
+		}
+	}
+
+	// Comments are dropped to keep the config small enough for instance user data
+	out, err := cfg.EncodeBytes(encoder.WithComments(encoder.CommentsDisabled))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s config: %v", machineType, err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package talos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateConfigs(t *testing.T) {
+	secrets, err := GenerateSecrets("v1.6.0")
+	if err != nil {
+		t.Fatalf("GenerateSecrets() error = %v", err)
+	}
+
+	configs, err := GenerateConfigs(ClusterConfig{
+		Name:          "cloud1",
+		Endpoint:      "https://203.0.113.10:6443",
+		TalosVersion:  "v1.6.0",
+		PodCIDR:       "10.128.0.0/16",
+		ServiceCIDR:   "10.129.0.0/20",
+		ControlPlanes: []string{"203.0.113.10"},
+	}, secrets)
+	if err != nil {
+		t.Fatalf("GenerateConfigs() error = %v, expected nil", err)
+	}
+
+	controlPlane := string(configs.ControlPlane)
+	for _, want := range []string{"type: controlplane", "10.128.0.0/16", "10.129.0.0/20", "https://203.0.113.10:6443"} {
+		if !strings.Contains(controlPlane, want) {
+			t.Errorf("Expected control plane config to contain %q", want)
+		}
+	}
+	if !strings.Contains(string(configs.Worker), "type: worker") {
+		t.Error("Expected worker config")
+	}
+
+	if configs.Talosconfig == nil || configs.Talosconfig.Contexts["cloud1"] == nil {
+		t.Fatal("Expected talosconfig with a cloud1 context")
+	}
+	if len(configs.KubernetesCA) == 0 {
+		t.Error("Expected Kubernetes CA certificate")
+	}
+
+	// Configs generated again from the same secrets trust the same CA
+	again, err := GenerateConfigs(ClusterConfig{Name: "cloud1", Endpoint: "https://203.0.113.10:6443", TalosVersion: "v1.6.0"}, secrets)
+	if err != nil {
+		t.Fatalf("GenerateConfigs() error = %v, expected nil", err)
+	}
+	if string(again.KubernetesCA) != string(configs.KubernetesCA) {
+		t.Error("Expected the same Kubernetes CA from the same secrets")
+	}
+}
+
+func TestGenerateConfigsInstallImage(t *testing.T) {
+	secrets, err := GenerateSecrets("v1.6.0")
+	if err != nil {
+		t.Fatalf("GenerateSecrets() error = %v", err)
+	}
+
+	installer := "factory.talos.dev/installer/ce4c980550dd2ab1b17bbf2b08801c7eb59418eafe8f279833297925d67c7515:v1.6.0"
+	configs, err := GenerateConfigs(ClusterConfig{
+		Name:         "cloud1",
+		Endpoint:     "https://203.0.113.10:6443",
+		TalosVersion: "v1.6.0",
+		InstallImage: installer,
+	}, secrets)
+	if err != nil {
+		t.Fatalf("GenerateConfigs() error = %v, expected nil", err)
+	}
+
+	for _, config := range [][]byte{configs.ControlPlane, configs.Worker} {
+		if !strings.Contains(string(config), "image: "+installer) {
+			t.Errorf("Expected config to install %s", installer)
+		}
+	}
+}
+
+func TestGenerateConfigsPrivateSubnet(t *testing.T) {
+	secrets, err := GenerateSecrets("v1.6.0")
+	if err != nil {
+		t.Fatalf("GenerateSecrets() error = %v", err)
+	}
+
+	configs, err := GenerateConfigs(ClusterConfig{
+		Name:          "cloud1",
+		Endpoint:      "https://203.0.113.10:6443",
+		TalosVersion:  "v1.6.0",
+		PrivateSubnet: "172.30.0.0/24",
+	}, secrets)
+	if err != nil {
+		t.Fatalf("GenerateConfigs() error = %v, expected nil", err)
+	}
+
+	if got := strings.Count(string(configs.ControlPlane), "- 172.30.0.0/24"); got != 2 {
+		t.Errorf("Expected kubelet and etcd to use the private subnet, found it %d times", got)
+	}
+	if !strings.Contains(string(configs.Worker), "validSubnets:\n") {
+		t.Error("Expected the worker kubelet to use the private subnet")
+	}
+}
+
+func TestGenerateConfigsErrors(t *testing.T) {
+	secrets, err := GenerateSecrets("v1.6.0")
+	if err != nil {
+		t.Fatalf("GenerateSecrets() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cluster ClusterConfig
+		secrets []byte
+	}{
+		{
+			name:    "missing endpoint",
+			cluster: ClusterConfig{Name: "cloud1", TalosVersion: "v1.6.0"},
+			secrets: secrets,
+		},
+		{
+			name:    "invalid Talos version",
+			cluster: ClusterConfig{Name: "cloud1", Endpoint: "https://203.0.113.10:6443", TalosVersion: "latest"},
+			secrets: secrets,
+		},
+		{
+			name:    "invalid secrets",
+			cluster: ClusterConfig{Name: "cloud1", Endpoint: "https://203.0.113.10:6443", TalosVersion: "v1.6.0"},
+			secrets: []byte("not: [a bundle"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := GenerateConfigs(tt.cluster, tt.secrets); err == nil {
+				t.Error("GenerateConfigs() error = nil, expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package talos
+
+import (
+	"bytes"
+	stdx509 "crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/siderolabs/crypto/x509"
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+	"github.com/siderolabs/talos/pkg/machinery/role"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultAdminCertTTL is how long generated admin client certificates are valid
+const DefaultAdminCertTTL = constants.KubernetesAdminCertDefaultLifetime
+
+// AdminTalosconfig generates a talosconfig with an admin client certificate for
+// the cluster, using a context named after the cluster
+func AdminTalosconfig(cluster string, endpoints []string, secretsData []byte) (*clientconfig.Config, error) {
+	bundle, err := LoadSecrets(secretsData)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := bundle.GenerateTalosAPIClientCertificate(role.MakeSet(role.Admin))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate Talos client certificate: %v", err)
+	}
+
+	return clientconfig.NewConfig(cluster, endpoints, bundle.Certs.OS.Crt, cert), nil
+}
+
+// MergeTalosconfig merges cfg into the talosconfig at path, creating it if it
+// does not exist. Contexts with the same name are replaced rather than renamed.
+func MergeTalosconfig(path string, cfg *clientconfig.Config) error {
+	existing, err := clientconfig.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open talosconfig %s: %v", path, err)
+	}
+
+	for name := range cfg.Contexts {
+		delete(existing.Contexts, name)
+	}
+	existing.Merge(cfg)
+
+	if err := existing.Save(path); err != nil {
+		return fmt.Errorf("failed to save talosconfig %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// DefaultTalosconfigPath returns the talosconfig path used by talosctl
+func DefaultTalosconfigPath() (string, error) {
+	paths, err := clientconfig.GetDefaultPaths()
+	if err != nil {
+		return "", err
+	}
+	return paths[0].Path, nil
+}
+
+// Kubeconfig is a kubectl config file. Fields this tool does not manage are
+// kept so merging into an existing file does not lose them.
+type Kubeconfig struct {
+	APIVersion     string                 `yaml:"apiVersion"`
+	Kind           string                 `yaml:"kind"`
+	Clusters       []KubeconfigEntry      `yaml:"clusters"`
+	Contexts       []KubeconfigEntry      `yaml:"contexts"`
+	Users          []KubeconfigEntry      `yaml:"users"`
+	CurrentContext string                 `yaml:"current-context"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+// KubeconfigEntry is a named cluster, context or user
+type KubeconfigEntry struct {
+	Name   string                 `yaml:"name"`
+	Fields map[string]interface{} `yaml:",inline"`
+}
+
+// KubernetesCredentials are the PEM encoded CA and admin client certificate for
+// a cluster's Kubernetes API
+type KubernetesCredentials struct {
+	Endpoint string
+	CA       []byte
+	Cert     []byte
+	Key      []byte
+}
+
+// AdminKubernetesCredentials generates a client certificate in system:masters,
+// signed by the cluster's Kubernetes CA
+func AdminKubernetesCredentials(endpoint string, secretsData []byte, ttl time.Duration) (KubernetesCredentials, error) {
+	if endpoint == "" {
+		return KubernetesCredentials{}, fmt.Errorf("no Kubernetes API endpoint")
+	}
+
+	bundle, err := LoadSecrets(secretsData)
+	if err != nil {
+		return KubernetesCredentials{}, err
+	}
+
+	ca, err := x509.NewCertificateAuthorityFromCertificateAndKey(bundle.Certs.K8s)
+	if err != nil {
+		return KubernetesCredentials{}, fmt.Errorf("failed to load Kubernetes CA: %v", err)
+	}
+
+	keyPair, err := x509.NewKeyPair(ca,
+		x509.CommonName(constants.KubernetesAdminCertCommonName),
+		x509.Organization(constants.KubernetesAdminCertOrganization),
+		x509.NotAfter(time.Now().Add(ttl)),
+		x509.KeyUsage(stdx509.KeyUsageDigitalSignature|stdx509.KeyUsageKeyEncipherment),
+		x509.ExtKeyUsage([]stdx509.ExtKeyUsage{stdx509.ExtKeyUsageClientAuth}),
+	)
+	if err != nil {
+		return KubernetesCredentials{}, fmt.Errorf("failed to generate Kubernetes admin certificate: %v", err)
+	}
+
+	return KubernetesCredentials{
+		Endpoint: endpoint,
+		CA:       bundle.Certs.K8s.Crt,
+		Cert:     keyPair.CrtPEM,
+		Key:      keyPair.KeyPEM,
+	}, nil
+}
+
+// AdminKubeconfig generates a kubeconfig for the cluster with admin credentials
+func AdminKubeconfig(cluster, endpoint string, secretsData []byte, ttl time.Duration) ([]byte, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("cluster %s has no Kubernetes API endpoint", cluster)
+	}
+
+	creds, err := AdminKubernetesCredentials(endpoint, secretsData, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	user := "admin@" + cluster
+	cfg := Kubeconfig{
+		APIVersion: "v1",
+		Kind:       "Config",
+		Clusters: []KubeconfigEntry{{Name: cluster, Fields: map[string]interface{}{
+			"cluster": map[string]interface{}{
+				"server":                     creds.Endpoint,
+				"certificate-authority-data": base64.StdEncoding.EncodeToString(creds.CA),
+			},
+		}}},
+		Users: []KubeconfigEntry{{Name: user, Fields: map[string]interface{}{
+			"user": map[string]interface{}{
+				"client-certificate-data": base64.StdEncoding.EncodeToString(creds.Cert),
+				"client-key-data":         base64.StdEncoding.EncodeToString(creds.Key),
+			},
+		}}},
+		Contexts: []KubeconfigEntry{{Name: cluster, Fields: map[string]interface{}{
+			"context": map[string]interface{}{
+				"cluster": cluster,
+				"user":    user,
+			},
+		}}},
+		CurrentContext: cluster,
+	}
+
+	return encodeKubeconfig(cfg)
+}
+
+// MergeKubeconfig merges the clusters, users and contexts of cfg into existing,
+// replacing entries with the same name, and switches to cfg's current context
+func MergeKubeconfig(existing, cfg []byte) ([]byte, error) {
+	var base, add Kubeconfig
+	if err := yaml.Unmarshal(existing, &base); err != nil {
+		return nil, fmt.Errorf("failed to parse existing kubeconfig: %v", err)
+	}
+	if err := yaml.Unmarshal(cfg, &add); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %v", err)
+	}
+
+	if base.APIVersion == "" {
+		base.APIVersion = "v1"
+	}
+	if base.Kind == "" {
+		base.Kind = "Config"
+	}
+
+	base.Clusters = mergeEntries(base.Clusters, add.Clusters)
+	base.Users = mergeEntries(base.Users, add.Users)
+	base.Contexts = mergeEntries(base.Contexts, add.Contexts)
+	if add.CurrentContext != "" {
+		base.CurrentContext = add.CurrentContext
+	}
+
+	return encodeKubeconfig(base)
+}
+
+// encodeKubeconfig renders a kubeconfig with the two space indent kubectl uses
+func encodeKubeconfig(cfg Kubeconfig) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to encode kubeconfig: %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode kubeconfig: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func mergeEntries(base, add []KubeconfigEntry) []KubeconfigEntry {
+	for _, entry := range add {
+		replaced := false
+		for i := range base {
+			if base[i].Name == entry.Name {
+				base[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			base = append(base, entry)
+		}
+	}
+	return base
+}
+
+// MergeKubeconfigFile merges cfg into the kubeconfig file at path, creating it
+// if it does not exist
+func MergeKubeconfigFile(path string, cfg []byte) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read kubeconfig %s: %v", path, err)
+	}
+
+	merged, err := MergeKubeconfig(existing, cfg)
+	if err != nil {
+		return err
+	}
+
+	return WriteCredentials(path, merged)
+}
+
+// DefaultKubeconfigPath returns the first path in KUBECONFIG, or ~/.kube/config
+func DefaultKubeconfigPath() (string, error) {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return strings.Split(env, string(os.PathListSeparator))[0], nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube", "config"), nil
+}
+
+// WriteCredentials writes a credentials file readable only by the user
+func WriteCredentials(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
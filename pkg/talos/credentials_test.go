@@ -0,0 +1,173 @@
+package talos
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAdminKubeconfig(t *testing.T) {
+	secrets, err := GenerateSecrets("v1.6.0")
+	if err != nil {
+		t.Fatalf("GenerateSecrets() error = %v", err)
+	}
+
+	out, err := AdminKubeconfig("cloud1", "https://203.0.113.10:6443", secrets, time.Hour)
+	if err != nil {
+		t.Fatalf("AdminKubeconfig() error = %v, expected nil", err)
+	}
+
+	var cfg Kubeconfig
+	if err := yaml.Unmarshal(out, &cfg); err != nil {
+		t.Fatalf("Failed to parse kubeconfig: %v", err)
+	}
+	if cfg.CurrentContext != "cloud1" || len(cfg.Contexts) != 1 || cfg.Contexts[0].Name != "cloud1" {
+		t.Errorf("Expected a cloud1 context, got %+v", cfg.Contexts)
+	}
+
+	user := cfg.Users[0].Fields["user"].(map[string]interface{})
+	certPEM, _ := base64.StdEncoding.DecodeString(user["client-certificate-data"].(string))
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("Expected a PEM encoded client certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse client certificate: %v", err)
+	}
+	if len(cert.Subject.Organization) != 1 || cert.Subject.Organization[0] != "system:masters" {
+		t.Errorf("Expected system:masters organization, got %v", cert.Subject.Organization)
+	}
+
+	bundle, _ := LoadSecrets(secrets)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(bundle.Certs.K8s.Crt)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("Expected client certificate signed by the cluster CA: %v", err)
+	}
+
+	if _, err := AdminKubeconfig("cloud1", "", secrets, time.Hour); err == nil {
+		t.Error("Expected error without an endpoint")
+	}
+}
+
+func TestMergeKubeconfig(t *testing.T) {
+	existing := []byte(`apiVersion: v1
+kind: Config
+preferences: {}
+clusters:
+- name: home
+  cluster:
+    server: https://192.168.1.10:6443
+- name: cloud1
+  cluster:
+    server: https://198.51.100.1:6443
+users:
+- name: oidc
+  user:
+    exec:
+      command: kubectl-oidc
+contexts:
+- name: home
+  context:
+    cluster: home
+    user: oidc
+current-context: home
+`)
+
+	add := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: cloud1
+  cluster:
+    server: https://203.0.113.10:6443
+users:
+- name: admin@cloud1
+  user:
+    client-certificate-data: Y2VydA==
+contexts:
+- name: cloud1
+  context:
+    cluster: cloud1
+    user: admin@cloud1
+current-context: cloud1
+`)
+
+	out, err := MergeKubeconfig(existing, add)
+	if err != nil {
+		t.Fatalf("MergeKubeconfig() error = %v, expected nil", err)
+	}
+
+	var cfg Kubeconfig
+	if err := yaml.Unmarshal(out, &cfg); err != nil {
+		t.Fatalf("Failed to parse merged kubeconfig: %v", err)
+	}
+
+	if len(cfg.Clusters) != 2 || len(cfg.Users) != 2 || len(cfg.Contexts) != 2 {
+		t.Errorf("Expected 2 clusters, users and contexts, got %d, %d and %d", len(cfg.Clusters), len(cfg.Users), len(cfg.Contexts))
+	}
+	if cfg.CurrentContext != "cloud1" {
+		t.Errorf("Expected current context cloud1, got %s", cfg.CurrentContext)
+	}
+	if !strings.Contains(string(out), "https://203.0.113.10:6443") || strings.Contains(string(out), "198.51.100.1") {
+		t.Error("Expected the cloud1 cluster to be replaced")
+	}
+	if !strings.Contains(string(out), "kubectl-oidc") || !strings.Contains(string(out), "preferences") {
+		t.Error("Expected unmanaged fields to be kept")
+	}
+
+	// Merging into an empty file produces a complete kubeconfig
+	out, err = MergeKubeconfig(nil, add)
+	if err != nil {
+		t.Fatalf("MergeKubeconfig() error = %v, expected nil", err)
+	}
+	if !strings.Contains(string(out), "kind: Config") {
+		t.Error("Expected kind Config in a new kubeconfig")
+	}
+}
+
+func TestMergeTalosconfig(t *testing.T) {
+	secrets, err := GenerateSecrets("v1.6.0")
+	if err != nil {
+		t.Fatalf("GenerateSecrets() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "talos", "config")
+
+	for _, endpoint := range []string{"198.51.100.1", "203.0.113.10"} {
+		cfg, err := AdminTalosconfig("cloud1", []string{endpoint}, secrets)
+		if err != nil {
+			t.Fatalf("AdminTalosconfig() error = %v, expected nil", err)
+		}
+		if err := MergeTalosconfig(path, cfg); err != nil {
+			t.Fatalf("MergeTalosconfig() error = %v, expected nil", err)
+		}
+	}
+
+	merged, err := clientconfig.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open merged talosconfig: %v", err)
+	}
+	if len(merged.Contexts) != 1 || merged.Context != "cloud1" {
+		t.Errorf("Expected the cloud1 context to be replaced, got context %q and %d contexts", merged.Context, len(merged.Contexts))
+	}
+	if endpoints := merged.Contexts["cloud1"].Endpoints; len(endpoints) != 1 || endpoints[0] != "203.0.113.10" {
+		t.Errorf("Expected the latest endpoints, got %v", endpoints)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected talosconfig mode 0600, got %v", info.Mode().Perm())
+	}
+}
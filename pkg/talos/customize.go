@@ -0,0 +1,92 @@
+package talos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/siderolabs/talos/pkg/machinery/config/configloader"
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+	"github.com/siderolabs/talos/pkg/machinery/config/encoder"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+)
+
+// NodeCustomization are the per node pool additions to a generated machine config
+type NodeCustomization struct {
+	Labels map[string]string
+	// Taints use the kubectl syntax key[=value]:Effect
+	Taints []string
+	// Patches are strategic merge or JSON patches, inline or as @file
+	Patches []string
+}
+
+// IsZero reports whether the customization changes nothing
+func (c NodeCustomization) IsZero() bool {
+	return len(c.Labels) == 0 && len(c.Taints) == 0 && len(c.Patches) == 0
+}
+
+// CustomizeConfig registers labels and taints on the node and applies the
+// config patches, in that order, so patches can override both
+func CustomizeConfig(config []byte, c NodeCustomization) ([]byte, error) {
+	if c.IsZero() {
+		return config, nil
+	}
+
+	cfg, err := configloader.NewFromBytes(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse machine config: %v", err)
+	}
+
+	taints := map[string]string{}
+	for _, taint := range c.Taints {
+		keyValue, effect, ok := strings.Cut(taint, ":")
+		if !ok {
+			return nil, fmt.Errorf("taint %q has no effect", taint)
+		}
+		key, value, _ := strings.Cut(keyValue, "=")
+		taints[key] = value + ":" + effect
+	}
+
+	patched, err := cfg.PatchV1Alpha1(func(raw *v1alpha1.Config) error {
+		if raw.MachineConfig == nil {
+			return fmt.Errorf("machine config has no machine section")
+		}
+
+		for key, value := range c.Labels {
+			if raw.MachineConfig.MachineNodeLabels == nil {
+				raw.MachineConfig.MachineNodeLabels = map[string]string{}
+			}
+			raw.MachineConfig.MachineNodeLabels[key] = value
+		}
+		for key, value := range taints {
+			if raw.MachineConfig.MachineNodeTaints == nil {
+				raw.MachineConfig.MachineNodeTaints = map[string]string{}
+			}
+			raw.MachineConfig.MachineNodeTaints[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	patches, err := configpatcher.LoadPatches(c.Patches)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config patches: %v", err)
+	}
+
+	out, err := configpatcher.Apply(configpatcher.WithConfig(patched), patches)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply config patches: %v", err)
+	}
+
+	result, err := out.Config()
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply config patches: %v", err)
+	}
+
+	encoded, err := result.EncodeBytes(encoder.WithComments(encoder.CommentsDisabled))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode machine config: %v", err)
+	}
+	return encoded, nil
+}
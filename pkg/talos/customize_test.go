@@ -0,0 +1,77 @@
+package talos
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCustomizeConfig(t *testing.T) {
+	configs := testMachineConfigs(t)
+
+	tests := []struct {
+		name          string
+		customization NodeCustomization
+		want          []string
+		shouldError   bool
+	}{
+		{
+			name: "labels and taints",
+			customization: NodeCustomization{
+				Labels: map[string]string{"gpu": "true"},
+				Taints: []string{"nvidia.com/gpu=true:NoSchedule", "dedicated:NoExecute"},
+			},
+			want: []string{"gpu: \"true\"", "nvidia.com/gpu: true:NoSchedule", "dedicated: :NoExecute"},
+		},
+		{
+			name: "strategic merge patch",
+			customization: NodeCustomization{
+				Patches: []string{"machine:\n  sysctls:\n    vm.max_map_count: \"262144\"\n"},
+			},
+			want: []string{"vm.max_map_count: \"262144\""},
+		},
+		{
+			name: "patch overrides label",
+			customization: NodeCustomization{
+				Labels:  map[string]string{"tier": "batch"},
+				Patches: []string{"machine:\n  nodeLabels:\n    tier: interactive\n"},
+			},
+			want: []string{"tier: interactive"},
+		},
+		{
+			name:          "invalid taint",
+			customization: NodeCustomization{Taints: []string{"dedicated"}},
+			shouldError:   true,
+		},
+		{
+			name:          "missing patch file",
+			customization: NodeCustomization{Patches: []string{"@does-not-exist.yaml"}},
+			shouldError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := CustomizeConfig(configs.Worker, tt.customization)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("CustomizeConfig() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if tt.shouldError {
+				return
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("Expected config to contain %q", want)
+				}
+			}
+			if !strings.Contains(string(out), "type: worker") {
+				t.Error("Expected the config to stay a worker config")
+			}
+		})
+	}
+
+	if out, err := CustomizeConfig(configs.Worker, NodeCustomization{}); err != nil || !bytes.Equal(out, configs.Worker) {
+		t.Errorf("Expected an empty customization to return the config unchanged, error = %v", err)
+	}
+}
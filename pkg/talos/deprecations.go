@@ -0,0 +1,215 @@
+package talos
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const deprecatedAPIsMetric = "apiserver_requested_deprecated_apis"
+
+// DeprecatedAPI is a deprecated API that was requested from the Kubernetes API
+// server since it started
+type DeprecatedAPI struct {
+	Group          string
+	Version        string
+	Resource       string
+	RemovedRelease string
+}
+
+func (d DeprecatedAPI) String() string {
+	gv := d.Version
+	if d.Group != "" {
+		gv = d.Group + "/" + d.Version
+	}
+	return fmt.Sprintf("%s %s (removed in %s)", gv, d.Resource, d.RemovedRelease)
+}
+
+// RemovedAPIs returns the deprecated APIs in use that no longer exist in the
+// target Kubernetes version, read from the API server's metrics
+func RemovedAPIs(ctx context.Context, creds KubernetesCredentials, target string) ([]DeprecatedAPI, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(creds.CA) {
+		return nil, fmt.Errorf("invalid Kubernetes CA certificate")
+	}
+
+	cert, err := tls.X509KeyPair(creds.Cert, creds.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes client certificate: %v", err)
+	}
+
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}},
+		},
+	}
+	url := strings.TrimRight(creds.Endpoint, "/") + "/metrics"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Kubernetes API metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	apis, err := parseDeprecatedAPIs(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return removedBy(apis, target)
+}
+
+// parseDeprecatedAPIs reads the deprecated API request metric from the
+// Prometheus text format
+func parseDeprecatedAPIs(r io.Reader) ([]DeprecatedAPI, error) {
+	var apis []DeprecatedAPI
+	seen := map[DeprecatedAPI]bool{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, deprecatedAPIsMetric+"{") {
+			continue
+		}
+
+		end := strings.LastIndex(line, "}")
+		if end < 0 {
+			return nil, fmt.Errorf("malformed metric line %q", line)
+		}
+
+		labels, err := parseLabels(line[len(deprecatedAPIsMetric)+1 : end])
+		if err != nil {
+			return nil, fmt.Errorf("malformed metric line %q: %v", line, err)
+		}
+
+		api := DeprecatedAPI{
+			Group:          labels["group"],
+			Version:        labels["version"],
+			Resource:       labels["resource"],
+			RemovedRelease: labels["removed_release"],
+		}
+		if !seen[api] {
+			seen[api] = true
+			apis = append(apis, api)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %v", err)
+	}
+
+	return apis, nil
+}
+
+// parseLabels parses a Prometheus label set such as a="1",b="2"
+func parseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	for s = strings.TrimSpace(s); s != ""; {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("label without value")
+		}
+		name := strings.TrimSpace(s[:eq])
+
+		value, rest, err := unquoteLabel(s[eq+1:])
+		if err != nil {
+			return nil, fmt.Errorf("label %s: %v", name, err)
+		}
+		labels[name] = value
+
+		s = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+		s = strings.TrimSpace(s)
+	}
+
+	return labels, nil
+}
+
+// unquoteLabel reads a quoted label value from the start of s and returns the
+// remainder
+func unquoteLabel(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("value is not quoted")
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", err
+			}
+			return value, s[i+1:], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated value")
+}
+
+// removedBy returns the APIs removed in the target version or earlier, sorted
+func removedBy(apis []DeprecatedAPI, target string) ([]DeprecatedAPI, error) {
+	targetMajor, targetMinor, err := minorVersion(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []DeprecatedAPI
+	for _, api := range apis {
+		if api.RemovedRelease == "" {
+			continue
+		}
+
+		major, minor, err := minorVersion(api.RemovedRelease)
+		if err != nil {
+			return nil, err
+		}
+		if major < targetMajor || (major == targetMajor && minor <= targetMinor) {
+			removed = append(removed, api)
+		}
+	}
+
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].String() < removed[j].String()
+	})
+
+	return removed, nil
+}
+
+// minorVersion parses the major and minor parts of a version such as v1.29.3
+func minorVersion(version string) (int, int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid Kubernetes version %q", version)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Kubernetes version %q", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Kubernetes version %q", version)
+	}
+
+	return major, minor, nil
+}
@@ -0,0 +1,71 @@
+package talos
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMetrics = `# HELP apiserver_requested_deprecated_apis [STABLE] Gauge of deprecated APIs that have been requested, broken out by API group, version, resource, subresource, and removed_release.
+# TYPE apiserver_requested_deprecated_apis gauge
+apiserver_requested_deprecated_apis{group="flowcontrol.apiserver.k8s.io",removed_release="1.29",resource="flowschemas",subresource="",version="v1beta2"} 1
+apiserver_requested_deprecated_apis{group="flowcontrol.apiserver.k8s.io",removed_release="1.32",resource="prioritylevelconfigurations",subresource="",version="v1beta3"} 1
+apiserver_requested_deprecated_apis{group="",removed_release="",resource="componentstatuses",subresource="",version="v1"} 1
+apiserver_request_total{code="200",resource="pods",verb="LIST",version="v1"} 12
+`
+
+func TestParseDeprecatedAPIs(t *testing.T) {
+	apis, err := parseDeprecatedAPIs(strings.NewReader(testMetrics))
+	if err != nil {
+		t.Fatalf("parseDeprecatedAPIs() error = %v", err)
+	}
+
+	if len(apis) != 3 {
+		t.Fatalf("Expected 3 deprecated APIs, got %d", len(apis))
+	}
+
+	want := DeprecatedAPI{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta2", Resource: "flowschemas", RemovedRelease: "1.29"}
+	if apis[0] != want {
+		t.Errorf("apis[0] = %+v, expected %+v", apis[0], want)
+	}
+
+	if _, err := parseDeprecatedAPIs(strings.NewReader(`apiserver_requested_deprecated_apis{group=flowcontrol} 1`)); err == nil {
+		t.Error("parseDeprecatedAPIs() error = nil, expected an error for an unquoted label")
+	}
+}
+
+func TestRemovedBy(t *testing.T) {
+	apis, err := parseDeprecatedAPIs(strings.NewReader(testMetrics))
+	if err != nil {
+		t.Fatalf("parseDeprecatedAPIs() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		want        []string
+		shouldError bool
+	}{
+		{name: "before removal", target: "1.28.9"},
+		{name: "removal release", target: "v1.29.0", want: []string{"flowschemas"}},
+		{name: "later release", target: "1.32.1", want: []string{"flowschemas", "prioritylevelconfigurations"}},
+		{name: "next major", target: "2.0.0", want: []string{"flowschemas", "prioritylevelconfigurations"}},
+		{name: "invalid version", target: "latest", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removed, err := removedBy(apis, tt.target)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("removedBy() error = %v, shouldError %v", err, tt.shouldError)
+			}
+
+			var got []string
+			for _, api := range removed {
+				got = append(got, api.Resource)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("removedBy() = %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
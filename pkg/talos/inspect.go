@@ -0,0 +1,85 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/siderolabs/talos/pkg/machinery/config/configloader"
+	"github.com/siderolabs/talos/pkg/machinery/config/generate/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// NodeDetails is what a running node tells about itself and its cluster
+type NodeDetails struct {
+	TalosVersion string
+	// Role is controlplane or worker
+	Role string
+	// ClusterName is only set in control plane configs
+	ClusterName       string
+	Endpoint          string
+	KubernetesVersion string
+	PodCIDRs          []string
+	ServiceCIDRs      []string
+	// Secrets is the cluster's secrets bundle as YAML, recovered from control
+	// plane configs only
+	Secrets []byte
+}
+
+// InspectNode reads the Talos version and machine config of the node at
+// address, for clusters built without this tool
+func InspectNode(ctx context.Context, connect Connector, address string) (NodeDetails, error) {
+	c, err := connect(ctx, address)
+	if err != nil {
+		return NodeDetails{}, fmt.Errorf("failed to connect to %s: %v", address, err)
+	}
+	defer c.Close()
+
+	version, err := c.Version(ctx)
+	if err != nil {
+		return NodeDetails{}, fmt.Errorf("failed to get the Talos version of %s: %v", address, err)
+	}
+	config, err := c.MachineConfig(ctx)
+	if err != nil {
+		return NodeDetails{}, fmt.Errorf("failed to get the machine config of %s: %v", address, err)
+	}
+
+	details, err := parseNodeDetails(config)
+	if err != nil {
+		return NodeDetails{}, fmt.Errorf("node %s: %v", address, err)
+	}
+	details.TalosVersion = version
+	return details, nil
+}
+
+// parseNodeDetails reads the cluster details from a machine config
+func parseNodeDetails(config []byte) (NodeDetails, error) {
+	cfg, err := configloader.NewFromBytes(config)
+	if err != nil {
+		return NodeDetails{}, fmt.Errorf("failed to parse machine config: %v", err)
+	}
+	if cfg.Machine() == nil || cfg.Cluster() == nil {
+		return NodeDetails{}, fmt.Errorf("machine config has no machine or cluster section")
+	}
+
+	details := NodeDetails{
+		Role:         "worker",
+		ClusterName:  cfg.Cluster().Name(),
+		PodCIDRs:     cfg.Cluster().Network().PodCIDRs(),
+		ServiceCIDRs: cfg.Cluster().Network().ServiceCIDRs(),
+	}
+	if endpoint := cfg.Cluster().Endpoint(); endpoint != nil {
+		details.Endpoint = endpoint.String()
+	}
+	// Configs without a kubelet image tag run the Talos default version,
+	// which is left unknown
+	details.KubernetesVersion, _ = KubernetesVersion(config)
+
+	if cfg.Machine().Type().IsControlPlane() {
+		details.Role = "controlplane"
+		bundle := secrets.NewBundleFromConfig(secrets.NewClock(), cfg)
+		if details.Secrets, err = yaml.Marshal(bundle); err != nil {
+			return NodeDetails{}, fmt.Errorf("failed to encode secrets bundle: %v", err)
+		}
+	}
+	return details, nil
+}
@@ -0,0 +1,66 @@
+package talos
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInspectNode(t *testing.T) {
+	configs := testMachineConfigs(t)
+	connect, _ := fakeConnectors(map[string]*fakeNode{
+		"203.0.113.10": {configured: true, config: configs.ControlPlane, version: "v1.6.7"},
+		"203.0.113.20": {configured: true, config: configs.Worker},
+		"203.0.113.30": {configured: true},
+	})
+
+	tests := []struct {
+		name        string
+		address     string
+		role        string
+		secrets     bool
+		shouldError bool
+	}{
+		{name: "control plane", address: "203.0.113.10", role: "controlplane", secrets: true},
+		{name: "worker", address: "203.0.113.20", role: "worker"},
+		{name: "no machine config", address: "203.0.113.30", shouldError: true},
+		{name: "unreachable", address: "203.0.113.40", shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := InspectNode(context.Background(), connect, tt.address)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("InspectNode() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if tt.shouldError {
+				return
+			}
+
+			if details.Role != tt.role || details.Endpoint != "https://203.0.113.10:6443" {
+				t.Errorf("InspectNode() = %+v", details)
+			}
+			if details.KubernetesVersion != "1.28.0" || details.TalosVersion == "" {
+				t.Errorf("Expected the Kubernetes and Talos versions, got %+v", details)
+			}
+			if (len(details.Secrets) > 0) != tt.secrets {
+				t.Fatalf("Expected secrets %v, got %d bytes", tt.secrets, len(details.Secrets))
+			}
+			if !tt.secrets {
+				return
+			}
+
+			if details.ClusterName != "cloud1" {
+				t.Errorf("Expected cluster name cloud1, got %q", details.ClusterName)
+			}
+
+			// The recovered bundle generates configs for new nodes
+			if _, err := GenerateConfigs(ClusterConfig{
+				Name:         details.ClusterName,
+				Endpoint:     details.Endpoint,
+				TalosVersion: details.TalosVersion,
+			}, details.Secrets); err != nil {
+				t.Errorf("GenerateConfigs() with recovered secrets error = %v", err)
+			}
+		})
+	}
+}
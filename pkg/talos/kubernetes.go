@@ -0,0 +1,236 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/siderolabs/talos/pkg/machinery/config/configloader"
+	"github.com/siderolabs/talos/pkg/machinery/config/encoder"
+	"github.com/siderolabs/talos/pkg/machinery/config/machine"
+	"github.com/siderolabs/talos/pkg/machinery/config/types/v1alpha1"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+)
+
+// KubernetesVersion returns the Kubernetes version a machine config runs,
+// taken from its kubelet image tag
+func KubernetesVersion(config []byte) (string, error) {
+	cfg, err := configloader.NewFromBytes(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse machine config: %v", err)
+	}
+
+	image := cfg.Machine().Kubelet().Image()
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		return strings.TrimPrefix(image[i+1:], "v"), nil
+	}
+
+	return "", fmt.Errorf("kubelet image %q has no version tag", image)
+}
+
+// SetKubernetesVersion retags the kubelet image and, on control plane configs,
+// the API server, controller manager, scheduler and kube-proxy images to
+// version. Custom image repositories are kept.
+func SetKubernetesVersion(config []byte, version string) ([]byte, error) {
+	cfg, err := configloader.NewFromBytes(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse machine config: %v", err)
+	}
+
+	if cfg.RawV1Alpha1() == nil {
+		return nil, fmt.Errorf("machine config has no v1alpha1 document")
+	}
+
+	tag := "v" + strings.TrimPrefix(version, "v")
+
+	patched, err := cfg.PatchV1Alpha1(func(raw *v1alpha1.Config) error {
+		if raw.MachineConfig == nil {
+			return fmt.Errorf("machine config has no machine section")
+		}
+
+		// Unset sections fall back to images of the Talos default Kubernetes
+		// version, so they are created to pin the new version
+		if raw.MachineConfig.MachineKubelet == nil {
+			raw.MachineConfig.MachineKubelet = &v1alpha1.KubeletConfig{}
+		}
+		raw.MachineConfig.MachineKubelet.KubeletImage = retag(raw.MachineConfig.MachineKubelet.KubeletImage, constants.KubeletImage, tag)
+
+		cluster := raw.ClusterConfig
+		if cluster == nil || raw.MachineConfig.Type() != machine.TypeControlPlane {
+			return nil
+		}
+
+		if cluster.APIServerConfig == nil {
+			cluster.APIServerConfig = &v1alpha1.APIServerConfig{}
+		}
+		if cluster.ControllerManagerConfig == nil {
+			cluster.ControllerManagerConfig = &v1alpha1.ControllerManagerConfig{}
+		}
+		if cluster.SchedulerConfig == nil {
+			cluster.SchedulerConfig = &v1alpha1.SchedulerConfig{}
+		}
+		if cluster.ProxyConfig == nil {
+			cluster.ProxyConfig = &v1alpha1.ProxyConfig{}
+		}
+
+		cluster.APIServerConfig.ContainerImage = retag(cluster.APIServerConfig.ContainerImage, constants.KubernetesAPIServerImage, tag)
+		cluster.ControllerManagerConfig.ContainerImage = retag(cluster.ControllerManagerConfig.ContainerImage, constants.KubernetesControllerManagerImage, tag)
+		cluster.SchedulerConfig.ContainerImage = retag(cluster.SchedulerConfig.ContainerImage, constants.KubernetesSchedulerImage, tag)
+		cluster.ProxyConfig.ContainerImage = retag(cluster.ProxyConfig.ContainerImage, constants.KubeProxyImage, tag)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := patched.EncodeBytes(encoder.WithComments(encoder.CommentsDisabled))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode machine config: %v", err)
+	}
+	return out, nil
+}
+
+// retag replaces the tag of image, using the default repository if image is empty
+func retag(image, defaultRepository, tag string) string {
+	repository := image
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		repository = image[:i]
+	}
+	if repository == "" {
+		repository = defaultRepository
+	}
+	return repository + ":" + tag
+}
+
+// KubernetesUpgrader updates the Kubernetes version one node at a time, control
+// plane nodes first, by retagging the images in each node's machine config.
+// Talos restarts the static pods and kubelet without a reboot.
+type KubernetesUpgrader struct {
+	connect Connector
+	// apiHealthy checks the Kubernetes API after each control plane node
+	apiHealthy HealthCheck
+
+	Version      string
+	Timeout      time.Duration
+	PollInterval time.Duration
+	Report       func(node, message string)
+}
+
+func NewKubernetesUpgrader(connect Connector, apiHealthy HealthCheck, version string) *KubernetesUpgrader {
+	return &KubernetesUpgrader{
+		connect:      connect,
+		apiHealthy:   apiHealthy,
+		Version:      strings.TrimPrefix(version, "v"),
+		Timeout:      10 * time.Minute,
+		PollInterval: 10 * time.Second,
+		Report:       func(string, string) {},
+	}
+}
+
+// Run upgrades nodes and halts at the first node that does not become healthy
+func (u *KubernetesUpgrader) Run(ctx context.Context, nodes []Node) (UpgradeReport, error) {
+	ordered := upgradeOrder(nodes)
+
+	report := UpgradeReport{Nodes: make([]NodeUpgrade, len(ordered))}
+	for i, node := range ordered {
+		report.Nodes[i] = NodeUpgrade{Node: node.Name, ControlPlane: node.ControlPlane, To: u.Version, Result: UpgradePending}
+	}
+
+	for i, node := range ordered {
+		result := &report.Nodes[i]
+
+		if err := u.upgradeNode(ctx, node, result); err != nil {
+			result.Result = UpgradeFailed
+			result.Error = err.Error()
+			return report, fmt.Errorf("Kubernetes upgrade halted at %s: %v", node.Name, err)
+		}
+	}
+
+	return report, nil
+}
+
+func (u *KubernetesUpgrader) upgradeNode(ctx context.Context, node Node, result *NodeUpgrade) error {
+	c, err := u.connect(ctx, node.Address)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	config, err := c.MachineConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if result.From, err = KubernetesVersion(config); err != nil {
+		return err
+	}
+	if result.From == u.Version {
+		u.Report(node.Name, fmt.Sprintf("already running Kubernetes %s", u.Version))
+		result.Result = UpgradeSkipped
+		return nil
+	}
+
+	updated, err := SetKubernetesVersion(config, u.Version)
+	if err != nil {
+		return err
+	}
+
+	u.Report(node.Name, fmt.Sprintf("updating Kubernetes from %s to %s", result.From, u.Version))
+	if err := c.ApplyConfiguration(ctx, updated); err != nil {
+		return err
+	}
+
+	u.Report(node.Name, "waiting for kubelet and the Kubernetes node to become Ready")
+	if err := u.waitFor(ctx, func(ctx context.Context) bool {
+		c, err := u.connect(ctx, node.Address)
+		if err != nil {
+			return false
+		}
+		defer c.Close()
+
+		kubelet, err := c.ServiceStatus(ctx, "kubelet")
+		if err != nil || kubelet.State != "Running" || !kubelet.Healthy {
+			return false
+		}
+		ready, err := c.NodeReady(ctx)
+		return err == nil && ready
+	}); err != nil {
+		return fmt.Errorf("node did not become Ready: %v", err)
+	}
+
+	if node.ControlPlane {
+		u.Report(node.Name, "waiting for the Kubernetes API")
+		if err := u.waitFor(ctx, func(ctx context.Context) bool {
+			return u.apiHealthy(ctx) == nil
+		}); err != nil {
+			return fmt.Errorf("Kubernetes API did not become healthy: %v", err)
+		}
+	}
+
+	result.Result = UpgradeDone
+	u.Report(node.Name, "upgraded")
+	return nil
+}
+
+// waitFor waits one poll interval for the change to be picked up, then polls
+// check until it passes or Timeout elapses
+func (u *KubernetesUpgrader) waitFor(ctx context.Context, check func(ctx context.Context) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(u.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if check(ctx) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMachineConfigs(t *testing.T) MachineConfigs {
+	t.Helper()
+
+	secrets, err := GenerateSecrets("v1.6.0")
+	if err != nil {
+		t.Fatalf("GenerateSecrets() error = %v", err)
+	}
+
+	configs, err := GenerateConfigs(ClusterConfig{
+		Name:              "cloud1",
+		Endpoint:          "https://203.0.113.10:6443",
+		TalosVersion:      "v1.6.0",
+		KubernetesVersion: "1.28.0",
+		ControlPlanes:     []string{"203.0.113.10"},
+	}, secrets)
+	if err != nil {
+		t.Fatalf("GenerateConfigs() error = %v", err)
+	}
+
+	return configs
+}
+
+func TestSetKubernetesVersion(t *testing.T) {
+	configs := testMachineConfigs(t)
+
+	tests := []struct {
+		name        string
+		config      []byte
+		version     string
+		want        []string
+		dontWant    []string
+		shouldError bool
+	}{
+		{
+			name:    "control plane",
+			config:  configs.ControlPlane,
+			version: "v1.29.3",
+			want: []string{
+				"ghcr.io/siderolabs/kubelet:v1.29.3",
+				"registry.k8s.io/kube-apiserver:v1.29.3",
+				"registry.k8s.io/kube-controller-manager:v1.29.3",
+				"registry.k8s.io/kube-scheduler:v1.29.3",
+				"registry.k8s.io/kube-proxy:v1.29.3",
+			},
+			dontWant: []string{"v1.28.0"},
+		},
+		{
+			name:     "worker",
+			config:   configs.Worker,
+			version:  "1.29.3",
+			want:     []string{"ghcr.io/siderolabs/kubelet:v1.29.3"},
+			dontWant: []string{"kube-apiserver"},
+		},
+		{
+			name:        "invalid config",
+			config:      []byte("not a machine config"),
+			version:     "1.29.3",
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := SetKubernetesVersion(tt.config, tt.version)
+			if (err != nil) != tt.shouldError {
+				t.Fatalf("SetKubernetesVersion() error = %v, shouldError %v", err, tt.shouldError)
+			}
+			if tt.shouldError {
+				return
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("Expected config to contain %q", want)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(string(out), dontWant) {
+					t.Errorf("Expected config not to contain %q", dontWant)
+				}
+			}
+
+			version, err := KubernetesVersion(out)
+			if err != nil {
+				t.Fatalf("KubernetesVersion() error = %v", err)
+			}
+			if version != "1.29.3" {
+				t.Errorf("KubernetesVersion() = %q, expected 1.29.3", version)
+			}
+		})
+	}
+}
+
+func TestRetag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"", "registry.k8s.io/kube-apiserver:v1.29.3"},
+		{"registry.k8s.io/kube-apiserver:v1.28.0", "registry.k8s.io/kube-apiserver:v1.29.3"},
+		{"mirror.example.com:5000/kube-apiserver:v1.28.0", "mirror.example.com:5000/kube-apiserver:v1.29.3"},
+		{"mirror.example.com:5000/kube-apiserver", "mirror.example.com:5000/kube-apiserver:v1.29.3"},
+	}
+
+	for _, tt := range tests {
+		if got := retag(tt.image, "registry.k8s.io/kube-apiserver", "v1.29.3"); got != tt.want {
+			t.Errorf("retag(%q) = %q, expected %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+func kubernetesNode(t *testing.T, config []byte) *fakeNode {
+	t.Helper()
+
+	return &fakeNode{
+		configured: true,
+		config:     config,
+		kubelet:    ServiceStatus{ID: "kubelet", State: "Running", Healthy: true},
+		ready:      true,
+	}
+}
+
+func newTestKubernetesUpgrader(nodes map[string]*fakeNode, apiHealthy HealthCheck) *KubernetesUpgrader {
+	connect, _ := fakeConnectors(nodes)
+	u := NewKubernetesUpgrader(connect, apiHealthy, "v1.29.3")
+	u.Timeout = 50 * time.Millisecond
+	u.PollInterval = time.Millisecond
+	return u
+}
+
+func TestKubernetesUpgraderRun(t *testing.T) {
+	configs := testMachineConfigs(t)
+	current, err := SetKubernetesVersion(configs.Worker, "1.29.3")
+	if err != nil {
+		t.Fatalf("SetKubernetesVersion() error = %v", err)
+	}
+
+	nodes := map[string]*fakeNode{
+		"10.0.0.1": kubernetesNode(t, configs.ControlPlane),
+		"10.0.0.2": kubernetesNode(t, configs.Worker),
+		"10.0.0.3": kubernetesNode(t, current),
+	}
+	healthy := func(ctx context.Context) error { return nil }
+
+	u := newTestKubernetesUpgrader(nodes, healthy)
+	report, err := u.Run(context.Background(), []Node{
+		{Name: "worker-0", Address: "10.0.0.2"},
+		{Name: "worker-1", Address: "10.0.0.3"},
+		{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v, expected nil", err)
+	}
+
+	want := map[string]string{"cp-0": UpgradeDone, "worker-0": UpgradeDone, "worker-1": UpgradeSkipped}
+	if report.Nodes[0].Node != "cp-0" {
+		t.Errorf("Expected control plane first, got %s", report.Nodes[0].Node)
+	}
+	for _, node := range report.Nodes {
+		if node.Result != want[node.Node] {
+			t.Errorf("%s result = %s, expected %s", node.Node, node.Result, want[node.Node])
+		}
+		if node.Node != "worker-1" && node.From != "1.28.0" {
+			t.Errorf("%s from = %s, expected 1.28.0", node.Node, node.From)
+		}
+	}
+
+	if nodes["10.0.0.3"].applied != nil {
+		t.Error("Expected config not to be applied to a node on the target version")
+	}
+	for _, address := range []string{"10.0.0.1", "10.0.0.2"} {
+		if version, _ := KubernetesVersion(nodes[address].applied); version != "1.29.3" {
+			t.Errorf("Applied config on %s runs %q, expected 1.29.3", address, version)
+		}
+	}
+}
+
+func TestKubernetesUpgraderHalts(t *testing.T) {
+	configs := testMachineConfigs(t)
+
+	tests := []struct {
+		name       string
+		kubelet    ServiceStatus
+		apiHealthy HealthCheck
+	}{
+		{
+			name:       "kubelet unhealthy",
+			kubelet:    ServiceStatus{ID: "kubelet", State: "Running"},
+			apiHealthy: func(ctx context.Context) error { return nil },
+		},
+		{
+			name:       "API unhealthy",
+			kubelet:    ServiceStatus{ID: "kubelet", State: "Running", Healthy: true},
+			apiHealthy: func(ctx context.Context) error { return fmt.Errorf("connection refused") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := kubernetesNode(t, configs.ControlPlane)
+			cp.kubelet = tt.kubelet
+			nodes := map[string]*fakeNode{
+				"10.0.0.1": cp,
+				"10.0.0.2": kubernetesNode(t, configs.Worker),
+			}
+
+			u := newTestKubernetesUpgrader(nodes, tt.apiHealthy)
+			report, err := u.Run(context.Background(), []Node{
+				{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+				{Name: "worker-0", Address: "10.0.0.2"},
+			})
+			if err == nil {
+				t.Fatal("Run() error = nil, expected an error")
+			}
+
+			if failed, ok := report.Failed(); !ok || failed.Node != "cp-0" {
+				t.Errorf("Failed() = %v, %v, expected cp-0", failed, ok)
+			}
+			if report.Nodes[1].Result != UpgradePending {
+				t.Errorf("worker-0 result = %s, expected %s", report.Nodes[1].Result, UpgradePending)
+			}
+			if nodes["10.0.0.2"].applied != nil {
+				t.Error("Expected worker not to be touched after the control plane failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package talos
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Statuses of the Kubernetes Node Ready condition
+const (
+	ConditionTrue    = "True"
+	ConditionFalse   = "False"
+	ConditionUnknown = "Unknown"
+)
+
+// NodeInfo is what a node reports about itself through the Talos API
+type NodeInfo struct {
+	TalosVersion string
+	// KubernetesReady is the status of the Node's Ready condition
+	KubernetesReady string
+}
+
+// QueryNodes asks every node for its Talos version and Kubernetes readiness,
+// concurrently and within timeout. Nodes that cannot be reached report an
+// Unknown Ready condition and no version. The result is keyed by node name.
+func QueryNodes(ctx context.Context, connect Connector, nodes []Node, timeout time.Duration) map[string]NodeInfo {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		infos = make(map[string]NodeInfo, len(nodes))
+	)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node Node) {
+			defer wg.Done()
+
+			info := queryNode(ctx, connect, node)
+			mu.Lock()
+			infos[node.Name] = info
+			mu.Unlock()
+		}(node)
+	}
+	wg.Wait()
+
+	return infos
+}
+
+func queryNode(ctx context.Context, connect Connector, node Node) NodeInfo {
+	info := NodeInfo{KubernetesReady: ConditionUnknown}
+	if node.Address == "" {
+		return info
+	}
+
+	c, err := connect(ctx, node.Address)
+	if err != nil {
+		return info
+	}
+	defer c.Close()
+
+	if version, err := c.Version(ctx); err == nil {
+		info.TalosVersion = version
+	}
+
+	ready, err := c.NodeReady(ctx)
+	switch {
+	case err != nil:
+	case ready:
+		info.KubernetesReady = ConditionTrue
+	default:
+		info.KubernetesReady = ConditionFalse
+	}
+	return info
+}
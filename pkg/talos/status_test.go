@@ -0,0 +1,34 @@
+package talos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryNodes(t *testing.T) {
+	nodes := map[string]*fakeNode{
+		"10.0.0.1": {configured: true, version: "v1.7.0", ready: true},
+		"10.0.0.2": {configured: true, version: "v1.6.7"},
+	}
+	connect, _ := fakeConnectors(nodes)
+
+	infos := QueryNodes(context.Background(), connect, []Node{
+		{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+		{Name: "worker-0", Address: "10.0.0.2"},
+		{Name: "worker-1", Address: "10.0.0.3"},
+		{Name: "worker-2"},
+	}, time.Second)
+
+	expected := map[string]NodeInfo{
+		"cp-0":     {TalosVersion: "v1.7.0", KubernetesReady: ConditionTrue},
+		"worker-0": {TalosVersion: "v1.6.7", KubernetesReady: ConditionFalse},
+		"worker-1": {KubernetesReady: ConditionUnknown},
+		"worker-2": {KubernetesReady: ConditionUnknown},
+	}
+	for name, want := range expected {
+		if got := infos[name]; got != want {
+			t.Errorf("QueryNodes()[%s] = %+v, expected %+v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Upgrade results of a single node
+const (
+	UpgradeDone    = "upgraded"
+	UpgradeSkipped = "skipped"
+	UpgradeFailed  = "failed"
+	UpgradePending = "pending"
+)
+
+// InstallerImage returns the official Talos installer image for a version
+func InstallerImage(version string) string {
+	return "ghcr.io/siderolabs/installer:" + normalizeVersion(version)
+}
+
+// NodeUpgrade is the outcome of upgrading one node
+type NodeUpgrade struct {
+	Node         string
+	ControlPlane bool
+	From         string
+	To           string
+	Result       string
+	Error        string
+}
+
+// UpgradeReport lists the outcome for every node in upgrade order. Nodes after
+// a failure are left pending.
+type UpgradeReport struct {
+	Nodes []NodeUpgrade
+}
+
+// Failed returns the node the upgrade halted on, if any
+func (r UpgradeReport) Failed() (NodeUpgrade, bool) {
+	for _, node := range r.Nodes {
+		if node.Result == UpgradeFailed {
+			return node, true
+		}
+	}
+	return NodeUpgrade{}, false
+}
+
+// Upgrader performs rolling Talos upgrades: one node at a time, control plane
+// nodes first, waiting for each node to come back on the new version with
+// healthy etcd and a Ready Kubernetes node before moving on
+type Upgrader struct {
+	connect Connector
+
+	// Version is the target Talos version and Image the installer image
+	Version string
+	Image   string
+	// Preserve keeps the ephemeral partition across the upgrade
+	Preserve bool
+	// Timeout bounds waiting for each node to rejoin
+	Timeout      time.Duration
+	PollInterval time.Duration
+	// Report receives progress messages per node
+	Report func(node, message string)
+}
+
+// NewUpgrader creates an upgrader to version using the official installer image
+func NewUpgrader(connect Connector, version string) *Upgrader {
+	return &Upgrader{
+		connect:      connect,
+		Version:      normalizeVersion(version),
+		Image:        InstallerImage(version),
+		Timeout:      15 * time.Minute,
+		PollInterval: 10 * time.Second,
+		Report:       func(string, string) {},
+	}
+}
+
+// Run upgrades nodes and halts on the first node that fails a health check. The
+// report is returned in either case.
+func (u *Upgrader) Run(ctx context.Context, nodes []Node) (UpgradeReport, error) {
+	ordered := upgradeOrder(nodes)
+
+	var controlPlanes []Node
+	for _, node := range ordered {
+		if node.ControlPlane {
+			controlPlanes = append(controlPlanes, node)
+		}
+	}
+
+	report := UpgradeReport{Nodes: make([]NodeUpgrade, len(ordered))}
+	for i, node := range ordered {
+		report.Nodes[i] = NodeUpgrade{Node: node.Name, ControlPlane: node.ControlPlane, To: u.Version, Result: UpgradePending}
+	}
+
+	for i, node := range ordered {
+		result := &report.Nodes[i]
+
+		if err := u.upgradeNode(ctx, node, controlPlanes, result); err != nil {
+			result.Result = UpgradeFailed
+			result.Error = err.Error()
+			return report, fmt.Errorf("upgrade halted at %s: %v", node.Name, err)
+		}
+	}
+
+	return report, nil
+}
+
+func (u *Upgrader) upgradeNode(ctx context.Context, node Node, controlPlanes []Node, result *NodeUpgrade) error {
+	c, err := u.connect(ctx, node.Address)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	current, err := c.Version(ctx)
+	if err != nil {
+		return err
+	}
+	result.From = current
+
+	if normalizeVersion(current) == u.Version {
+		u.Report(node.Name, fmt.Sprintf("already running %s", current))
+		result.Result = UpgradeSkipped
+		return nil
+	}
+
+	if node.ControlPlane {
+		if err := u.checkQuorum(ctx, node, controlPlanes); err != nil {
+			return err
+		}
+	}
+
+	u.Report(node.Name, fmt.Sprintf("upgrading from %s to %s", current, u.Version))
+	if err := c.Upgrade(ctx, u.Image, u.Preserve); err != nil {
+		return err
+	}
+
+	u.Report(node.Name, "waiting for the node to reboot into the new version")
+	if err := u.waitFor(ctx, node, "Talos "+u.Version, func(ctx context.Context, c Client) (bool, error) {
+		version, err := c.Version(ctx)
+		return err == nil && normalizeVersion(version) == u.Version, nil
+	}); err != nil {
+		return err
+	}
+
+	if node.ControlPlane {
+		u.Report(node.Name, "waiting for etcd")
+		if err := u.waitFor(ctx, node, "healthy etcd", etcdHealthy); err != nil {
+			return err
+		}
+	}
+
+	u.Report(node.Name, "waiting for the Kubernetes node to become Ready")
+	if err := u.waitFor(ctx, node, "Ready Kubernetes node", func(ctx context.Context, c Client) (bool, error) {
+		ready, err := c.NodeReady(ctx)
+		return err == nil && ready, nil
+	}); err != nil {
+		return err
+	}
+
+	result.Result = UpgradeDone
+	u.Report(node.Name, "upgraded")
+	return nil
+}
+
+// checkQuorum makes sure etcd keeps quorum while node reboots. Clusters with
+// fewer than three members cannot, so only full health is required there.
+func (u *Upgrader) checkQuorum(ctx context.Context, node Node, controlPlanes []Node) error {
+	members := len(controlPlanes)
+	healthy := 0
+	nodeHealthy := false
+
+	for _, cp := range controlPlanes {
+		c, err := u.connect(ctx, cp.Address)
+		if err != nil {
+			continue
+		}
+		ok, _ := etcdHealthy(ctx, c)
+		c.Close()
+
+		if ok {
+			healthy++
+			if cp.Name == node.Name {
+				nodeHealthy = true
+			}
+		}
+	}
+
+	if members < 3 {
+		if healthy < members {
+			return fmt.Errorf("etcd has %d of %d healthy members", healthy, members)
+		}
+		u.Report(node.Name, fmt.Sprintf("warning: etcd with %d members loses quorum while %s reboots", members, node.Name))
+		return nil
+	}
+
+	remaining := healthy
+	if nodeHealthy {
+		remaining--
+	}
+	if quorum := members/2 + 1; remaining < quorum {
+		return fmt.Errorf("etcd has %d of %d healthy members, upgrading %s would lose quorum", healthy, members, node.Name)
+	}
+
+	return nil
+}
+
+func etcdHealthy(ctx context.Context, c Client) (bool, error) {
+	status, err := c.ServiceStatus(ctx, "etcd")
+	if err != nil {
+		return false, nil
+	}
+	return status.State == "Running" && status.Healthy, nil
+}
+
+// waitFor reconnects to the node until check passes, as the node reboots
+// during the upgrade
+func (u *Upgrader) waitFor(ctx context.Context, node Node, what string, check func(ctx context.Context, c Client) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, u.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(u.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if c, err := u.connect(ctx, node.Address); err == nil {
+			done, err := check(ctx, c)
+			c.Close()
+			if err != nil {
+				return err
+			}
+			if done {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s: %v", what, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// upgradeOrder returns control plane nodes first, keeping the given order
+func upgradeOrder(nodes []Node) []Node {
+	ordered := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.ControlPlane {
+			ordered = append(ordered, node)
+		}
+	}
+	for _, node := range nodes {
+		if !node.ControlPlane {
+			ordered = append(ordered, node)
+		}
+	}
+	return ordered
+}
+
+func normalizeVersion(version string) string {
+	return "v" + strings.TrimPrefix(version, "v")
+}
@@ -0,0 +1,145 @@
+package talos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func upgradableNode(version string) *fakeNode {
+	return &fakeNode{
+		configured: true,
+		etcd:       healthyEtcd(),
+		version:    version,
+		upgradeTo:  "v1.6.7",
+		ready:      true,
+	}
+}
+
+func newTestUpgrader(nodes map[string]*fakeNode) (*Upgrader, *[]string) {
+	connect, _ := fakeConnectors(nodes)
+	u := NewUpgrader(connect, "1.6.7")
+	u.Timeout = 50 * time.Millisecond
+	u.PollInterval = time.Millisecond
+
+	var order []string
+	u.Report = func(node, message string) {
+		if message == "upgraded" {
+			order = append(order, node)
+		}
+	}
+
+	return u, &order
+}
+
+func TestUpgraderRun(t *testing.T) {
+	nodes := map[string]*fakeNode{
+		"10.0.0.1": upgradableNode("v1.6.0"),
+		"10.0.0.2": upgradableNode("v1.6.0"),
+		"10.0.0.3": upgradableNode("v1.6.0"),
+		"10.0.0.4": upgradableNode("v1.6.7"),
+	}
+
+	u, order := newTestUpgrader(nodes)
+
+	report, err := u.Run(context.Background(), []Node{
+		{Name: "worker-0", Address: "10.0.0.4"},
+		{Name: "worker-1", Address: "10.0.0.3"},
+		{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+		{Name: "cp-1", Address: "10.0.0.2", ControlPlane: true},
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v, expected nil", err)
+	}
+
+	expected := []string{"cp-0", "cp-1", "worker-1"}
+	if len(*order) != len(expected) {
+		t.Fatalf("Expected upgrade order %v, got %v", expected, *order)
+	}
+	for i := range expected {
+		if (*order)[i] != expected[i] {
+			t.Errorf("Expected upgrade order %v, got %v", expected, *order)
+			break
+		}
+	}
+
+	if nodes["10.0.0.1"].upgraded[0] != "ghcr.io/siderolabs/installer:v1.6.7" {
+		t.Errorf("Unexpected installer image %s", nodes["10.0.0.1"].upgraded[0])
+	}
+	if len(nodes["10.0.0.4"].upgraded) != 0 {
+		t.Error("Expected a node on the target version to be skipped")
+	}
+
+	results := map[string]string{}
+	for _, node := range report.Nodes {
+		results[node.Node] = node.Result
+	}
+	if results["worker-0"] != UpgradeSkipped || results["cp-0"] != UpgradeDone {
+		t.Errorf("Unexpected results %v", results)
+	}
+}
+
+func TestUpgraderHaltsOnFailure(t *testing.T) {
+	nodes := map[string]*fakeNode{
+		"10.0.0.1": upgradableNode("v1.6.0"),
+		"10.0.0.2": upgradableNode("v1.6.0"),
+	}
+	// The node never becomes Ready after the upgrade
+	nodes["10.0.0.1"].ready = false
+
+	u, _ := newTestUpgrader(nodes)
+
+	report, err := u.Run(context.Background(), []Node{
+		{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+		{Name: "worker-0", Address: "10.0.0.2"},
+	})
+	if err == nil {
+		t.Fatal("Run() error = nil, expected an error")
+	}
+
+	failed, ok := report.Failed()
+	if !ok || failed.Node != "cp-0" || failed.Error == "" {
+		t.Errorf("Expected cp-0 to be reported as failed, got %+v", report.Nodes)
+	}
+	if report.Nodes[1].Result != UpgradePending {
+		t.Errorf("Expected worker-0 to stay pending, got %s", report.Nodes[1].Result)
+	}
+	if len(nodes["10.0.0.2"].upgraded) != 0 {
+		t.Error("Expected the upgrade to halt before worker-0")
+	}
+}
+
+func TestUpgraderQuorum(t *testing.T) {
+	tests := []struct {
+		name        string
+		unhealthy   []string
+		shouldError bool
+	}{
+		{name: "all members healthy", shouldError: false},
+		{name: "upgrading the unhealthy member", unhealthy: []string{"10.0.0.1"}, shouldError: false},
+		{name: "another member unhealthy", unhealthy: []string{"10.0.0.2"}, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := map[string]*fakeNode{
+				"10.0.0.1": upgradableNode("v1.6.0"),
+				"10.0.0.2": upgradableNode("v1.6.7"),
+				"10.0.0.3": upgradableNode("v1.6.7"),
+			}
+			for _, address := range tt.unhealthy {
+				nodes[address].etcd = ServiceStatus{ID: "etcd", State: "Running"}
+			}
+			u, _ := newTestUpgrader(nodes)
+
+			err := u.checkQuorum(context.Background(), Node{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true}, []Node{
+				{Name: "cp-0", Address: "10.0.0.1", ControlPlane: true},
+				{Name: "cp-1", Address: "10.0.0.2", ControlPlane: true},
+				{Name: "cp-2", Address: "10.0.0.3", ControlPlane: true},
+			})
+			if (err != nil) != tt.shouldError {
+				t.Errorf("checkQuorum() error = %v, shouldError %v", err, tt.shouldError)
+			}
+		})
+	}
+}